@@ -0,0 +1,126 @@
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+// Fake only moves when Advance is called, firing due timers and tickers in order
+type Fake struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	now     time.Time
+	waiters []*fakeWaiter
+}
+
+type fakeWaiter struct {
+	fake   *Fake
+	c      chan time.Time
+	when   time.Time
+	period time.Duration // zero for timers
+}
+
+func NewFake(now time.Time) *Fake {
+	f := &Fake{now: now}
+	f.cond = sync.NewCond(&f.mu)
+	return f
+}
+
+func (f *Fake) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+func (f *Fake) NewTimer(d time.Duration) Timer {
+	return f.add(d, 0)
+}
+
+func (f *Fake) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("non-positive interval for NewTicker")
+	}
+	return fakeTicker{f.add(d, d)}
+}
+
+func (f *Fake) add(d, period time.Duration) *fakeWaiter {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	w := &fakeWaiter{fake: f, c: make(chan time.Time, 1), when: f.now.Add(d), period: period}
+	if d <= 0 && period == 0 {
+		w.c <- f.now
+		return w
+	}
+	f.waiters = append(f.waiters, w)
+	f.cond.Broadcast()
+	return w
+}
+
+// Advance moves the clock forward. Like the real ones, timers and tickers drop ticks nobody has read yet
+func (f *Fake) Advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	end := f.now.Add(d)
+	for {
+		next := -1
+		for i, w := range f.waiters {
+			if !w.when.After(end) && (next == -1 || w.when.Before(f.waiters[next].when)) {
+				next = i
+			}
+		}
+		if next == -1 {
+			break
+		}
+
+		w := f.waiters[next]
+		f.now = w.when
+		select {
+		case w.c <- w.when:
+		default:
+		}
+		if w.period > 0 {
+			w.when = w.when.Add(w.period)
+		} else {
+			f.waiters = append(f.waiters[:next], f.waiters[next+1:]...)
+		}
+	}
+	f.now = end
+	f.cond.Broadcast()
+}
+
+// BlockUntil waits till n timers and tickers are running, so a test knows its goroutine is waiting on the clock
+func (f *Fake) BlockUntil(n int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for len(f.waiters) != n {
+		f.cond.Wait()
+	}
+}
+
+func (w *fakeWaiter) C() <-chan time.Time {
+	return w.c
+}
+
+func (w *fakeWaiter) Stop() bool {
+	f := w.fake
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for i, other := range f.waiters {
+		if other == w {
+			f.waiters = append(f.waiters[:i], f.waiters[i+1:]...)
+			f.cond.Broadcast()
+			return true
+		}
+	}
+	return false
+}
+
+type fakeTicker struct {
+	w *fakeWaiter
+}
+
+func (t fakeTicker) C() <-chan time.Time { return t.w.c }
+func (t fakeTicker) Stop()               { t.w.Stop() }
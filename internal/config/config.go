@@ -23,12 +23,19 @@ type BotConfig struct {
 	WorkerNumber   int
 	IsPrivate      bool
 	KaspiCard      string
+	FairnessPolicy string
 }
 
 type APIConfig struct {
 	IsExampleData             bool
 	CourseURL                 string
 	TimeIntervalBetweenParses time.Duration
+	SubscriptionsExpireAfter  time.Duration
+	SchedulePath              string
+	// AdaptivePolling tunes the interval between parses outside registration windows
+	AdaptivePolling bool
+	MinInterval     time.Duration
+	MaxInterval     time.Duration
 }
 
 // envStage = ("dev", "prod")
@@ -38,6 +45,12 @@ func LoadConfig() *Config {
 	exampleData := flag.Bool("example-data", false, "Load example data for testing (default: false)")
 	workerNumTelegram := flag.Int("telegram-workers", 10, "Number of Telegram workers for processing updates")
 	timeIntreval := flag.Duration("time-interval", 3*time.Hour, "Time interval between course parses")
+	fairness := flag.String("fairness", "least-recent", "Order of users notified about the same free seat (random, round-robin, least-recent)")
+	schedulePath := flag.String("schedule", "./schedule.json", "JSON file with registration windows, reloaded on SIGHUP or change")
+	adaptive := flag.Bool("adaptive", false, "Poll more often when seats change a lot and less often when nothing changes (default: false)")
+	minInterval := flag.Duration("min-interval", 20*time.Minute, "Shortest adaptive interval between course parses")
+	maxInterval := flag.Duration("max-interval", 6*time.Hour, "Longest adaptive interval between course parses")
+	expireAfter := flag.Duration("expire-after", 0, "Time after the last registration window when subscriptions expire, applied only till that moment passes (default: 0, disabled)")
 
 	flag.Parse()
 
@@ -49,15 +62,21 @@ func LoadConfig() *Config {
 	cfg := &Config{
 		EnvStage: *stage,
 		BotConfig: BotConfig{
-			Token:        os.Getenv("TELEGRAM_BOT_TOKEN"),
-			IsPrivate:    *private,
-			WorkerNumber: *workerNumTelegram,
-			KaspiCard:    os.Getenv("KASPI_CARD"),
+			Token:          os.Getenv("TELEGRAM_BOT_TOKEN"),
+			IsPrivate:      *private,
+			WorkerNumber:   *workerNumTelegram,
+			KaspiCard:      os.Getenv("KASPI_CARD"),
+			FairnessPolicy: *fairness,
 		},
 		APIConfig: APIConfig{
 			IsExampleData:             *exampleData,
 			CourseURL:                 os.Getenv("COURCES_API_URL"),
 			TimeIntervalBetweenParses: *timeIntreval,
+			SubscriptionsExpireAfter:  *expireAfter,
+			SchedulePath:              *schedulePath,
+			AdaptivePolling:           *adaptive,
+			MinInterval:               *minInterval,
+			MaxInterval:               *maxInterval,
 		},
 	}
 
@@ -65,8 +84,12 @@ func LoadConfig() *Config {
 		panic("COURCES_API_URL environment variable is not set")
 	}
 
+	// admin commands are available in public mode too, only private mode requires admins
+	if adminID := os.Getenv("TELEGRAM_ADMIN_ID"); adminID != "" {
+		cfg.BotConfig.AdminID = parseInt64Array(adminID)
+	}
+
 	if *private {
-		cfg.BotConfig.AdminID = parseInt64Array(os.Getenv("TELEGRAM_ADMIN_ID"))
 		if len(cfg.BotConfig.AdminID) == 0 {
 			panic("TELEGRAM_ADMIN_ID environment variable is not set or invalid")
 		}
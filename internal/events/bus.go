@@ -0,0 +1,95 @@
+package events
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/TheTeemka/telegram_bot_cources/internal/models"
+)
+
+// CatalogUpdated is published after every successful parse of the catalog
+type CatalogUpdated struct {
+	Diff     *models.CatalogDiff
+	ParsedAt time.Time
+	Manual   bool // requested by an admin instead of the schedule
+}
+
+// CatalogRefreshFailed is published when the catalog could not be parsed
+type CatalogRefreshFailed struct {
+	Err    error
+	At     time.Time
+	Manual bool
+}
+
+// EnrollmentRecorded is published by the history recorder once the enrollment of a catalog update
+// is saved, readers of the enrollment history wait for it instead of CatalogUpdated
+type EnrollmentRecorded struct {
+	CatalogUpdated
+}
+
+// Bus is the in-process event bus. Every event type has its own topic
+type Bus struct {
+	CatalogUpdated       Topic[CatalogUpdated]
+	CatalogRefreshFailed Topic[CatalogRefreshFailed]
+	EnrollmentRecorded   Topic[EnrollmentRecorded]
+}
+
+func NewBus() *Bus {
+	return &Bus{}
+}
+
+const subscriberBuffer = 4
+
+// Topic delivers every published event to each of its subscribers in order
+type Topic[T any] struct {
+	mu          sync.RWMutex
+	subscribers []chan T
+}
+
+// Subscribe must be called before publishing starts, events published earlier are not replayed
+func (t *Topic[T]) Subscribe() <-chan T {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	ch := make(chan T, subscriberBuffer)
+	t.subscribers = append(t.subscribers, ch)
+	return ch
+}
+
+// Publish blocks till every subscriber accepted the event, so a slow subscriber slows the
+// publisher down instead of missing events. Subscribers with room in their buffer get the event
+// right away, a full one doesn't hold up the others
+func (t *Topic[T]) Publish(ctx context.Context, event T) error {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	var full []chan T
+	for _, ch := range t.subscribers {
+		select {
+		case ch <- event:
+		default:
+			full = append(full, ch)
+		}
+	}
+	if len(full) == 0 {
+		return nil
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, len(full))
+	for _, ch := range full {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			select {
+			case ch <- event:
+			case <-ctx.Done():
+				errs <- ctx.Err()
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	return <-errs
+}
@@ -0,0 +1,49 @@
+package events
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTopicPublish(t *testing.T) {
+	var topic Topic[int]
+	first, second := topic.Subscribe(), topic.Subscribe()
+
+	for i := range subscriberBuffer {
+		assert.NoError(t, topic.Publish(context.Background(), i))
+	}
+	for i := range subscriberBuffer {
+		assert.Equal(t, i, <-first)
+		assert.Equal(t, i, <-second)
+	}
+
+	// nobody reads, the buffer is full and publishing waits for the context
+	for i := range subscriberBuffer {
+		assert.NoError(t, topic.Publish(context.Background(), i))
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, topic.Publish(ctx, 0), context.DeadlineExceeded)
+}
+
+func TestTopicPublishDoesNotWaitInSequence(t *testing.T) {
+	var topic Topic[int]
+	slow, fast := topic.Subscribe(), topic.Subscribe()
+	for i := range subscriberBuffer {
+		assert.NoError(t, topic.Publish(context.Background(), i))
+		<-fast
+	}
+
+	// the slow subscriber is full, the fast one gets the event while the publisher waits
+	published := make(chan error, 1)
+	go func() { published <- topic.Publish(context.Background(), subscriberBuffer) }()
+	assert.Equal(t, subscriberBuffer, <-fast)
+
+	for i := range subscriberBuffer + 1 {
+		assert.Equal(t, i, <-slow)
+	}
+	assert.NoError(t, <-published)
+}
@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/TheTeemka/telegram_bot_cources/internal/models"
+	"github.com/TheTeemka/telegram_bot_cources/internal/telegramfmt"
+	tapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+var (
+	courseArg   = Arg{Name: "Course Name", MaxWords: 2}
+	sectionsArg = Arg{Name: "Course Sections", Repeated: true}
+)
+
+// commands is the registry of every command the bot understands, in the order of the command menu
+func (h *MessageHandler) commands() []*Command {
+	return []*Command{
+		{Name: "start", Description: "Start the bot", Handle: h.HandleStart},
+		{Name: "help", Description: "List of commands", Handle: h.HandleHelp},
+		{
+			Name: "subscribe", Description: "Subscribe to a course", Args: []Arg{courseArg, {Name: "Course Sections", Repeated: true, Optional: true}},
+			Dialog: models.StateSubscribe,
+			Prompt: "Please send a course code (e.g. 'PHYS 161') or a part of its title to pick the sections with buttons.\n\nYou can still send everything at once.\nFormat: <code>[Course Name] [Course Sections]</code>.\nExample: 'PHYS 161 2L 1PLB 2R 2r 3plb 3L'\n\nYou can also provide .txt file from crashed.nu",
+		},
+		{
+			Name: "bundle", Description: "Track linked sections together", Args: []Arg{courseArg, sectionsArg, {Name: "partial", Choices: []string{"partial"}, Optional: true}},
+			Dialog: models.StateBundle,
+			Prompt: "Please provide a course abbr and the sections that must be free together.\nFormat: <code>[Course Name] [Course Sections] [partial]</code>.\nExample: 'PHYS 161 2L 1PLB 3R'\n\nAdd <code>partial</code> at the end to also get quiet updates when only some of the sections are free.",
+		},
+		{
+			Name: "newsections", Description: "Toggle notifications about new sections", Args: []Arg{courseArg},
+			Dialog: models.StateNewSections,
+			Prompt: "Please provide a course abbr you are subscribed to, to toggle notifications about its new sections.\nExample: 'PHYS161'.",
+		},
+		{
+			Name: "urgent", Description: "Get instant notifications for sections", Args: []Arg{courseArg, {Name: "Course Sections", Repeated: true, Optional: true}, {Name: "off", Choices: []string{"off"}, Optional: true}},
+			Dialog: models.StateUrgent,
+			Prompt: "Please provide a course abbr and sections you want to hear about instantly instead of a digest.\nFormat: <code>[Course Name] [Course Sections] [off]</code>.\nExample: 'PHYS 161 2L 1PLB'\n\nSkip sections to apply it to the whole course, add <code>off</code> to return to digests.",
+		},
+		{
+			Name: "expire", Description: "Stop watching sections after some time", Args: []Arg{courseArg, {Name: "Course Sections", Repeated: true, Optional: true}, {Name: "duration"}},
+			Dialog: models.StateExpire,
+			Prompt: "Please provide a course abbr, sections and how long to watch them.\nFormat: <code>[Course Name] [Course Sections] [7d|12h|off]</code>.\nExample: 'PHYS 161 2L 1PLB 3d'\n\nSkip sections to apply it to the whole course, <code>off</code> keeps watching till you unsubscribe.",
+		},
+		{
+			Name: "unsubscribe", Description: "Unsubscribe from a course", Args: []Arg{courseArg},
+			Dialog: models.StateUnsubscribe,
+			Prompt: "Please provide a course abbr as in docs.\nFormat: <code>[Course Name]</code>.\nExample: 'PHYS161'.",
+		},
+		{Name: "cancel", Description: "Cancel the current command", KeepState: true, Handle: h.HandleCancel},
+		{Name: "list", Description: "List your subscriptions", Handle: h.ListSubscriptions},
+		{Name: "notifications", Description: "When you were notified about free seats", Handle: h.HandleNotificationLog},
+		{Name: "trending", Description: "Most watched and fastest filling courses", Handle: h.HandleTrending},
+		{Name: "settings", Description: "Quiet hours, daily digest and study year", Args: []Arg{{Name: "setting", Choices: []string{"quiet", "digest", "year"}, Optional: true}, {Name: "value", Optional: true}}, Handle: h.HandleSettings},
+		{Name: "faq", Description: "Frequently Asked Questions", Handle: h.HandleFAQ},
+		{Name: "schedule", Description: "Registration windows and update times", Handle: h.HandleSchedule},
+		{Name: "nextupdatetime", Description: "next sync time", Handle: h.HandleNextUpdateTime},
+		{Name: "donate", Description: "Donate to the bot", Hidden: true, Handle: h.HandleDonate},
+
+		{Name: "parsestat", Description: "Save request statistics", Role: RoleAdmin, Handle: h.parsestat},
+		{Name: "syncdata1", Description: "Refresh the catalog now", Role: RoleAdmin, Handle: h.syncdata1},
+		{Name: "predictionstat", Description: "Accuracy of fill predictions", Role: RoleAdmin, Handle: h.predictionstat},
+		{Name: "windows", Description: "List registration windows", Role: RoleAdmin, Handle: h.HandleWindows},
+		{Name: "addwindow", Description: "Add a registration window", Args: []Arg{{Name: "time"}, {Name: "ramp=profile", Optional: true}, {Name: "label", Repeated: true}}, Role: RoleAdmin, Handle: h.HandleAddWindow},
+		{Name: "delwindow", Description: "Remove a registration window", Args: []Arg{{Name: "number"}}, Role: RoleAdmin, Handle: h.HandleDeleteWindow},
+	}
+}
+
+// CommandsList sets the command menu of everybody, admins additionally see their commands
+func (h *MessageHandler) CommandsList() []tapi.SetMyCommandsConfig {
+	res := []tapi.SetMyCommandsConfig{tapi.NewSetMyCommands(h.router.Menu(RoleUser)...)}
+	for _, adminID := range h.AdminID {
+		res = append(res, tapi.NewSetMyCommandsWithScope(tapi.NewBotCommandScopeChat(adminID), h.router.Menu(RoleAdmin)...))
+	}
+	return res
+}
+
+func (h *MessageHandler) role(userID int64) Role {
+	if slices.Contains(h.AdminID, userID) {
+		return RoleAdmin
+	}
+	return RoleUser
+}
+
+func (h *MessageHandler) HandleStart(cmd *tapi.Message) []tapi.Chattable {
+	mf := telegramfmt.NewMessageFormatter(cmd.From.ID)
+
+	return mf.ImmediateMessage(h.welcomeText)
+}
+
+func (h *MessageHandler) HandleHelp(cmd *tapi.Message) []tapi.Chattable {
+	mf := telegramfmt.NewMessageFormatter(cmd.From.ID)
+
+	return mf.ImmediateMessage(h.router.Help(h.role(cmd.From.ID)))
+}
+
+func (h *MessageHandler) HandleFAQ(cmd *tapi.Message) []tapi.Chattable {
+	mf := telegramfmt.NewMessageFormatter(cmd.From.ID)
+
+	// the active ramp and the polling interval change with the schedule, so the FAQ is generated on every request
+	interval, reason := h.Refresher.PollingInterval()
+	return mf.ImmediateMessage(generateFAQText(h.Schedule.ActiveProfile(h.Clock.Now()), h.FairnessPolicy,
+		interval, telegramfmt.Escape(reason), h.router.Help(RoleUser)))
+}
+
+func (h *MessageHandler) HandleDonate(cmd *tapi.Message) []tapi.Chattable {
+	mf := telegramfmt.NewMessageFormatter(cmd.From.ID)
+
+	return mf.ImmediateMessage(fmt.Sprintf("\n Toss a coin to your humble bot,\nO student of fate, \nWhen rivals draw near, and\nthe registration deadline won’t wait.\nA humble donation, a whisper, a nudge,\nTo tilt odds in your favor in timetable wars\n\nKaspi: <code>%s</code>\n[Click to the number to copy]", h.KaspiCard))
+}
+
+func (h *MessageHandler) HandleNextUpdateTime(cmd *tapi.Message) []tapi.Chattable {
+	mf := telegramfmt.NewMessageFormatter(cmd.From.ID)
+
+	return mf.ImmediateMessage(fmt.Sprintf("Next update time is: %s", telegramfmt.Escape(h.Refresher.NextRefresh().Format("15:04:05 02.01.2006"))))
+}
@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/TheTeemka/telegram_bot_cources/internal/models"
+	"github.com/TheTeemka/telegram_bot_cources/internal/telegramfmt"
+	tapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// dialogTTL is how long the bot waits for an answer before forgetting the dialog
+const dialogTTL = 30 * time.Minute
+
+// dialogStep handles the answer to a step of a dialog, it either moves the dialog on or leaves it
+type dialogStep func(msg *tapi.Message, state *models.ChatState) []tapi.Chattable
+
+// transitions lists the states every state may move to, checked against the state the user is in.
+// Moving between the steps of the same dialog is always allowed, another dialog is started from idle
+var transitions = map[models.StateName][]models.StateName{
+	models.StateIdle: {
+		models.StateSubscribe, models.StateBundle, models.StateNewSections,
+		models.StateUrgent, models.StateExpire, models.StateUnsubscribe,
+	},
+	models.StateSubscribe:   {models.StateIdle},
+	models.StateBundle:      {models.StateIdle},
+	models.StateNewSections: {models.StateIdle},
+	models.StateUrgent:      {models.StateIdle},
+	models.StateExpire:      {models.StateIdle},
+	models.StateUnsubscribe: {models.StateIdle},
+}
+
+// dialogSteps lists the steps of every dialog in order
+func (h *MessageHandler) dialogSteps() map[models.StateName][]dialogStep {
+	return map[models.StateName][]dialogStep{
+		// typing another course at the section picker starts it over
+		models.StateSubscribe:   {h.subscribeCourse, h.subscribeCourse},
+		models.StateBundle:      {h.answer(h.HandleBundle)},
+		models.StateNewSections: {h.answer(h.HandleNewSections)},
+		models.StateUrgent:      {h.answer(h.HandleUrgent)},
+		models.StateExpire:      {h.answer(h.HandleExpire)},
+		models.StateUnsubscribe: {h.answer(h.HandleUnsubscribe)},
+	}
+}
+
+// answer turns a handler into the last step of a dialog
+func (h *MessageHandler) answer(next handler) dialogStep {
+	return func(msg *tapi.Message, state *models.ChatState) []tapi.Chattable {
+		if err := h.leaveDialog(state); err != nil {
+			slog.Error("Failed to leave dialog", "error", err, "user_id", msg.From.ID, "state", state.Name)
+			mf := telegramfmt.NewMessageFormatter(msg.From.ID)
+			return mf.ImmediateMessage("⚠️ Failed to clear your state. Please try again later.")
+		}
+		return next(msg)
+	}
+}
+
+func canTransition(from, to models.StateName) bool {
+	return slices.Contains(transitions[from], to)
+}
+
+func stateName(state *models.ChatState) models.StateName {
+	if state.Idle() {
+		return models.StateIdle
+	}
+	return state.Name
+}
+
+// enterDialog starts the first step of a dialog, the user must not be in another one
+func (h *MessageHandler) enterDialog(userID int64, name models.StateName) (*models.ChatState, error) {
+	current, err := h.StateRepo.Get(userID)
+	if err != nil {
+		return nil, err
+	}
+	if from := stateName(current); !canTransition(from, name) {
+		return nil, fmt.Errorf("dialog %q can't be started from %q", name, from)
+	}
+	state := &models.ChatState{
+		TelegramID: userID,
+		Name:       name,
+		ExpiresAt:  h.Clock.Now().Add(dialogTTL),
+	}
+	return state, h.StateRepo.Save(state)
+}
+
+// goToStep moves the dialog to the step with the answers collected so far
+func (h *MessageHandler) goToStep(state *models.ChatState, step int, payload any) error {
+	if step < 0 || step >= len(h.dialogs[state.Name]) {
+		return fmt.Errorf("dialog %q has no step %d", state.Name, step)
+	}
+	next := *state
+	next.Step = step
+	next.ExpiresAt = h.Clock.Now().Add(dialogTTL)
+	if err := next.SetPayload(payload); err != nil {
+		return err
+	}
+	return h.StateRepo.Save(&next)
+}
+
+// leaveDialog finishes or cancels the dialog
+func (h *MessageHandler) leaveDialog(state *models.ChatState) error {
+	if !canTransition(state.Name, models.StateIdle) {
+		return fmt.Errorf("dialog %q can't be left", state.Name)
+	}
+	return h.StateRepo.Clear(state.TelegramID)
+}
+
+func (h *MessageHandler) HandleCancel(cmd *tapi.Message) []tapi.Chattable {
+	mf := telegramfmt.NewMessageFormatter(cmd.From.ID)
+
+	state, err := h.StateRepo.Get(cmd.From.ID)
+	if err != nil {
+		slog.Error("Failed to get state for user", "user_id", cmd.From.ID, "error", err)
+		return mf.ImmediateMessage("⚠️ Failed to retrieve your state. Please try again later.")
+	}
+	if state.Idle() {
+		return mf.ImmediateMessage("Nothing to cancel.")
+	}
+	if err := h.leaveDialog(state); err != nil {
+		slog.Error("Failed to cancel dialog", "error", err, "user_id", cmd.From.ID, "state", state.Name)
+		return mf.ImmediateMessage("⚠️ Failed to clear your state. Please try again later.")
+	}
+	return mf.ImmediateMessage(fmt.Sprintf("✅ /%s is cancelled", state.Name))
+}
@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/TheTeemka/telegram_bot_cources/internal/clock"
+	"github.com/TheTeemka/telegram_bot_cources/internal/config"
+	"github.com/TheTeemka/telegram_bot_cources/internal/database"
+	"github.com/TheTeemka/telegram_bot_cources/internal/models"
+	"github.com/TheTeemka/telegram_bot_cources/internal/repositories"
+	"github.com/TheTeemka/telegram_bot_cources/internal/ticker"
+	tapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDialogsAreReachable(t *testing.T) {
+	h := &MessageHandler{}
+	steps := h.dialogSteps()
+
+	for _, c := range h.commands() {
+		if c.Dialog == models.StateIdle {
+			continue
+		}
+		assert.True(t, canTransition(models.StateIdle, c.Dialog), "/%s can't start its dialog", c.Name)
+		assert.NotEmpty(t, steps[c.Dialog], "/%s starts a dialog without steps", c.Name)
+	}
+
+	for name := range transitions {
+		if name == models.StateIdle {
+			continue
+		}
+		assert.NotEmpty(t, steps[name], "dialog %q has no steps", name)
+		assert.True(t, canTransition(name, models.StateIdle), "dialog %q can't be cancelled", name)
+	}
+}
+
+func newTestHandler(t *testing.T, clk clock.Clock, courses map[string]*models.Course) *MessageHandler {
+	db := database.NewSQLiteDB(filepath.Join(t.TempDir(), "db.db"))
+	t.Cleanup(func() { db.Close() })
+
+	courseRepo := &repositories.CourseRepository{Courses: courses, LastTimeParsed: clk.Now()}
+	return NewMessageHandler(nil, config.BotConfig{}, courseRepo,
+		repositories.NewSQLiteSubscriptionRepo(db, clk),
+		repositories.NewSQLiteBundleRepo(db, clk),
+		repositories.NewSQLiteInterestRepo(db, clk),
+		repositories.NewStateRepository(db, clk),
+		repositories.NewSQLiteUserSettingsRepo(db, clk),
+		repositories.NewSQLiteNotificationQueueRepo(db, clk),
+		repositories.NewSQLiteUserRepo(db, clk),
+		repositories.NewStatisticsRepository(db, clk),
+		repositories.NewSQLiteWindowRepo(db),
+		ticker.NewSchedule(), nil, nil, nil, clk)
+}
+
+func command(userID int64, text string) *tapi.Message {
+	name, _, _ := strings.Cut(strings.TrimPrefix(text, "/"), " ")
+	return &tapi.Message{
+		From:     &tapi.User{ID: userID},
+		Text:     text,
+		Entities: []tapi.MessageEntity{{Type: "bot_command", Offset: 0, Length: len(name) + 1}},
+	}
+}
+
+func TestDialogLifecycle(t *testing.T) {
+	clk := clock.NewFake(time.Date(2025, 12, 17, 9, 0, 0, 0, time.UTC))
+	h := newTestHandler(t, clk, nil)
+
+	h.HandleCommand(command(1, "/unsubscribe"))
+	state, err := h.StateRepo.Get(1)
+	assert.NoError(t, err)
+	if assert.False(t, state.Idle()) {
+		assert.Equal(t, models.StateUnsubscribe, state.Name)
+		assert.True(t, clk.Now().Add(dialogTTL).Equal(state.ExpiresAt), "expires at %s", state.ExpiresAt)
+	}
+
+	_, err = h.enterDialog(1, models.StateBundle)
+	assert.Error(t, err, "a dialog can't be started from another one")
+
+	h.HandleCommand(command(1, "/bundle"))
+	state, err = h.StateRepo.Get(1)
+	assert.NoError(t, err)
+	assert.Equal(t, models.StateBundle, stateName(state), "a command replaces the ongoing dialog")
+
+	h.HandleCommand(command(1, "/cancel"))
+	state, err = h.StateRepo.Get(1)
+	assert.NoError(t, err)
+	assert.True(t, state.Idle())
+
+	h.HandleCommand(command(1, "/urgent"))
+	clk.Advance(dialogTTL)
+	state, err = h.StateRepo.Get(1)
+	assert.NoError(t, err)
+	assert.True(t, state.Idle(), "dialogs are forgotten after the TTL")
+}
+
+func TestDialogInlineArguments(t *testing.T) {
+	clk := clock.NewFake(time.Date(2025, 12, 17, 9, 0, 0, 0, time.UTC))
+	h := newTestHandler(t, clk, map[string]*models.Course{
+		"PHYS 161": {AbbrName: "PHYS 161", Sections: []*models.Section{{SectionName: "1L"}, {SectionName: "2L"}}},
+	})
+	h.CoursesRepo.SectionAbbrList = []string{"L", "R"}
+	subscriptions := func() []string {
+		subs, err := h.SubscriptionRepo.GetSubscriptions(1)
+		assert.NoError(t, err)
+		var res []string
+		for _, sub := range subs {
+			res = append(res, sub.Course+" "+sub.Section)
+		}
+		return res
+	}
+	idle := func() bool {
+		state, err := h.StateRepo.Get(1)
+		assert.NoError(t, err)
+		return state.Idle()
+	}
+
+	h.HandleCommand(command(1, "/subscribe PHYS 161 2L"))
+	assert.Equal(t, []string{"PHYS 161 2L"}, subscriptions(), "the arguments answer the prompt")
+	assert.True(t, idle())
+
+	replies := replyTexts(h.HandleCommand(command(1, "/unsubscribe PHYS 161 2L")))
+	if assert.Len(t, replies, 1) {
+		assert.Contains(t, replies[0], "Usage: <code>/unsubscribe [Course Name]</code>")
+	}
+	assert.False(t, idle(), "the dialog still asks for the arguments")
+	assert.Equal(t, []string{"PHYS 161 2L"}, subscriptions())
+
+	h.HandleCommand(command(1, "/unsubscribe PHYS 161"))
+	assert.Empty(t, subscriptions())
+	assert.True(t, idle())
+}
@@ -2,59 +2,126 @@ package handlers
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"log/slog"
 	"net/http"
 	"slices"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/TheTeemka/telegram_bot_cources/internal/clock"
 	"github.com/TheTeemka/telegram_bot_cources/internal/config"
+	"github.com/TheTeemka/telegram_bot_cources/internal/models"
 	"github.com/TheTeemka/telegram_bot_cources/internal/repositories"
 	"github.com/TheTeemka/telegram_bot_cources/internal/telegramfmt"
+	"github.com/TheTeemka/telegram_bot_cources/internal/ticker"
 	tapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
 
+// FillPredictor tells when sections are likely to be full
+type FillPredictor interface {
+	PredictFullAt(course, section string) (time.Time, bool)
+	CoursePredictions(course string) map[string]time.Time
+	Accuracy(since time.Time) (models.PredictionAccuracy, error)
+}
+
+// CatalogRefresher refreshes the catalog on demand, letting the tracker and others know about changes
+type CatalogRefresher interface {
+	Refresh(ctx context.Context) error
+	NextRefresh() time.Time
+	PollingInterval() (time.Duration, string)
+}
+
+// ReminderPlanner replans reminders about registration windows of a user
+type ReminderPlanner interface {
+	Plan(userID int64) error
+}
+
 type MessageHandler struct {
 	BotAPI           *tapi.BotAPI
 	StateRepo        repositories.StateRepository
 	CoursesRepo      *repositories.CourseRepository
 	SubscriptionRepo repositories.CourseSubscriptionRepository
+	BundleRepo       repositories.BundleSubscriptionRepository
+	InterestRepo     repositories.CourseInterestRepository
+	SettingsRepo     repositories.UserSettingsRepository
+	QueueRepo        repositories.NotificationQueueRepository
+	UserRepo         repositories.UserRepository
 	StatisticsRepo   *repositories.StatisticsRepository
+	WindowRepo       repositories.RegistrationWindowRepository
+	Schedule         *ticker.Schedule
+	Refresher        CatalogRefresher
+	Predictor        FillPredictor
+	Reminders        ReminderPlanner
+	Clock            clock.Clock
 	Private          bool
 	AdminID          []int64
 	AllowedUsersID   []int64
 
 	welcomeText string
-	faq         string
 	KaspiCard   string
+
+	FairnessPolicy string
+
+	windowsMu sync.Mutex
+	router    *Router
+	dialogs   map[models.StateName][]dialogStep
 }
 
 func NewMessageHandler(botAPI *tapi.BotAPI, cfg config.BotConfig,
 	coursesRepo *repositories.CourseRepository,
 	subscriptionRepo repositories.CourseSubscriptionRepository,
+	bundleRepo repositories.BundleSubscriptionRepository,
+	interestRepo repositories.CourseInterestRepository,
 	stateRepo repositories.StateRepository,
-	statisticsRepo *repositories.StatisticsRepository) *MessageHandler {
-
-	return &MessageHandler{
+	settingsRepo repositories.UserSettingsRepository,
+	queueRepo repositories.NotificationQueueRepository,
+	userRepo repositories.UserRepository,
+	statisticsRepo *repositories.StatisticsRepository,
+	windowRepo repositories.RegistrationWindowRepository,
+	schedule *ticker.Schedule,
+	refresher CatalogRefresher,
+	predictor FillPredictor,
+	reminders ReminderPlanner,
+	clk clock.Clock) *MessageHandler {
+
+	h := &MessageHandler{
 		BotAPI:         botAPI,
 		AdminID:        cfg.AdminID,
 		Private:        cfg.IsPrivate,
 		AllowedUsersID: cfg.AllowedUsersID,
-		faq:            generateFAQText(),
 		welcomeText:    generateWelcomeText(coursesRepo.SemesterName),
 
 		KaspiCard:        cfg.KaspiCard,
+		FairnessPolicy:   cfg.FairnessPolicy,
 		CoursesRepo:      coursesRepo,
 		StateRepo:        stateRepo,
 		SubscriptionRepo: subscriptionRepo,
+		BundleRepo:       bundleRepo,
+		InterestRepo:     interestRepo,
+		SettingsRepo:     settingsRepo,
+		QueueRepo:        queueRepo,
+		UserRepo:         userRepo,
 		StatisticsRepo:   statisticsRepo,
-	}
+		WindowRepo:       windowRepo,
+		Schedule:         schedule,
+		Refresher:        refresher,
+		Predictor:        predictor,
+		Reminders:        reminders,
+		Clock:            clk,
+	}
+	h.router = NewRouter(h.commands())
+	h.dialogs = h.dialogSteps()
+	return h
 }
 
 func (h *MessageHandler) HandleUpdate(update tapi.Update) []tapi.Chattable {
 	h.StatisticsRepo.AddOne("Total_Request_Number")
 	if update.CallbackQuery != nil {
-		return h.HandleCallback(update.CallbackQuery)
+		return append(h.touchUser(update.CallbackQuery.From), h.HandleCallback(update.CallbackQuery)...)
 	}
 
 	if update.Message == nil {
@@ -67,96 +134,99 @@ func (h *MessageHandler) HandleUpdate(update tapi.Update) []tapi.Chattable {
 		}
 	}
 
+	msgs := h.touchUser(update.Message.From)
 	if update.Message.IsCommand() {
-		return h.HandleCommand(update.Message)
+		return append(msgs, h.HandleCommand(update.Message)...)
 	}
-	return h.HandleMessage(update.Message)
+	return append(msgs, h.HandleMessage(update.Message)...)
 
 }
 
-var knownCommands = []string{"start", "subscribe", "unsubscribe", "list", "donate", "faq", "parsestat", "nextupdatetime", "syncdata1"}
-
-func (h *MessageHandler) CommandsList() tapi.SetMyCommandsConfig {
-	return tapi.NewSetMyCommands(
-		tapi.BotCommand{Command: "start", Description: "Start the bot"},
-		tapi.BotCommand{Command: "subscribe", Description: "Subscribe to a course"},
-		tapi.BotCommand{Command: "unsubscribe", Description: "Unsubscribe from a course"},
-		tapi.BotCommand{Command: "list", Description: "List your subscriptions"},
-		tapi.BotCommand{Command: "faq", Description: "Frequently Asked Questions"},
-		// tapi.BotCommand{Command: "gatekeep", Description: "gatekeep your course and section of choice"},
-		// tapi.BotCommand{Command: "donate", Description: "Donate to the bot"},
-		tapi.BotCommand{Command: "nextupdatetime", Description: "next sync time"},
-	)
+// touchUser marks the user as active, resuming subscriptions of those who blocked the bot before
+func (h *MessageHandler) touchUser(user *tapi.User) []tapi.Chattable {
+	reactivated, err := h.UserRepo.Touch(user.ID, user.UserName)
+	if err != nil {
+		slog.Error("Failed to touch user", "error", err, "user_id", user.ID)
+		return nil
+	}
+	if !reactivated {
+		return nil
+	}
+
+	slog.Info("User is back, resuming their subscriptions", "user_id", user.ID, "username", user.UserName)
+	mf := telegramfmt.NewMessageFormatter(user.ID)
+	return mf.ImmediateMessage("👋 Welcome back! Your subscriptions are active again.")
 }
 
 func (h *MessageHandler) HandleCommand(cmd *tapi.Message) []tapi.Chattable {
 	slog.Debug("Handling command", "command", cmd.Command(), "text", cmd.Text, "username", cmd.From.UserName)
 	mf := telegramfmt.NewMessageFormatter(cmd.From.ID)
-	if !slices.Contains(knownCommands, cmd.Command()) {
+	command, ok := h.router.Lookup(cmd.Command())
+	if !ok {
 		return mf.ImmediateMessage("❌ Unknown command " + cmd.Command())
 	}
 
 	h.StatisticsRepo.AddOne("command" + cmd.Command())
-	h.StateRepo.Upsert(cmd.From.ID, "")
-	switch cmd.Command() {
-	case "start":
-		return mf.ImmediateMessage(h.welcomeText)
-	case "list":
-		return h.ListSubscriptions(cmd)
-	case "donate":
-		return mf.ImmediateMessage(fmt.Sprintf("\n Toss a coin to your humble bot,\nO student of fate, \nWhen rivals draw near, and\nthe registration deadline won’t wait.\nA humble donation, a whisper, a nudge,\nTo tilt odds in your favor in timetable wars\n\nKaspi: <code>%s</code>\n[Click to the number to copy]", h.KaspiCard))
-	case "faq":
-		return mf.ImmediateMessage(h.faq)
-	case "nextupdatetime":
-		return mf.ImmediateMessage(fmt.Sprintf("Next update time is: %s", telegramfmt.Escape(h.CoursesRepo.NextTimeToParse.Format("15:04:05 02.01.2006"))))
-	case "parsestat":
-		return AuthAdmin(h.AdminID, h.parsestat)(cmd)
-	case "syncdata1":
-		return AuthAdmin(h.AdminID, h.syncdata1)(cmd)
-	}
-
-	h.StateRepo.Upsert(cmd.From.ID, cmd.Command())
-	switch cmd.Command() {
-	case "subscribe":
-		return mf.ImmediateMessage("Please provide a course abbr and section as in docs.\nFormat: <code>[Course Name] [Course Sections]</code>.\nExample: 'PHYS 161 2L 1PLB 2R 2r 3plb 3L'\n\nYou can also provide .txt file from crashed.nu")
-	case "unsubscribe":
-		return mf.ImmediateMessage("Please provide a course abbr as in docs.\nFormat: <code>`[Course Name]</code>.\nExample: 'PHYS161'.")
-	default:
-		return h.HandleCommandUnknown(cmd)
+	if !command.KeepState {
+		// another command cancels the ongoing dialog
+		if state, err := h.StateRepo.Get(cmd.From.ID); err != nil {
+			slog.Error("Failed to get state for user", "user_id", cmd.From.ID, "error", err)
+		} else if !state.Idle() {
+			if err := h.leaveDialog(state); err != nil {
+				slog.Error("Failed to cancel dialog", "user_id", cmd.From.ID, "state", state.Name, "error", err)
+			}
+		}
+	}
+	if command.Role == RoleAdmin {
+		return AuthAdmin(h.AdminID, command.Handle)(cmd)
+	}
+	if command.Dialog == models.StateIdle {
+		return command.Handle(cmd)
 	}
+
+	state, err := h.enterDialog(cmd.From.ID, command.Dialog)
+	if err != nil {
+		slog.Error("Failed to start dialog", "user_id", cmd.From.ID, "dialog", command.Dialog, "error", err)
+		return mf.ImmediateMessage("⚠️ Failed to start the command. Please try again later.")
+	}
+	// without arguments they come with the next message
+	words := strings.Fields(cmd.CommandArguments())
+	if len(words) == 0 {
+		return mf.ImmediateMessage(command.Prompt + "\n\n<i>/cancel to stop</i>")
+	}
+	if !command.Accepts(words) {
+		return mf.ImmediateMessage(fmt.Sprintf("❌ Usage: <code>%s</code>\n\n%s\n\n<i>/cancel to stop</i>",
+			telegramfmt.Escape(command.Usage()), command.Prompt))
+	}
+	// typed inline they answer the first step, as if sent after the prompt
+	answer := *cmd
+	answer.Text = strings.Join(words, " ")
+	answer.Entities = nil
+	return h.dialogs[command.Dialog][0](&answer, state)
 }
 
 func (h *MessageHandler) HandleMessage(msg *tapi.Message) []tapi.Chattable {
 	mf := telegramfmt.NewMessageFormatter(msg.From.ID)
 
 	slog.Debug("Handling message", "text", msg.Text, "username", msg.From.UserName)
-	state, err := h.StateRepo.GetState(msg.From.ID)
-
+	state, err := h.StateRepo.Get(msg.From.ID)
 	if err != nil {
 		slog.Error("Failed to get state for user", "user_id", msg.From.ID, "error", err)
 		return mf.ImmediateMessage("⚠️ Failed to retrieve your state. Please try again later.")
 	}
-
-	err = h.StateRepo.Upsert(msg.From.ID, "")
-	if err != nil {
-		slog.Error("Failed to clear state for user", "user_id", msg.From.ID, "error", err)
-		return mf.ImmediateMessage("⚠️ Failed to clear your state. Please try again later.")
+	if state.Idle() {
+		return h.HandleCourseCode(msg)
 	}
 
-	switch state {
-	case "":
-		return h.HandleCourseCode(msg)
-	case "start":
-		return h.HandleCommandStart(msg)
-	case "subscribe":
-		return h.HandleSubscribe(msg)
-	case "unsubscribe":
-		return h.HandleUnsubscribe(msg)
-	case "list":
-		return h.ListSubscriptions(msg)
-	default:
-		return h.HandleCommandUnknown(msg) //TODO: panic
+	steps := h.dialogs[state.Name]
+	if state.Step >= len(steps) {
+		slog.Error("Unknown dialog step", "user_id", msg.From.ID, "state", state.Name, "step", state.Step)
+		if err := h.StateRepo.Clear(msg.From.ID); err != nil {
+			slog.Error("Failed to clear state for user", "user_id", msg.From.ID, "error", err)
+		}
+		return h.HandleCommandUnknown(msg)
 	}
+	return steps[state.Step](msg, state)
 }
 
 func (h *MessageHandler) HandleSubscribe(cmd *tapi.Message) []tapi.Chattable {
@@ -166,6 +236,12 @@ func (h *MessageHandler) HandleSubscribe(cmd *tapi.Message) []tapi.Chattable {
 
 	mf := telegramfmt.NewMessageFormatter(cmd.From.ID)
 	courseAbbr, sectionNames, err := h.parseCommandArguments(cmd.Text)
+	if err == ErrNotEnoughParams {
+		courseAbbr = telegramfmt.StandartizeCourseName(cmd.Text)
+		if _, exists := h.CoursesRepo.GetCourse(courseAbbr); courseAbbr != "" && !exists {
+			return h.notFoundCourse(cmd.From.ID, courseAbbr)
+		}
+	}
 	if err != nil {
 		switch err {
 		case ErrNotEnoughParams:
@@ -177,10 +253,10 @@ func (h *MessageHandler) HandleSubscribe(cmd *tapi.Message) []tapi.Chattable {
 	}
 
 	course, exists := h.CoursesRepo.GetCourse(courseAbbr)
-	courseAbbr = course.AbbrName
 	if !exists {
-		return mf.ImmediateNotFoundCourse(courseAbbr, "for subscription")
+		return h.notFoundCourse(cmd.From.ID, courseAbbr)
 	}
+	courseAbbr = course.AbbrName
 
 	if valid, sect := h.CoursesRepo.CheckForValidness(courseAbbr, sectionNames); !valid {
 		return mf.ImmediateNotFoundCourseSection(courseAbbr, sect, "for subscription")
@@ -198,6 +274,38 @@ func (h *MessageHandler) HandleSubscribe(cmd *tapi.Message) []tapi.Chattable {
 	return mf.ImmediateMessage(fmt.Sprintf("✅ Successfully subscribed to <b>%s (%s)</b>", courseAbbr, strings.Join(sectionNames, ", ")))
 }
 
+// notFoundCourse replies to a subscription to a course missing from the catalog. It may be a typo,
+// so the interest in the course is registered only if the user asks for it
+func (h *MessageHandler) notFoundCourse(userID int64, courseAbbr string) []tapi.Chattable {
+	mf := telegramfmt.NewMessageFormatter(userID)
+	mf.AddString(fmt.Sprintf("❌ Course <b>%s</b> not found\nCheck the spelling. If the course is not offered yet, I can send you its sections as soon as it appears in the catalog.",
+		telegramfmt.Escape(courseAbbr)))
+	mf.NotifyWhenOfferedOrIgnore(courseAbbr)
+	return mf.Messages()
+}
+
+// registerInterest remembers a course that is not offered yet, so the user gets notified once it appears
+func (h *MessageHandler) registerInterest(mf *telegramfmt.MessageFormatter, userID int64, courseAbbr string) {
+	if _, exists := h.CoursesRepo.GetCourse(courseAbbr); exists {
+		mf.AddString(fmt.Sprintf("📝 Course <b>%s</b> is in the catalog already, call /subscribe to pick its sections",
+			telegramfmt.Escape(courseAbbr)))
+		return
+	}
+
+	err := h.InterestRepo.Add(userID, courseAbbr)
+	if err != nil {
+		slog.Error("Failed to register course interest",
+			"error", err,
+			"user_id", userID,
+			"course", courseAbbr)
+		mf.AddString("⚠️ Failed to subscribe to the course. Please try again.")
+		return
+	}
+
+	mf.AddString(fmt.Sprintf("🕓 Course <b>%s</b> is not offered yet.\nI will send you its sections as soon as it appears in the catalog.",
+		telegramfmt.Escape(courseAbbr)))
+}
+
 func (h *MessageHandler) HandleSubscribeFromCrashedNUFile(cmd *tapi.Message) []tapi.Chattable {
 	mf := telegramfmt.NewMessageFormatter(cmd.From.ID)
 
@@ -238,8 +346,61 @@ func (h *MessageHandler) HandleSubscribeFromCrashedNUFile(cmd *tapi.Message) []t
 	return mf.Messages()
 }
 
+func (h *MessageHandler) HandleBundle(cmd *tapi.Message) []tapi.Chattable {
+	mf := telegramfmt.NewMessageFormatter(cmd.From.ID)
+
+	text := strings.TrimSpace(cmd.Text)
+	notifyPartial := false
+	if fields := strings.Fields(text); len(fields) > 0 && strings.EqualFold(fields[len(fields)-1], "partial") {
+		notifyPartial = true
+		text = strings.Join(fields[:len(fields)-1], " ")
+	}
+	if text == "" {
+		return mf.ImmediateMessage("❌ You haven't provided coursename. If you want to try again, first call /bundle")
+	}
+
+	courseAbbr, sectionNames, err := h.parseCommandArguments(text)
+	if err != nil {
+		switch err {
+		case ErrNotEnoughParams:
+			return mf.ImmediateMessage("❌ You haven't provided enough arguments. If you want to try again, first call /bundle")
+		case ErrInvalidParams:
+			return mf.ImmediateMessage("❌ You haven't provided valid parameters for the command. If you want to try again, first call /bundle")
+		}
+		return mf.ImmediateMessage("❌ You haven't provided coursename. If you want to try again, first call /bundle")
+	}
+	sectionNames = slices.Compact(slices.Sorted(slices.Values(sectionNames)))
+	if len(sectionNames) < 2 {
+		return mf.ImmediateMessage("❌ A bundle needs at least two sections. Use /subscribe to track a single section")
+	}
+
+	course, exists := h.CoursesRepo.GetCourse(courseAbbr)
+	if !exists {
+		return mf.ImmediateNotFoundCourse(courseAbbr, "for bundle")
+	}
+	courseAbbr = course.AbbrName
+
+	if valid, sect := h.CoursesRepo.CheckForValidness(courseAbbr, sectionNames); !valid {
+		return mf.ImmediateNotFoundCourseSection(courseAbbr, sect, "for bundle")
+	}
+
+	err = h.BundleRepo.Subscribe(cmd.From.ID, courseAbbr, sectionNames, notifyPartial)
+	if err != nil {
+		slog.Error("Failed to subscribe to bundle",
+			"error", err,
+			"user_id", cmd.From.ID,
+			"course", courseAbbr)
+		return mf.ImmediateMessage("⚠️ Failed to subscribe to the bundle. Please try again.")
+	}
+
+	return mf.ImmediateMessage(fmt.Sprintf("✅ Successfully subscribed to bundle <b>%s (%s)</b>", courseAbbr, strings.Join(sectionNames, ", ")))
+}
+
 func (h *MessageHandler) parseCommandArguments(args string) (string, []string, error) {
 	fields := strings.Fields(args)
+	if len(fields) == 0 {
+		return "", nil, ErrNotEnoughParams
+	}
 
 	courseName := fields[0]
 	ind := 1
@@ -287,7 +448,18 @@ func (h *MessageHandler) HandleUnsubscribe(cmd *tapi.Message) []tapi.Chattable {
 	}
 
 	if _, exists := h.CoursesRepo.GetCourse(courseName); !exists {
-		return mf.ImmediateNotFoundCourse(courseName, "for unsubscribing")
+		removed, err := h.InterestRepo.Remove(cmd.From.ID, courseName)
+		if err != nil {
+			slog.Error("Failed to remove course interest",
+				"error", err,
+				"user_id", cmd.From.ID,
+				"course", courseName)
+			return mf.ImmediateMessage("⚠️ Failed to unsubscribe to the course. Please try again.")
+		}
+		if !removed {
+			return mf.ImmediateNotFoundCourse(courseName, "for unsubscribing")
+		}
+		return mf.ImmediateMessage(fmt.Sprintf("✅ You will no longer be notified when <b>%s</b> appears", telegramfmt.Escape(courseName)))
 	}
 
 	err := h.SubscriptionRepo.UnSubscribe(cmd.From.ID, courseName)
@@ -299,9 +471,157 @@ func (h *MessageHandler) HandleUnsubscribe(cmd *tapi.Message) []tapi.Chattable {
 		return mf.ImmediateMessage("⚠️ Failed to unsubscribe to the course. Please try again.")
 	}
 
+	err = h.BundleRepo.DeleteByCourse(cmd.From.ID, courseName)
+	if err != nil {
+		slog.Error("Failed to remove bundles",
+			"error", err,
+			"user_id", cmd.From.ID,
+			"course", courseName)
+		return mf.ImmediateMessage("⚠️ Failed to unsubscribe to the course. Please try again.")
+	}
+
 	return mf.ImmediateMessage(fmt.Sprintf("✅ Successfully unsubscribed from <b>%s</b>", courseName))
 }
 
+func (h *MessageHandler) HandleNewSections(cmd *tapi.Message) []tapi.Chattable {
+	mf := telegramfmt.NewMessageFormatter(cmd.From.ID)
+	courseName := telegramfmt.StandartizeCourseName(cmd.Text)
+	if courseName == "" {
+		return mf.ImmediateMessage("❌ You haven't provided coursename")
+	}
+
+	course, exists := h.CoursesRepo.GetCourse(courseName)
+	if !exists {
+		return mf.ImmediateNotFoundCourse(courseName, "")
+	}
+	courseName = course.AbbrName
+
+	enabled, subscribed, err := h.SubscriptionRepo.ToggleNewSections(cmd.From.ID, courseName)
+	if err != nil {
+		slog.Error("Failed to toggle new sections notifications",
+			"error", err,
+			"user_id", cmd.From.ID,
+			"course", courseName)
+		return mf.ImmediateMessage("⚠️ Failed to change the setting. Please try again.")
+	}
+	if !subscribed {
+		return mf.ImmediateMessage(fmt.Sprintf("❌ You are not subscribed to <b>%s</b>, call /subscribe first", courseName))
+	}
+
+	if enabled {
+		return mf.ImmediateMessage(fmt.Sprintf("🔔 You will be notified about new sections of <b>%s</b>", courseName))
+	}
+	return mf.ImmediateMessage(fmt.Sprintf("🔕 You will no longer be notified about new sections of <b>%s</b>", courseName))
+}
+
+func (h *MessageHandler) HandleUrgent(cmd *tapi.Message) []tapi.Chattable {
+	mf := telegramfmt.NewMessageFormatter(cmd.From.ID)
+
+	text := strings.TrimSpace(cmd.Text)
+	urgent := true
+	if fields := strings.Fields(text); len(fields) > 0 && strings.EqualFold(fields[len(fields)-1], "off") {
+		urgent = false
+		text = strings.Join(fields[:len(fields)-1], " ")
+	}
+
+	courseAbbr, sectionNames, err := h.parseCommandArguments(text)
+	if err == ErrNotEnoughParams {
+		courseAbbr, err = telegramfmt.StandartizeCourseName(text), nil
+	}
+	if err != nil || courseAbbr == "" {
+		return mf.ImmediateMessage("❌ You haven't provided valid parameters for the command. If you want to try again, first call /urgent")
+	}
+
+	course, exists := h.CoursesRepo.GetCourse(courseAbbr)
+	if !exists {
+		return mf.ImmediateNotFoundCourse(courseAbbr, "")
+	}
+	courseAbbr = course.AbbrName
+
+	n, err := h.SubscriptionRepo.SetUrgent(cmd.From.ID, courseAbbr, sectionNames, urgent)
+	if err != nil {
+		slog.Error("Failed to set urgent subscriptions",
+			"error", err,
+			"user_id", cmd.From.ID,
+			"course", courseAbbr)
+		return mf.ImmediateMessage("⚠️ Failed to change the setting. Please try again.")
+	}
+	if n == 0 {
+		return mf.ImmediateMessage(fmt.Sprintf("⚠️ You are not subscribed to these sections of <b>%s</b>. First call /subscribe", courseAbbr))
+	}
+
+	if urgent {
+		return mf.ImmediateMessage(fmt.Sprintf("⚡ %d section(s) of <b>%s</b> will be notified instantly", n, courseAbbr))
+	}
+	return mf.ImmediateMessage(fmt.Sprintf("✅ %d section(s) of <b>%s</b> will be notified in digests", n, courseAbbr))
+}
+
+func (h *MessageHandler) HandleExpire(cmd *tapi.Message) []tapi.Chattable {
+	mf := telegramfmt.NewMessageFormatter(cmd.From.ID)
+
+	fields := strings.Fields(cmd.Text)
+	if len(fields) < 2 {
+		return mf.ImmediateMessage("❌ You haven't provided enough arguments. If you want to try again, first call /expire")
+	}
+	var expiresAt time.Time
+	if ttl := fields[len(fields)-1]; !strings.EqualFold(ttl, "off") {
+		d, err := parseTTL(ttl)
+		if err != nil {
+			return mf.ImmediateMessage("❌ Invalid duration, use something like <code>12h</code> or <code>7d</code>. If you want to try again, first call /expire")
+		}
+		expiresAt = h.Clock.Now().Add(d)
+	}
+	text := strings.Join(fields[:len(fields)-1], " ")
+
+	courseAbbr, sectionNames, err := h.parseCommandArguments(text)
+	if err == ErrNotEnoughParams {
+		courseAbbr, err = telegramfmt.StandartizeCourseName(text), nil
+	}
+	if err != nil || courseAbbr == "" {
+		return mf.ImmediateMessage("❌ You haven't provided valid parameters for the command. If you want to try again, first call /expire")
+	}
+
+	n, err := h.SubscriptionRepo.SetExpiry(cmd.From.ID, courseAbbr, sectionNames, expiresAt)
+	if err != nil {
+		slog.Error("Failed to set subscription expiry",
+			"error", err,
+			"user_id", cmd.From.ID,
+			"course", courseAbbr)
+		return mf.ImmediateMessage("⚠️ Failed to change the setting. Please try again.")
+	}
+	if n == 0 {
+		return mf.ImmediateMessage(fmt.Sprintf("⚠️ You are not subscribed to these sections of <b>%s</b>. First call /subscribe", telegramfmt.Escape(courseAbbr)))
+	}
+
+	if expiresAt.IsZero() {
+		return mf.ImmediateMessage(fmt.Sprintf("♾ %d section(s) of <b>%s</b> will be watched till you unsubscribe", n, telegramfmt.Escape(courseAbbr)))
+	}
+	location := time.FixedZone("UTC+5", 5*60*60)
+	return mf.ImmediateMessage(fmt.Sprintf("⏳ %d section(s) of <b>%s</b> will be watched till %s", n, telegramfmt.Escape(courseAbbr), expiresAt.In(location).Format("15:04 02.01.2006")))
+}
+
+// parseTTL accepts Go durations and whole days, like "7d"
+func parseTTL(s string) (time.Duration, error) {
+	var (
+		d   time.Duration
+		err error
+	)
+	if days, ok := strings.CutSuffix(strings.ToLower(s), "d"); ok {
+		var n int
+		n, err = strconv.Atoi(days)
+		d = time.Duration(n) * 24 * time.Hour
+	} else {
+		d, err = time.ParseDuration(s)
+	}
+	if err != nil {
+		return 0, err
+	}
+	if d < time.Hour {
+		return 0, fmt.Errorf("duration %s is shorter than an hour", d)
+	}
+	return d, nil
+}
+
 func (h *MessageHandler) Clear(cmd *tapi.Message) []tapi.Chattable {
 	mf := telegramfmt.NewMessageFormatter(cmd.From.ID)
 
@@ -323,14 +643,24 @@ func (h *MessageHandler) ListSubscriptions(cmd *tapi.Message) []tapi.Chattable {
 		slog.Error("⚠️ Failed to get subscriptions", "err", err)
 		return mf.ImmediateMessage("⚠️ Failed to retrieve your subscriptions. Please try again later.")
 	}
-	if len(subs) == 0 {
+	bundles, err := h.BundleRepo.GetBundles(cmd.From.ID)
+	if err != nil {
+		slog.Error("⚠️ Failed to get bundle subscriptions", "err", err)
+		return mf.ImmediateMessage("⚠️ Failed to retrieve your subscriptions. Please try again later.")
+	}
+	interests, err := h.InterestRepo.GetInterests(cmd.From.ID)
+	if err != nil {
+		slog.Error("⚠️ Failed to get course interests", "err", err)
+		return mf.ImmediateMessage("⚠️ Failed to retrieve your subscriptions. Please try again later.")
+	}
+	if len(subs) == 0 && len(bundles) == 0 && len(interests) == 0 {
 		return mf.ImmediateMessage("⚠️ You haven't subscribed to any courses yet.")
 	}
 
 	var sb strings.Builder
 	sb.WriteString("Your subscriptions:\n")
 	for _, sub := range subs {
-		_, exists := h.CoursesRepo.GetCourse(sub.Course)
+		course, exists := h.CoursesRepo.GetCourse(sub.Course)
 		if !exists {
 			mf.AddNotFoundCourse(sub.Course)
 			mf.UnsubscribeOrIgnoreCourse(sub.Course)
@@ -342,13 +672,29 @@ func (h *MessageHandler) ListSubscriptions(cmd *tapi.Message) []tapi.Chattable {
 			mf.AddNotFoundCourseSection(sub.Course, sub.Section)
 			mf.UnsubscribeOrIgnoreSection(sub.Course, sub.Section)
 		} else {
-			sb.WriteString(telegramfmt.FormatCourseSection(sub.Course, sub.Section, section.Size, section.Cap))
+			fullAt, _ := h.Predictor.PredictFullAt(course.AbbrName, sub.Section)
+			sb.WriteString(telegramfmt.FormatCourseSection(sub.Course, sub.Section, section.Size, section.Cap, fullAt, h.Clock.Now()))
+		}
+	}
+	if len(bundles) != 0 {
+		sb.WriteString("\nYour bundles:\n")
+		for _, bundle := range bundles {
+			sb.WriteString(telegramfmt.FormatBundle(bundle))
+		}
+	}
+	if len(interests) != 0 {
+		sb.WriteString("\nWaiting for courses to appear:\n")
+		for _, interest := range interests {
+			sb.WriteString(fmt.Sprintf("• <code>%s</code>\n", telegramfmt.Escape(interest.Course)))
 		}
 	}
 	timeStr := h.CoursesRepo.LastTimeParsed.Format("Last Update on: 15:04:05 02.01.2006")
 	sb.WriteString(fmt.Sprintf("\n<i>%s</i> \n@nu_cources_bot", timeStr))
 
 	mf.AddString(sb.String())
+	if len(bundles) != 0 {
+		mf.RemoveBundlesKeyboard(bundles)
+	}
 	return mf.Messages()
 }
 
@@ -362,7 +708,7 @@ func (h *MessageHandler) HandleCourseCode(updateMsg *tapi.Message) []tapi.Chatta
 		return mf.ImmediateNotFoundCourse(courseAbbr, "")
 	}
 
-	return mf.ImmediateMessage(telegramfmt.FormatCourseInDetails(course, h.CoursesRepo.SemesterName, h.CoursesRepo.LastTimeParsed))
+	return mf.ImmediateMessage(telegramfmt.FormatCourseInDetails(course, h.CoursesRepo.SemesterName, h.CoursesRepo.LastTimeParsed, h.Predictor.CoursePredictions(course.AbbrName), h.Clock.Now()))
 }
 
 func (h *MessageHandler) HandleCommandUnknown(cmd *tapi.Message) []tapi.Chattable {
@@ -372,11 +718,12 @@ func (h *MessageHandler) HandleCommandUnknown(cmd *tapi.Message) []tapi.Chattabl
 
 }
 
-func (h *MessageHandler) HandleCommandStart(cmd *tapi.Message) []tapi.Chattable {
-	mf := telegramfmt.NewMessageFormatter(cmd.From.ID)
-
-	return mf.ImmediateMessage(h.welcomeText)
-}
+const (
+	// maxSnooze keeps snoozes short, so a forgotten one doesn't mute the subscription for good
+	maxSnooze = 24 * time.Hour
+	// keepWatchingFor is how long "keep watching" postpones the expiry of subscriptions
+	keepWatchingFor = 7 * 24 * time.Hour
+)
 
 func (h *MessageHandler) HandleCallback(callback *tapi.CallbackQuery) []tapi.Chattable {
 	mf := telegramfmt.NewMessageFormatter(callback.From.ID)
@@ -397,18 +744,119 @@ func (h *MessageHandler) HandleCallback(callback *tapi.CallbackQuery) []tapi.Cha
 				)
 				if err != nil {
 					slog.Error("Failed to unsubscribe", "error", err, "course", args[1])
+					continue
 				}
+				mf.AddString(fmt.Sprintf("✅ Successfully unsubscribed from <b>%s</b>", telegramfmt.Escape(args[1])))
 			} else if len(args) == 3 {
 				err := h.SubscriptionRepo.UnSubscribeSection(
 					callback.From.ID, args[1], args[2],
 				)
 				if err != nil {
 					slog.Error("Failed to unsubscribe", "error", err, "course", args[1], "section", args[2])
+					continue
 				}
+				mf.AddString(fmt.Sprintf("✅ Successfully unsubscribed from <b>%s (%s)</b>", telegramfmt.Escape(args[1]), telegramfmt.Escape(args[2])))
 			} else {
 				slog.Error("Invalid ignore command format", "command", cmd)
 				continue
 			}
+		case "subscribe":
+			if len(args) != 3 {
+				slog.Error("Invalid subscribe command format", "command", cmd)
+				continue
+			}
+			// a long cross-listed course comes by its first code
+			course, exists := h.CoursesRepo.GetCourse(args[1])
+			if !exists {
+				mf.AddNotFoundCourse(args[1])
+				continue
+			}
+			if valid, _ := h.CoursesRepo.CheckForValidness(course.AbbrName, args[2:]); !valid {
+				mf.AddNotFoundCourseSection(course.AbbrName, args[2])
+				continue
+			}
+			err := h.SubscriptionRepo.Subscribe(callback.From.ID, course.AbbrName, args[2:])
+			if err != nil {
+				slog.Error("Failed to subscribe", "error", err, "course", course.AbbrName, "section", args[2])
+				mf.AddString("⚠️ Failed to subscribe to the course. Please try again.")
+				continue
+			}
+			mf.AddString(fmt.Sprintf("✅ Successfully subscribed to <b>%s (%s)</b>", telegramfmt.Escape(course.AbbrName), telegramfmt.Escape(args[2])))
+		case "course":
+			if len(args) != 2 {
+				slog.Error("Invalid course command format", "command", cmd)
+				continue
+			}
+			course, exists := h.CoursesRepo.GetCourse(args[1])
+			if !exists {
+				mf.AddNotFoundCourse(args[1])
+				continue
+			}
+			mf.AddString(telegramfmt.FormatCourseInDetails(course, h.CoursesRepo.SemesterName, h.CoursesRepo.LastTimeParsed, h.Predictor.CoursePredictions(course.AbbrName), h.Clock.Now()))
+		case "interest":
+			if len(args) != 2 {
+				slog.Error("Invalid interest command format", "command", cmd)
+				continue
+			}
+			h.registerInterest(mf, callback.From.ID, args[1])
+		case "pick", "toggle", "confirm", "abort":
+			h.handleSubscribePicker(mf, callback, args)
+		case "snooze":
+			if len(args) != 4 {
+				slog.Error("Invalid snooze command format", "command", cmd)
+				continue
+			}
+			duration, err := time.ParseDuration(args[3])
+			if err != nil || duration <= 0 || duration > maxSnooze {
+				slog.Error("Invalid snooze duration", "error", err, "command", cmd)
+				continue
+			}
+			until := h.Clock.Now().Add(duration)
+			found, err := h.SubscriptionRepo.Snooze(callback.From.ID, args[1], args[2], until)
+			if err != nil {
+				slog.Error("Failed to snooze", "error", err, "course", args[1], "section", args[2])
+				mf.AddString("⚠️ Failed to snooze the subscription. Please try again.")
+				continue
+			}
+			if !found {
+				mf.AddString(fmt.Sprintf("⚠️ You are not subscribed to <b>%s (%s)</b>", telegramfmt.Escape(args[1]), telegramfmt.Escape(args[2])))
+				continue
+			}
+			location := time.FixedZone("UTC+5", 5*60*60)
+			mf.AddString(fmt.Sprintf("💤 <b>%s (%s)</b> is snoozed till %s",
+				telegramfmt.Escape(args[1]), telegramfmt.Escape(args[2]), until.In(location).Format("15:04 02.01")))
+		case "keep":
+			if len(args) != 2 {
+				slog.Error("Invalid keep command format", "command", cmd)
+				continue
+			}
+			until := h.Clock.Now().Add(keepWatchingFor)
+			n, err := h.SubscriptionRepo.KeepWatching(callback.From.ID, args[1], until)
+			if err != nil {
+				slog.Error("Failed to keep watching", "error", err, "course", args[1])
+				mf.AddString("⚠️ Failed to keep watching the course. Please try again.")
+				continue
+			}
+			if n == 0 {
+				mf.AddString(fmt.Sprintf("⚠️ Subscriptions to <b>%s</b> have already expired. Call /subscribe to watch it again", telegramfmt.Escape(args[1])))
+				continue
+			}
+			location := time.FixedZone("UTC+5", 5*60*60)
+			mf.AddString(fmt.Sprintf("👀 Watching <b>%s</b> till %s", telegramfmt.Escape(args[1]), until.In(location).Format("15:04 02.01")))
+		case "unbundle":
+			if len(args) != 2 {
+				slog.Error("Invalid unbundle command format", "command", cmd)
+				continue
+			}
+			bundleID, err := strconv.ParseInt(args[1], 10, 64)
+			if err != nil {
+				slog.Error("Invalid bundle id", "error", err, "command", cmd)
+				continue
+			}
+			err = h.BundleRepo.Delete(callback.From.ID, bundleID)
+			if err != nil {
+				slog.Error("Failed to remove bundle", "error", err, "bundle_id", bundleID)
+			}
 		}
 	}
 
@@ -429,12 +877,33 @@ func (h *MessageHandler) parsestat(cmd *tapi.Message) []tapi.Chattable {
 func (h *MessageHandler) syncdata1(cmd *tapi.Message) []tapi.Chattable {
 	mf := telegramfmt.NewMessageFormatter(cmd.From.ID)
 
-	err := h.CoursesRepo.Parse()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	err := h.Refresher.Refresh(ctx)
 	if err != nil {
-		slog.Error("Failed to parse courses", "error", err)
+		slog.Error("Failed to sync data", "error", err)
 		return mf.ImmediateMessage("⚠️ Failed to sync data. Please try again later.")
 	}
-	return mf.ImmediateMessage(fmt.Sprintf("Data synced successfully.\nNext update time is: %s", h.CoursesRepo.LastTimeParsed.Format("15:04:05 02.01.2006")))
+	return mf.ImmediateMessage(fmt.Sprintf("Data synced successfully, subscribers are being notified.\nNext update time is: %s", h.Refresher.NextRefresh().Format("15:04:05 02.01.2006")))
+}
+
+func (h *MessageHandler) predictionstat(cmd *tapi.Message) []tapi.Chattable {
+	mf := telegramfmt.NewMessageFormatter(cmd.From.ID)
+
+	const period = 30 * 24 * time.Hour
+	acc, err := h.Predictor.Accuracy(h.Clock.Now().Add(-period))
+	if err != nil {
+		slog.Error("Failed to get prediction accuracy", "error", err)
+		return mf.ImmediateMessage("⚠️ Failed to get prediction accuracy.\n" + err.Error())
+	}
+	if acc.Resolved+acc.Missed == 0 {
+		return mf.ImmediateMessage("No predictions came due in the last 30 days.")
+	}
+
+	return mf.ImmediateMessage(fmt.Sprintf("📈 <b>Fill predictions for the last 30 days</b>\n"+
+		"Came true: %d\nMissed: %d\nOff by less than an hour: %d of %d\nMean error: %s\nMedian error: %s",
+		acc.Resolved, acc.Missed, acc.WithinHour, acc.Resolved,
+		acc.MeanError.Round(time.Minute), acc.MedianError.Round(time.Minute)))
 }
 
 func (h *MessageHandler) DownloadFile(fileID string) ([]byte, error) {
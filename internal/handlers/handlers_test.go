@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/TheTeemka/telegram_bot_cources/internal/clock"
+	"github.com/TheTeemka/telegram_bot_cources/internal/models"
+	tapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func replyTexts(msgs []tapi.Chattable) []string {
+	var texts []string
+	for _, msg := range msgs {
+		if m, ok := msg.(tapi.MessageConfig); ok {
+			texts = append(texts, m.Text)
+		}
+	}
+	return texts
+}
+
+func TestSnoozeCallback(t *testing.T) {
+	clk := clock.NewFake(time.Date(2025, 12, 17, 9, 0, 0, 0, time.UTC))
+	h := newTestHandler(t, clk, map[string]*models.Course{
+		"PHYS 161": {AbbrName: "PHYS 161", Sections: []*models.Section{{SectionName: "1L"}, {SectionName: "2L"}}},
+	})
+	assert.NoError(t, h.SubscriptionRepo.Subscribe(1, "PHYS 161", []string{"1L", "2L"}))
+
+	snoozedUntil := func(section string) time.Time {
+		subs, err := h.SubscriptionRepo.GetSubscriptions(1)
+		assert.NoError(t, err)
+		for _, sub := range subs {
+			if sub.Section == section {
+				return sub.SnoozedUntil
+			}
+		}
+		t.Fatalf("no subscription to %s", section)
+		return time.Time{}
+	}
+
+	assert.Equal(t, []string{"💤 <b>PHYS 161 (1L)</b> is snoozed till 20:00 17.12"}, replyTexts(tap(h, 1, "snooze_PHYS 161_1L_6h")))
+	assert.True(t, clk.Now().Add(6*time.Hour).Equal(snoozedUntil("1L")), "snoozed till %s", snoozedUntil("1L"))
+	assert.True(t, snoozedUntil("2L").IsZero(), "other sections are not snoozed")
+
+	assert.Empty(t, replyTexts(tap(h, 1, "snooze_PHYS 161_2L_48h")), "snoozes are at most a day long")
+	assert.Empty(t, replyTexts(tap(h, 1, "snooze_PHYS 161_2L_-1h")))
+	assert.Empty(t, replyTexts(tap(h, 1, "snooze_PHYS 161_2L")))
+	assert.True(t, snoozedUntil("2L").IsZero())
+
+	assert.Equal(t, []string{"⚠️ You are not subscribed to <b>PHYS 161 (3L)</b>"}, replyTexts(tap(h, 1, "snooze_PHYS 161_3L_1h")))
+	assert.Equal(t, []string{"⚠️ You are not subscribed to <b>PHYS 161 (1L)</b>"}, replyTexts(tap(h, 2, "snooze_PHYS 161_1L_1h")),
+		"a user can't snooze subscriptions of others")
+}
@@ -8,9 +8,11 @@ import (
 
 type handler func(msg *tapi.Message) []tapi.Chattable
 
+// AuthAdmin lets only members of the group through, everyone else gets no reply.
+// An empty group lets nobody through
 func AuthAdmin(authGroup []int64, next handler) handler {
 	return func(msg *tapi.Message) []tapi.Chattable {
-		if len(authGroup) == 0 && slices.Contains(authGroup, msg.From.ID) {
+		if !slices.Contains(authGroup, msg.From.ID) {
 			return nil
 		}
 
@@ -18,9 +20,10 @@ func AuthAdmin(authGroup []int64, next handler) handler {
 	}
 }
 
+// AuthAllowed lets only members of the group through, an empty group lets everyone through
 func AuthAllowed(allowedGroup []int64, next handler) handler {
 	return func(msg *tapi.Message) []tapi.Chattable {
-		if len(allowedGroup) == 0 && slices.Contains(allowedGroup, msg.From.ID) {
+		if len(allowedGroup) != 0 && !slices.Contains(allowedGroup, msg.From.ID) {
 			return nil
 		}
 
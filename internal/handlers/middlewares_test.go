@@ -0,0 +1,27 @@
+package handlers
+
+import (
+	"testing"
+
+	tapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuthMiddlewares(t *testing.T) {
+	reached := func(mw func([]int64, handler) handler, group []int64, userID int64) bool {
+		var ok bool
+		mw(group, func(*tapi.Message) []tapi.Chattable {
+			ok = true
+			return nil
+		})(&tapi.Message{From: &tapi.User{ID: userID}})
+		return ok
+	}
+
+	assert.True(t, reached(AuthAdmin, []int64{1, 2}, 2))
+	assert.False(t, reached(AuthAdmin, []int64{1, 2}, 3), "strangers can't run admin commands")
+	assert.False(t, reached(AuthAdmin, nil, 3), "without admins nobody can run admin commands")
+
+	assert.True(t, reached(AuthAllowed, []int64{1, 2}, 2))
+	assert.False(t, reached(AuthAllowed, []int64{1, 2}, 3))
+	assert.True(t, reached(AuthAllowed, nil, 3), "without a list everyone is allowed")
+}
@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/TheTeemka/telegram_bot_cources/internal/telegramfmt"
+	tapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const notificationLogLimit = 20
+
+// HandleNotificationLog shows when the user was notified about free seats and how many users
+// competed for each of them, so anyone can check the order is fair
+func (h *MessageHandler) HandleNotificationLog(cmd *tapi.Message) []tapi.Chattable {
+	mf := telegramfmt.NewMessageFormatter(cmd.From.ID)
+
+	entries, err := h.QueueRepo.GetLog(cmd.From.ID, notificationLogLimit)
+	if err != nil {
+		slog.Error("Failed to get notification log", "error", err, "user_id", cmd.From.ID)
+		return mf.ImmediateMessage("⚠️ Failed to retrieve your notifications. Please try again later.")
+	}
+	if len(entries) == 0 {
+		return mf.ImmediateMessage("You haven't been notified about free seats yet.")
+	}
+
+	location := time.FixedZone("UTC+5", 5*60*60)
+	var sb strings.Builder
+	sb.WriteString("🔔 <b>Your latest free seat notifications</b>\n\n")
+	for _, e := range entries {
+		delivered := fmt.Sprintf("pending, %d notified", e.Recipients)
+		if e.DeliveredRank != 0 {
+			delivered = fmt.Sprintf("#%d of %d at %s", e.DeliveredRank, e.Recipients, e.DeliveredAt.In(location).Format("15:04:05 02.01"))
+		}
+		sb.WriteString(fmt.Sprintf("• <b>%s</b> %s: %s\n",
+			telegramfmt.Escape(e.Course), telegramfmt.Escape(e.Section), delivered))
+	}
+	sb.WriteString("\n<i>" + describeFairness(h.FairnessPolicy) + ". Your place is counted by the time you actually got the notification, quiet hours and digests put you later</i>")
+
+	return mf.ImmediateMessage(sb.String())
+}
@@ -0,0 +1,197 @@
+package handlers
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/TheTeemka/telegram_bot_cources/internal/models"
+	"github.com/TheTeemka/telegram_bot_cources/internal/telegramfmt"
+	tapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+type Role int
+
+const (
+	RoleUser Role = iota
+	RoleAdmin
+)
+
+// Command is everything the bot knows about a command. Commands are registered once in
+// commands(), the command menu, /help and the FAQ are generated from them
+type Command struct {
+	Name        string
+	Description string
+	Args        []Arg
+	// Hidden commands are listed in /help but kept out of the command menu
+	Hidden bool
+	Role   Role
+	// Dialog is started by the command, Prompt asks for the answer to its first step
+	Dialog models.StateName
+	Prompt string
+	// KeepState leaves the ongoing dialog alone, other commands cancel it
+	KeepState bool
+	Handle    handler
+}
+
+// Arg is an argument of a command in the order it is typed
+type Arg struct {
+	Name string
+	// Choices are the keywords the argument must be one of, any text when empty
+	Choices  []string
+	Optional bool
+	// Repeated takes one or more words, like sections
+	Repeated bool
+	// MaxWords is how many words a single argument may span, one when zero
+	MaxWords int
+}
+
+func (a Arg) String() string {
+	s := a.Name
+	if len(a.Choices) != 0 {
+		s = strings.Join(a.Choices, "|")
+	}
+	if a.Repeated {
+		s += "..."
+	}
+	if a.Optional {
+		s += "?"
+	}
+	return "[" + s + "]"
+}
+
+// validateArgs checks the argument schema can be typed unambiguously
+func (c *Command) validateArgs() error {
+	names := make(map[string]bool, len(c.Args))
+	for i, a := range c.Args {
+		if a.Name == "" || strings.ContainsAny(a.Name, "[]|?") {
+			return fmt.Errorf("argument %d: invalid name %q", i+1, a.Name)
+		}
+		if names[a.Name] {
+			return fmt.Errorf("argument %q is declared twice", a.Name)
+		}
+		names[a.Name] = true
+		if slices.Contains(a.Choices, "") {
+			return fmt.Errorf("argument %q: empty choice", a.Name)
+		}
+		if a.MaxWords < 0 || a.MaxWords > 1 && (a.Repeated || len(a.Choices) != 0) {
+			return fmt.Errorf("argument %q: invalid word count %d", a.Name, a.MaxWords)
+		}
+		// words between two repeated arguments may belong to either of them
+		if i > 0 && a.Repeated && c.Args[i-1].Repeated {
+			return fmt.Errorf("repeated arguments %q and %q follow each other", c.Args[i-1].Name, a.Name)
+		}
+	}
+	return nil
+}
+
+// Accepts tells whether the words typed after the command fit its arguments. It only
+// checks the shape of the input, the dialog steps parse the values themselves
+func (c *Command) Accepts(words []string) bool {
+	return matchArgs(c.Args, words)
+}
+
+// matchArgs tries every way to split the words between the arguments
+func matchArgs(args []Arg, words []string) bool {
+	if len(args) == 0 {
+		return len(words) == 0
+	}
+	a := args[0]
+	if a.Optional && matchArgs(args[1:], words) {
+		return true
+	}
+	most := max(a.MaxWords, 1)
+	if a.Repeated {
+		most = len(words)
+	}
+	for n := 1; n <= most && n <= len(words); n++ {
+		if len(a.Choices) != 0 && !slices.ContainsFunc(a.Choices, func(choice string) bool {
+			return strings.EqualFold(choice, words[n-1])
+		}) {
+			break
+		}
+		if matchArgs(args[1:], words[n:]) {
+			return true
+		}
+	}
+	return false
+}
+
+// Usage reads as "/subscribe [Course Name] [Course Sections...?]"
+func (c *Command) Usage() string {
+	parts := []string{"/" + c.Name}
+	for _, a := range c.Args {
+		parts = append(parts, a.String())
+	}
+	return strings.Join(parts, " ")
+}
+
+type Router struct {
+	commands []*Command
+	byName   map[string]*Command
+}
+
+func NewRouter(commands []*Command) *Router {
+	r := &Router{
+		commands: commands,
+		byName:   make(map[string]*Command, len(commands)),
+	}
+	for _, c := range commands {
+		if _, ok := r.byName[c.Name]; ok {
+			panic("command registered twice: " + c.Name)
+		}
+		if (c.Handle == nil) == (c.Dialog == models.StateIdle) {
+			panic("command needs either a handler or a dialog: " + c.Name)
+		}
+		if err := c.validateArgs(); err != nil {
+			panic(fmt.Sprintf("command %s: %s", c.Name, err))
+		}
+		r.byName[c.Name] = c
+	}
+	return r
+}
+
+func (r *Router) Lookup(name string) (*Command, bool) {
+	c, ok := r.byName[name]
+	return c, ok
+}
+
+// Commands lists the commands available to the role in the order they were registered
+func (r *Router) Commands(role Role) []*Command {
+	var res []*Command
+	for _, c := range r.commands {
+		if c.Role <= role {
+			res = append(res, c)
+		}
+	}
+	return res
+}
+
+// Menu is the command menu of the role, hidden commands are left out
+func (r *Router) Menu(role Role) []tapi.BotCommand {
+	var res []tapi.BotCommand
+	for _, c := range r.Commands(role) {
+		if !c.Hidden {
+			res = append(res, tapi.BotCommand{Command: c.Name, Description: c.Description})
+		}
+	}
+	return res
+}
+
+// Help lists the commands of the role with their arguments
+func (r *Router) Help(role Role) string {
+	var sb strings.Builder
+	sb.WriteString("<b>📖 Commands</b>\n")
+	for _, c := range r.Commands(RoleUser) {
+		sb.WriteString(fmt.Sprintf("<code>%s</code> - %s\n", telegramfmt.Escape(c.Usage()), c.Description))
+	}
+	if role == RoleAdmin {
+		sb.WriteString("\n<b>🔧 Admin commands</b>\n")
+		for _, c := range r.Commands(RoleAdmin) {
+			if c.Role == RoleAdmin {
+				sb.WriteString(fmt.Sprintf("<code>%s</code> - %s\n", telegramfmt.Escape(c.Usage()), c.Description))
+			}
+		}
+	}
+	return sb.String()
+}
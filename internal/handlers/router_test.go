@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"strings"
+	"testing"
+
+	tapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRouter(t *testing.T) {
+	noop := func(msg *tapi.Message) []tapi.Chattable { return nil }
+	r := NewRouter([]*Command{
+		{Name: "list", Description: "List your subscriptions", Handle: noop},
+		{Name: "donate", Description: "Donate to the bot", Hidden: true, Handle: noop},
+		{Name: "delwindow", Description: "Remove a registration window", Args: []Arg{{Name: "number"}}, Role: RoleAdmin, Handle: noop},
+	})
+
+	assert.Equal(t, []tapi.BotCommand{{Command: "list", Description: "List your subscriptions"}}, r.Menu(RoleUser))
+	assert.Len(t, r.Menu(RoleAdmin), 2)
+
+	assert.Contains(t, r.Help(RoleUser), "/donate")
+	assert.NotContains(t, r.Help(RoleUser), "/delwindow")
+	assert.Contains(t, r.Help(RoleAdmin), "/delwindow [number]")
+
+	assert.Panics(t, func() {
+		NewRouter([]*Command{{Name: "list", Handle: noop}, {Name: "list", Handle: noop}})
+	})
+	assert.Panics(t, func() {
+		NewRouter([]*Command{{Name: "bundle", Handle: noop, Args: []Arg{
+			{Name: "Course Sections", Repeated: true}, {Name: "Other Sections", Repeated: true},
+		}}})
+	}, "repeated arguments can't be told apart")
+	assert.Panics(t, func() {
+		NewRouter([]*Command{{Name: "settings", Handle: noop, Args: []Arg{{Name: "quiet|digest"}}}})
+	}, "keywords are choices")
+}
+
+func TestCommandUsage(t *testing.T) {
+	c := &Command{Name: "bundle", Args: []Arg{
+		{Name: "Course Name"},
+		{Name: "Course Sections", Repeated: true},
+		{Name: "partial", Choices: []string{"partial"}, Optional: true},
+	}}
+	assert.Equal(t, "/bundle [Course Name] [Course Sections...] [partial?]", c.Usage())
+	assert.Equal(t, "/list", (&Command{Name: "list"}).Usage())
+
+	h := &MessageHandler{}
+	assert.NotPanics(t, func() { NewRouter(h.commands()) })
+}
+
+func TestCommandAccepts(t *testing.T) {
+	h := &MessageHandler{}
+	r := NewRouter(h.commands())
+
+	tests := []struct {
+		input string
+		want  bool
+	}{
+		{"/unsubscribe PHYS161", true},
+		{"/unsubscribe PHYS 161", true},
+		{"/unsubscribe PHYS 161 1L", false},
+		{"/subscribe PHYS 161", true},
+		{"/subscribe PHYS 161 1L 2L 3L", true},
+		{"/bundle PHYS 161 1L 2R Partial", true},
+		{"/bundle PHYS161", false},
+		{"/urgent PHYS 161 off", true},
+		{"/expire PHYS161", false},
+		{"/expire PHYS 161 1L 3d", true},
+		{"/settings quiet 23:00-08:00", true},
+		{"/settings sound on", false},
+	}
+	for _, tt := range tests {
+		name, args, _ := strings.Cut(strings.TrimPrefix(tt.input, "/"), " ")
+		c, ok := r.Lookup(name)
+		if assert.True(t, ok, name) {
+			assert.Equal(t, tt.want, c.Accepts(strings.Fields(args)), tt.input)
+		}
+	}
+}
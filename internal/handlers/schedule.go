@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/TheTeemka/telegram_bot_cources/internal/telegramfmt"
+	tapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// HandleSchedule shows upcoming registration windows and when the catalog is refreshed next
+func (h *MessageHandler) HandleSchedule(cmd *tapi.Message) []tapi.Chattable {
+	mf := telegramfmt.NewMessageFormatter(cmd.From.ID)
+
+	location := time.FixedZone("UTC+5", 5*60*60)
+	now := h.Clock.Now()
+
+	var sb strings.Builder
+	sb.WriteString("🗓 <b>Upcoming registration windows</b>\n")
+	upcoming := 0
+	for _, w := range h.Schedule.Windows() {
+		if !w.Till.After(now) {
+			continue
+		}
+		upcoming++
+		sb.WriteString(fmt.Sprintf("• %s: %s <i>(in %s)</i>\n",
+			w.Till.In(location).Format("15:04 02.01"), telegramfmt.Escape(w.Label), formatCountdown(w.Till.Sub(now))))
+	}
+	if upcoming == 0 {
+		sb.WriteString("<i>No registration windows ahead</i>\n")
+	}
+
+	next := h.Refresher.NextRefresh()
+	interval, reason := h.Refresher.PollingInterval()
+	sb.WriteString(fmt.Sprintf("\n🔄 Course data is updated %s <i>(%s)</i>\n", formatEvery(interval), telegramfmt.Escape(reason)))
+	sb.WriteString(fmt.Sprintf("Next update at %s <i>(in %s)</i>", next.In(location).Format("15:04:05"), formatCountdown(next.Sub(now))))
+
+	return mf.ImmediateMessage(sb.String())
+}
+
+func formatCountdown(d time.Duration) string {
+	if d < time.Minute {
+		return "less than a minute"
+	}
+	if d >= 48*time.Hour {
+		return fmt.Sprintf("%d days", int(d/(24*time.Hour)))
+	}
+	return formatSpan(d.Truncate(time.Minute))
+}
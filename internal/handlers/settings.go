@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+
+	"github.com/TheTeemka/telegram_bot_cources/internal/models"
+	"github.com/TheTeemka/telegram_bot_cources/internal/telegramfmt"
+	tapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const settingsUsage = "<b>Usage:</b>\n" +
+	"• <code>/settings quiet 23:00-08:00</code> hold notifications during these hours\n" +
+	"• <code>/settings quiet off</code>\n" +
+	"• <code>/settings digest 20:00</code> get all notifications once a day\n" +
+	"• <code>/settings digest off</code>\n" +
+	"• <code>/settings year 3</code> get reminders before registration windows of your year open\n" +
+	"• <code>/settings year off</code>\n\n" +
+	"<i>Times are in UTC+5. Urgent sections (/urgent) are always notified instantly.</i>"
+
+func (h *MessageHandler) HandleSettings(cmd *tapi.Message) []tapi.Chattable {
+	mf := telegramfmt.NewMessageFormatter(cmd.From.ID)
+
+	args := strings.Fields(cmd.CommandArguments())
+	if len(args) == 0 {
+		settings, err := h.SettingsRepo.Get(cmd.From.ID)
+		if err != nil {
+			slog.Error("Failed to get user settings", "error", err, "user_id", cmd.From.ID)
+			return mf.ImmediateMessage("⚠️ Failed to retrieve your settings. Please try again later.")
+		}
+		return mf.ImmediateMessage(formatSettings(settings) + "\n" + settingsUsage)
+	}
+	if len(args) != 2 {
+		return mf.ImmediateMessage("❌ You haven't provided valid parameters for the command.\n\n" + settingsUsage)
+	}
+
+	switch strings.ToLower(args[0]) {
+	case "quiet":
+		return h.setQuietHours(cmd.From.ID, args[1])
+	case "digest":
+		return h.setDigestTime(cmd.From.ID, args[1])
+	case "year":
+		return h.setStudyYear(cmd.From.ID, args[1])
+	default:
+		return mf.ImmediateMessage("❌ Unknown setting " + telegramfmt.Escape(args[0]) + "\n\n" + settingsUsage)
+	}
+}
+
+func (h *MessageHandler) setQuietHours(userID int64, arg string) []tapi.Chattable {
+	mf := telegramfmt.NewMessageFormatter(userID)
+
+	var from, till *models.DayTime
+	if !strings.EqualFold(arg, "off") {
+		bounds := strings.Split(arg, "-")
+		if len(bounds) != 2 {
+			return mf.ImmediateMessage("❌ Quiet hours should look like <code>23:00-08:00</code>")
+		}
+		f, err := models.ParseDayTime(bounds[0])
+		if err != nil {
+			return mf.ImmediateMessage("❌ Quiet hours should look like <code>23:00-08:00</code>")
+		}
+		t, err := models.ParseDayTime(bounds[1])
+		if err != nil || f == t {
+			return mf.ImmediateMessage("❌ Quiet hours should look like <code>23:00-08:00</code>")
+		}
+		from, till = &f, &t
+	}
+
+	err := h.SettingsRepo.SetQuietHours(userID, from, till)
+	if err != nil {
+		slog.Error("Failed to set quiet hours", "error", err, "user_id", userID)
+		return mf.ImmediateMessage("⚠️ Failed to change the setting. Please try again.")
+	}
+
+	if from == nil {
+		return mf.ImmediateMessage("🔔 Quiet hours are turned off")
+	}
+	return mf.ImmediateMessage(fmt.Sprintf("🌙 Notifications will be held from %s till %s and delivered as one digest", from, till))
+}
+
+func (h *MessageHandler) setDigestTime(userID int64, arg string) []tapi.Chattable {
+	mf := telegramfmt.NewMessageFormatter(userID)
+
+	var at *models.DayTime
+	if !strings.EqualFold(arg, "off") {
+		t, err := models.ParseDayTime(arg)
+		if err != nil {
+			return mf.ImmediateMessage("❌ Digest time should look like <code>20:00</code>")
+		}
+		at = &t
+	}
+
+	err := h.SettingsRepo.SetDigestTime(userID, at)
+	if err != nil {
+		slog.Error("Failed to set digest time", "error", err, "user_id", userID)
+		return mf.ImmediateMessage("⚠️ Failed to change the setting. Please try again.")
+	}
+
+	if at == nil {
+		return mf.ImmediateMessage("🔔 You will get notifications instantly")
+	}
+	return mf.ImmediateMessage(fmt.Sprintf("🗓 You will get all notifications once a day at %s", at))
+}
+
+func (h *MessageHandler) setStudyYear(userID int64, arg string) []tapi.Chattable {
+	mf := telegramfmt.NewMessageFormatter(userID)
+
+	year := 0
+	if !strings.EqualFold(arg, "off") {
+		y, err := strconv.Atoi(arg)
+		if err != nil || y < 1 || y > models.MaxStudyYear {
+			return mf.ImmediateMessage(fmt.Sprintf("❌ Year should be a number from 1 to %d", models.MaxStudyYear))
+		}
+		year = y
+	}
+
+	err := h.SettingsRepo.SetStudyYear(userID, year)
+	if err != nil {
+		slog.Error("Failed to set study year", "error", err, "user_id", userID)
+		return mf.ImmediateMessage("⚠️ Failed to change the setting. Please try again.")
+	}
+	if err := h.Reminders.Plan(userID); err != nil {
+		slog.Error("Failed to plan reminders", "error", err, "user_id", userID)
+	}
+
+	if year == 0 {
+		return mf.ImmediateMessage("🔕 You won't get reminders about registration windows")
+	}
+	return mf.ImmediateMessage(fmt.Sprintf("⏰ You will be reminded a day, an hour and 10 minutes before registration windows for %d UG", year))
+}
+
+func formatSettings(settings *models.UserSettings) string {
+	var sb strings.Builder
+	sb.WriteString("<b>⚙️ Your settings</b>\n")
+	if settings.HasQuietHours() {
+		sb.WriteString(fmt.Sprintf("🌙 Quiet hours: %s-%s\n", settings.QuietFrom, settings.QuietTill))
+	} else {
+		sb.WriteString("🌙 Quiet hours: off\n")
+	}
+	if settings.DigestAt != nil {
+		sb.WriteString(fmt.Sprintf("🗓 Daily digest: %s\n", settings.DigestAt))
+	} else {
+		sb.WriteString("🗓 Daily digest: off (instant notifications)\n")
+	}
+	if settings.StudyYear != 0 {
+		sb.WriteString(fmt.Sprintf("🎓 Study year: %d UG (registration reminders on)\n", settings.StudyYear))
+	} else {
+		sb.WriteString("🎓 Study year: not set (no registration reminders)\n")
+	}
+	return sb.String()
+}
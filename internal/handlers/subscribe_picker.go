@@ -0,0 +1,178 @@
+package handlers
+
+import (
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+
+	"github.com/TheTeemka/telegram_bot_cources/internal/models"
+	"github.com/TheTeemka/telegram_bot_cources/internal/telegramfmt"
+	tapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// pickerSearchLimit keeps the list of found courses short enough to tap through
+const pickerSearchLimit = 8
+
+// subscribeSelection is the payload of /subscribe while the user picks sections
+type subscribeSelection struct {
+	Course   string   `json:"course"`
+	Sections []string `json:"sections,omitempty"`
+}
+
+// subscribeCourse is the first step of /subscribe. A course code opens the section picker,
+// anything else is looked up by title, while "PHYS 161 2L 1PLB" subscribes right away
+func (h *MessageHandler) subscribeCourse(msg *tapi.Message, state *models.ChatState) []tapi.Chattable {
+	mf := telegramfmt.NewMessageFormatter(msg.From.ID)
+
+	if msg.Document == nil {
+		if _, _, err := h.parseCommandArguments(msg.Text); err != nil {
+			if course, exists := h.CoursesRepo.GetCourse(telegramfmt.StandartizeCourseName(msg.Text)); exists {
+				selection := subscribeSelection{Course: course.AbbrName}
+				if err := h.goToStep(state, 1, selection); err != nil {
+					slog.Error("Failed to open section picker", "error", err, "user_id", msg.From.ID, "course", course.AbbrName)
+					return mf.ImmediateMessage("⚠️ Failed to subscribe to the course. Please try again.")
+				}
+				mf.AddString(telegramfmt.FormatSectionPicker(course, nil, h.CoursesRepo.LastTimeParsed))
+				mf.AddKeyboardToLastMessage(telegramfmt.SectionPickerKeyboard(course, nil))
+				return mf.Messages()
+			}
+
+			code, title := telegramfmt.StandartizeCourseName(msg.Text), strings.TrimSpace(msg.Text)
+			if found := h.CoursesRepo.SearchCourses(code, title, pickerSearchLimit); len(found) != 0 {
+				mf.AddString(fmt.Sprintf("🔎 Courses matching <b>%s</b>:", telegramfmt.Escape(msg.Text)))
+				mf.AddKeyboardToLastMessage(telegramfmt.CoursePickerKeyboard(found))
+				return mf.Messages()
+			}
+		}
+	}
+
+	return h.answer(h.HandleSubscribe)(msg, state)
+}
+
+// handleSubscribePicker updates the course and section pickers of /subscribe in place
+func (h *MessageHandler) handleSubscribePicker(mf *telegramfmt.MessageFormatter, callback *tapi.CallbackQuery, args []string) {
+	mf.Add(tapi.NewCallback(callback.ID, ""))
+	if callback.Message == nil {
+		return
+	}
+	userID := callback.From.ID
+	edit := func(text string, keyboard [][]tapi.InlineKeyboardButton) {
+		var cfg tapi.EditMessageTextConfig
+		if keyboard == nil {
+			cfg = tapi.NewEditMessageText(userID, callback.Message.MessageID, text)
+		} else {
+			cfg = tapi.NewEditMessageTextAndMarkup(userID, callback.Message.MessageID, text, tapi.NewInlineKeyboardMarkup(keyboard...))
+		}
+		cfg.ParseMode = telegramfmt.ParseMode
+		mf.Add(cfg)
+	}
+	showPicker := func(course *models.Course, selected []string, note string) {
+		edit(note+telegramfmt.FormatSectionPicker(course, selected, h.CoursesRepo.LastTimeParsed),
+			telegramfmt.SectionPickerKeyboard(course, selected))
+	}
+
+	state, err := h.StateRepo.Get(userID)
+	if err != nil {
+		slog.Error("Failed to get state for user", "user_id", userID, "error", err)
+		edit("⚠️ Failed to retrieve your state. Please try again later.", nil)
+		return
+	}
+
+	if args[0] == "abort" {
+		if !state.Idle() {
+			if err := h.leaveDialog(state); err != nil {
+				slog.Error("Failed to cancel dialog", "error", err, "user_id", userID, "state", state.Name)
+			}
+		}
+		edit("✖ Subscription is cancelled", nil)
+		return
+	}
+
+	if len(args) < 2 {
+		slog.Error("Invalid picker command format", "command", strings.Join(args, "_"))
+		return
+	}
+	course, exists := h.CoursesRepo.GetCourse(args[1])
+	if !exists {
+		edit(fmt.Sprintf("❌ Course <b>%s</b> not found", telegramfmt.Escape(args[1])), nil)
+		return
+	}
+
+	if args[0] == "pick" {
+		// the course list may outlive the dialog, picking a course starts it again
+		if state.Idle() || state.Name != models.StateSubscribe {
+			if !state.Idle() {
+				if err := h.leaveDialog(state); err != nil {
+					slog.Error("Failed to leave dialog", "error", err, "user_id", userID, "state", state.Name)
+				}
+			}
+			if state, err = h.enterDialog(userID, models.StateSubscribe); err != nil {
+				slog.Error("Failed to start dialog", "error", err, "user_id", userID)
+				edit("⚠️ Failed to subscribe to the course. Please try again.", nil)
+				return
+			}
+		}
+		if err := h.goToStep(state, 1, subscribeSelection{Course: course.AbbrName}); err != nil {
+			slog.Error("Failed to open section picker", "error", err, "user_id", userID, "course", course.AbbrName)
+			edit("⚠️ Failed to subscribe to the course. Please try again.", nil)
+			return
+		}
+		showPicker(course, nil, "")
+		return
+	}
+
+	var selection subscribeSelection
+	if state.Idle() || state.Name != models.StateSubscribe || state.Step != 1 {
+		edit("⌛ This selection has expired. Call /subscribe to pick sections again", nil)
+		return
+	}
+	if err := state.DecodePayload(&selection); err != nil || selection.Course != course.AbbrName {
+		edit("⌛ This selection has expired. Call /subscribe to pick sections again", nil)
+		return
+	}
+
+	switch args[0] {
+	case "toggle":
+		if len(args) != 3 {
+			slog.Error("Invalid toggle command format", "command", strings.Join(args, "_"))
+			return
+		}
+		if i := slices.Index(selection.Sections, args[2]); i >= 0 {
+			selection.Sections = slices.Delete(selection.Sections, i, i+1)
+		} else if _, exists := h.CoursesRepo.GetSection(course.AbbrName, args[2]); exists {
+			selection.Sections = append(selection.Sections, args[2])
+		}
+		if err := h.goToStep(state, 1, selection); err != nil {
+			slog.Error("Failed to save section selection", "error", err, "user_id", userID, "course", course.AbbrName)
+			edit("⚠️ Failed to subscribe to the course. Please try again.", nil)
+			return
+		}
+		showPicker(course, selection.Sections, "")
+
+	case "confirm":
+		if len(selection.Sections) == 0 {
+			showPicker(course, nil, "❗ Pick at least one section\n\n")
+			return
+		}
+		if valid, sect := h.CoursesRepo.CheckForValidness(course.AbbrName, selection.Sections); !valid {
+			selection.Sections = slices.DeleteFunc(selection.Sections, func(s string) bool { return s == sect })
+			if err := h.goToStep(state, 1, selection); err != nil {
+				slog.Error("Failed to save section selection", "error", err, "user_id", userID, "course", course.AbbrName)
+			}
+			showPicker(course, selection.Sections, fmt.Sprintf("❗ Section <b>%s</b> is gone from the catalog\n\n", telegramfmt.Escape(sect)))
+			return
+		}
+
+		if err := h.SubscriptionRepo.Subscribe(userID, course.AbbrName, selection.Sections); err != nil {
+			slog.Error("Failed to subscribe", "error", err, "user_id", userID, "course", course.AbbrName)
+			showPicker(course, selection.Sections, "⚠️ Failed to subscribe to the course. Please try again.\n\n")
+			return
+		}
+		if err := h.leaveDialog(state); err != nil {
+			slog.Error("Failed to leave dialog", "error", err, "user_id", userID, "state", state.Name)
+		}
+		edit(fmt.Sprintf("✅ Successfully subscribed to <b>%s (%s)</b>",
+			telegramfmt.Escape(course.AbbrName), telegramfmt.Escape(strings.Join(selection.Sections, ", "))), nil)
+	}
+}
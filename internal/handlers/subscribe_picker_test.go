@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/TheTeemka/telegram_bot_cources/internal/clock"
+	"github.com/TheTeemka/telegram_bot_cources/internal/models"
+	tapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func newPickerTest(t *testing.T) (*MessageHandler, *clock.Fake) {
+	clk := clock.NewFake(time.Date(2025, 12, 17, 9, 0, 0, 0, time.UTC))
+	physics := func() *models.Course {
+		return &models.Course{AbbrName: "PHYS 161", FullName: "Physics I for Scientists and Engineers", Sections: []*models.Section{
+			{SectionName: "1L", Size: 50, Cap: 50},
+			{SectionName: "2L", Size: 12, Cap: 50},
+		}}
+	}
+	// the parser keeps a copy of cross-listed courses under every code
+	turkish := func() *models.Course {
+		return &models.Course{AbbrName: "TUR 280/LING 280", FullName: "Turkish Linguistics", Sections: []*models.Section{
+			{SectionName: "1L", Size: 3, Cap: 20},
+		}}
+	}
+	return newTestHandler(t, clk, map[string]*models.Course{
+		"PHYS 161":         physics(),
+		"TUR 280":          turkish(),
+		"LING 280":         turkish(),
+		"TUR 280/LING 280": turkish(),
+	}), clk
+}
+
+func tap(h *MessageHandler, userID int64, data string) []tapi.Chattable {
+	return h.HandleCallback(&tapi.CallbackQuery{
+		ID:      "1",
+		From:    &tapi.User{ID: userID},
+		Message: &tapi.Message{MessageID: 10, Chat: &tapi.Chat{ID: userID}},
+		Data:    data,
+	})
+}
+
+// pickerButtons returns the data of the buttons of the last message or edit with a keyboard
+func pickerButtons(msgs []tapi.Chattable) []string {
+	var markup *tapi.InlineKeyboardMarkup
+	for _, msg := range msgs {
+		switch m := msg.(type) {
+		case tapi.MessageConfig:
+			if k, ok := m.ReplyMarkup.(tapi.InlineKeyboardMarkup); ok {
+				markup = &k
+			}
+		case tapi.EditMessageTextConfig:
+			markup = m.ReplyMarkup
+		}
+	}
+	if markup == nil {
+		return nil
+	}
+
+	var data []string
+	for _, row := range markup.InlineKeyboard {
+		for _, b := range row {
+			data = append(data, *b.CallbackData)
+		}
+	}
+	return data
+}
+
+// editedText returns the text of the last edit of the picker
+func editedText(msgs []tapi.Chattable) string {
+	var text string
+	for _, msg := range msgs {
+		if m, ok := msg.(tapi.EditMessageTextConfig); ok {
+			text = m.Text
+		}
+	}
+	return text
+}
+
+func TestSubscribePicker(t *testing.T) {
+	h, _ := newPickerTest(t)
+
+	h.HandleCommand(command(1, "/subscribe"))
+	assert.Equal(t, []string{"pick_PHYS 161", "abort"}, pickerButtons(h.HandleMessage(command(1, "physics"))))
+	assert.Equal(t, []string{"pick_TUR 280/LING 280", "abort"}, pickerButtons(h.HandleMessage(command(1, "280"))),
+		"a cross-listed course is found once")
+
+	assert.Equal(t, []string{"toggle_PHYS 161_1L", "toggle_PHYS 161_2L", "confirm_PHYS 161", "abort"},
+		pickerButtons(tap(h, 1, "pick_PHYS 161")))
+	tap(h, 1, "toggle_PHYS 161_2L")
+	tap(h, 1, "toggle_PHYS 161_1L")
+	assert.Contains(t, editedText(tap(h, 1, "toggle_PHYS 161_1L")), "Selected: <b>2L</b>")
+	assert.Contains(t, editedText(tap(h, 1, "toggle_PHYS 161_3L")), "Selected: <b>2L</b>", "unknown sections aren't picked")
+
+	assert.Contains(t, editedText(tap(h, 1, "confirm_PHYS 161")), "Successfully subscribed to <b>PHYS 161 (2L)</b>")
+	subs, err := h.SubscriptionRepo.GetSubscriptions(1)
+	assert.NoError(t, err)
+	if assert.Len(t, subs, 1) {
+		assert.Equal(t, "2L", subs[0].Section)
+	}
+	state, err := h.StateRepo.Get(1)
+	assert.NoError(t, err)
+	assert.True(t, state.Idle())
+
+	assert.Contains(t, editedText(tap(h, 1, "toggle_PHYS 161_1L")), "This selection has expired",
+		"the picker is done after confirmation")
+}
+
+func TestSubscribePickerConfirmNothing(t *testing.T) {
+	h, _ := newPickerTest(t)
+
+	tap(h, 1, "pick_TUR 280")
+	assert.Contains(t, editedText(tap(h, 1, "confirm_TUR 280")), "Pick at least one section")
+	tap(h, 1, "toggle_LING 280_1L")
+	assert.Contains(t, editedText(tap(h, 1, "confirm_TUR 280/LING 280")), "Successfully subscribed to <b>TUR 280/LING 280 (1L)</b>",
+		"a cross-listed course is the same under every code")
+}
+
+func TestSubscribePickerExpired(t *testing.T) {
+	h, clk := newPickerTest(t)
+
+	tap(h, 1, "pick_PHYS 161")
+	tap(h, 1, "toggle_PHYS 161_2L")
+	clk.Advance(dialogTTL)
+	assert.Contains(t, editedText(tap(h, 1, "confirm_PHYS 161")), "This selection has expired")
+
+	tap(h, 1, "pick_PHYS 161")
+	assert.Contains(t, editedText(tap(h, 1, "confirm_TUR 280")), "This selection has expired",
+		"a picker of another course is outdated")
+
+	subs, err := h.SubscriptionRepo.GetSubscriptions(1)
+	assert.NoError(t, err)
+	assert.Empty(t, subs)
+}
+
+func TestSubscribePickerAbort(t *testing.T) {
+	h, _ := newPickerTest(t)
+
+	h.HandleCommand(command(1, "/subscribe"))
+	tap(h, 1, "pick_PHYS 161")
+	tap(h, 1, "toggle_PHYS 161_2L")
+	assert.Contains(t, editedText(tap(h, 1, "abort")), "Subscription is cancelled")
+
+	state, err := h.StateRepo.Get(1)
+	assert.NoError(t, err)
+	assert.True(t, state.Idle())
+	assert.Contains(t, editedText(tap(h, 1, "confirm_PHYS 161")), "This selection has expired")
+
+	subs, err := h.SubscriptionRepo.GetSubscriptions(1)
+	assert.NoError(t, err)
+	assert.Empty(t, subs)
+}
+
+func TestSubscribeUnknownCourse(t *testing.T) {
+	h, _ := newPickerTest(t)
+
+	h.HandleCommand(command(1, "/subscribe"))
+	assert.Equal(t, []string{"delete", "interest_PHSY 161;delete"}, pickerButtons(h.HandleMessage(command(1, "PHSY 161"))))
+	interests, err := h.InterestRepo.GetInterests(1)
+	assert.NoError(t, err)
+	assert.Empty(t, interests, "a typo isn't remembered without asking")
+
+	tap(h, 1, "interest_PHYS 161;delete")
+	tap(h, 1, "interest_PHSY 161;delete")
+	interests, err = h.InterestRepo.GetInterests(1)
+	assert.NoError(t, err)
+	if assert.Len(t, interests, 1) {
+		assert.Equal(t, "PHSY 161", interests[0].Course)
+	}
+}
@@ -2,19 +2,30 @@ package handlers
 
 import (
 	"fmt"
+	"strings"
+	"time"
+
+	"github.com/TheTeemka/telegram_bot_cources/internal/ticker"
 )
 
-func generateFAQText() string {
+// generateFAQText builds the FAQ for the active ramp profile, the fairness policy and the
+// polling interval of the moment
+func generateFAQText(ramp ticker.RampProfile, fairness string, interval time.Duration, reason, commands string) string {
 	return "<b>📋 Frequently Asked Questions</b>\n\n" +
 
 		"<b>🔍 Course Information</b>\n" +
 		"❓ <b>How do I check a course?</b>\n" +
 		"   Simply send a course code (e.g., <b>PHYS 161</b>, <b>CSCI 151</b>) without any command. The bot will show current enrollment and section details.\n\n" +
+		"❓ <b>How do I subscribe to sections?</b>\n" +
+		"   Use <code>/subscribe</code> and send a course code or a part of its title. Tap the sections you want to watch and press Confirm.\n\n" +
+		"❓ <b>How do I track a lecture, lab and recitation together?</b>\n" +
+		"   Use <code>/bundle</code> (e.g., <b>PHYS 161 2L 1PLB 3R</b>). You will be notified only when every section of the bundle has free places at the same time. Add <b>partial</b> at the end to also get quiet updates when only some of them are free.\n\n" +
 
 		"<b>🚨 Troubleshooting</b>\n" +
 		"❓ <b>What if a course is not found?</b>\n" +
 		"   • Check the course code spelling\n" +
 		"   • Ensure the course is offered this semester\n" +
+		"   • If it is not published yet, <code>/subscribe</code> to it anyway and tap <b>Notify me when it appears</b>\n" +
 
 		"❓ <b>What if a section is not found?</b>\n" +
 		"   • Check section naming (1L, 2PLB, 3R, etc.)\n" +
@@ -23,26 +34,55 @@ func generateFAQText() string {
 
 		"❓ <b>Bot not responding?</b>\n" +
 		"   • Wait a moment and try again\n" +
-		"   • Use <code>/start</code> to reset your session\n" +
+		"   • Use <code>/cancel</code> to stop the current command\n" +
 		"   • Check your internet connection\n\n" +
 
+		"❓ <b>Will I know if a course gets more places or new sections?</b>\n" +
+		"   • Subscribers of a course are notified whenever the capacity of its sections changes\n" +
+		"   • Use <code>/newsections</code> to also get notified when a new section of a course you are subscribed to appears\n\n" +
+
+		"❓ <b>Why do I get one message with many updates?</b>\n" +
+		"   • All changes found during a single update are grouped into one digest per user\n" +
+		"   • Use <code>/urgent</code> to get instant separate notifications for the sections that matter most\n\n" +
+
+		"❓ <b>Can I mute the bot at night?</b>\n" +
+		"   • <code>/settings quiet 23:00-08:00</code> holds notifications and delivers them as one digest when quiet hours end\n" +
+		"   • <code>/settings digest 20:00</code> delivers everything once a day instead of instantly\n" +
+		"   • Tap 💤 under a notification to snooze that section for 1 or 6 hours\n\n" +
+
+		"❓ <b>Can the bot remind me about my registration time?</b>\n" +
+		"   • <code>/settings year 3</code> reminds you a day, an hour and 10 minutes before the registration windows for your year open\n" +
+		"   • Every reminder shows the current state of the sections you watch\n\n" +
+
+		"❓ <b>Who gets notified first when a seat opens?</b>\n" +
+		"   • " + describeFairness(fairness) + "\n" +
+		"   • Users getting notifications at the same time get them in this order, quiet hours and digests hold yours till they end\n" +
+		"   • <code>/notifications</code> shows when you were notified and your place in line\n\n" +
+
+		"❓ <b>Do subscriptions last forever?</b>\n" +
+		"   • By default they do, till you unsubscribe\n" +
+		"   • If the bot is set up to expire them after registration, you get a reminder a day before, tap 👀 Keep watching to extend them for a week\n" +
+		"   • <code>/expire PHYS 161 2L 3d</code> sets your own time, <code>off</code> keeps watching till you unsubscribe\n\n" +
+
+		"❓ <b>Which courses are in demand?</b>\n" +
+		"   • <code>/trending</code> shows the most looked up courses, the most watched sections and the ones filling fastest\n\n" +
+
 		"❓ <b>Not getting notifications?</b>\n" +
 		"   • Verify your subscription with <code>/list</code>\n" +
 		"   • Ensure you haven't blocked the bot\n" +
 		"   • Notifications only come when spots open up\n\n" +
 		"❓ <b>How often does the bot update course data?</b>\n" +
 		"   • The bot uses a dynamic schedule to check for updates more frequently as registration deadlines approach.\n" +
-		"   • Default time is 3 hour frequency\n" +
-		"   • From 1 hour to 30 minutes before registration closes: updates every 30 minutes.\n" +
-		"   • From 30 to 15 minutes before: updates every 15 minutes.\n" +
-		"   • From 15 to 5 minutes before: updates every 5 minutes.\n" +
-		"   • In the last 5 minutes before registration closes and 5 minutes after: updates every minute.\n" +
-		"   • From 5 to 30 minutes after registration closes: updates every 3 minutes.\n" +
+		fmt.Sprintf("   • Right now it updates %s <i>(%s)</i>\n", formatEvery(interval), reason) +
+		describeRamp(ramp) +
+		"   • <code>/schedule</code> shows the upcoming registration windows and when the next update is\n" +
 		"   • This ensures you get the most up-to-date information when it matters most!\n\n" +
 
 		"<b>💰 Support</b>\n" +
 		"❓ <b>How can I support this bot?</b>\n" +
-		"   Use <code>/donate</code> to see donation information. Your support helps maintain the bot and improve its features.\n\n"
+		"   Use <code>/donate</code> to see donation information. Your support helps maintain the bot and improve its features.\n\n" +
+
+		commands
 }
 
 func generateWelcomeText(semester string) string {
@@ -54,6 +94,71 @@ func generateWelcomeText(semester string) string {
 			"• Available seats\n"+
 			"• Section details\n\n"+
 			"Also provides opportunity to track course status by subscription system with notifications\n\n"+
-			"<i>Updates every 60/30/15/5 minutes \n[The closer to registration the more frequent updates will be]</i>",
+			"<i>The closer to registration deadlines, the more frequent updates are. /schedule shows how often right now</i>",
 		semester)
 }
+
+// describeFairness explains the fairness policy, it is one of the policies of the service package
+func describeFairness(policy string) string {
+	switch policy {
+	case "random":
+		return "Users competing for the same seat are shuffled every time"
+	case "round-robin":
+		return "The first place moves to the next user every time a seat of the section opens"
+	default:
+		return "Whoever was notified about the section the longest ago, or never, goes first"
+	}
+}
+
+// describeRamp lists the steps of a ramp profile as FAQ bullet points
+func describeRamp(ramp ticker.RampProfile) string {
+	var sb strings.Builder
+	for _, step := range ramp.Steps {
+		var when string
+		switch {
+		case step.Till == 0:
+			when = fmt.Sprintf("In the last %s before registration opens", formatSpan(-step.From))
+		case step.Till <= 0:
+			when = fmt.Sprintf("From %s to %s before registration opens", formatSpan(-step.From), formatSpan(-step.Till))
+		case step.From == 0:
+			when = fmt.Sprintf("In the first %s after registration opens", formatSpan(step.Till))
+		case step.From >= 0:
+			when = fmt.Sprintf("From %s to %s after registration opens", formatSpan(step.From), formatSpan(step.Till))
+		default:
+			when = fmt.Sprintf("From %s before registration opens till %s after", formatSpan(-step.From), formatSpan(step.Till))
+		}
+
+		sb.WriteString(fmt.Sprintf("   • %s: updates %s.\n", when, formatEvery(step.Interval)))
+	}
+	return sb.String()
+}
+
+// formatEvery reads as "every minute", "every 3 hours" or "every 1 hour 30 minutes"
+func formatEvery(d time.Duration) string {
+	switch span := formatSpan(d); span {
+	case "1 hour", "1 minute":
+		return "every " + strings.TrimPrefix(span, "1 ")
+	default:
+		return "every " + span
+	}
+}
+
+// formatSpan spells out whole hours and minutes, like "1 hour 30 minutes"
+func formatSpan(d time.Duration) string {
+	plural := func(n int, unit string) string {
+		if n == 1 {
+			return fmt.Sprintf("%d %s", n, unit)
+		}
+		return fmt.Sprintf("%d %ss", n, unit)
+	}
+
+	hours, minutes := int(d/time.Hour), int(d%time.Hour/time.Minute)
+	switch {
+	case hours == 0:
+		return plural(minutes, "minute")
+	case minutes == 0:
+		return plural(hours, "hour")
+	default:
+		return plural(hours, "hour") + " " + plural(minutes, "minute")
+	}
+}
@@ -0,0 +1,17 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatEvery(t *testing.T) {
+	assert.Equal(t, "every minute", formatEvery(time.Minute))
+	assert.Equal(t, "every hour", formatEvery(time.Hour))
+	assert.Equal(t, "every 5 minutes", formatEvery(5*time.Minute))
+	assert.Equal(t, "every 3 hours", formatEvery(3*time.Hour))
+	assert.Equal(t, "every 1 hour 30 minutes", formatEvery(90*time.Minute))
+	assert.Equal(t, "every 2 hours 1 minute", formatEvery(121*time.Minute))
+}
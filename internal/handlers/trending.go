@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"cmp"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+
+	"github.com/TheTeemka/telegram_bot_cources/internal/telegramfmt"
+	tapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const trendingLimit = 5
+
+// HandleTrending shows the most looked up courses, the most watched sections
+// and the sections filling fastest since the last parse
+func (h *MessageHandler) HandleTrending(cmd *tapi.Message) []tapi.Chattable {
+	mf := telegramfmt.NewMessageFormatter(cmd.From.ID)
+
+	stats, err := h.StatisticsRepo.GetAll()
+	if err != nil {
+		slog.Error("Failed to get statistics", "error", err)
+		return mf.ImmediateMessage("⚠️ Failed to retrieve trending courses. Please try again later.")
+	}
+	popular, err := h.SubscriptionRepo.GetMostSubscribed(trendingLimit)
+	if err != nil {
+		slog.Error("Failed to get most subscribed sections", "error", err)
+		return mf.ImmediateMessage("⚠️ Failed to retrieve trending courses. Please try again later.")
+	}
+
+	// statistics also count commands and requests, only course lookups are interesting here
+	var lookedUp []string
+	for action := range stats {
+		if _, exists := h.CoursesRepo.GetCourse(action); exists {
+			lookedUp = append(lookedUp, action)
+		}
+	}
+	slices.SortFunc(lookedUp, func(a, b string) int {
+		if c := cmp.Compare(stats[b], stats[a]); c != 0 {
+			return c
+		}
+		return strings.Compare(a, b)
+	})
+	lookedUp = lookedUp[:min(trendingLimit, len(lookedUp))]
+
+	var sb strings.Builder
+	sb.WriteString("🔥 <b>Most looked up courses</b>\n")
+	if len(lookedUp) == 0 {
+		sb.WriteString("<i>Nothing yet</i>\n")
+	}
+	for i, course := range lookedUp {
+		sb.WriteString(fmt.Sprintf("%d. <b>%s</b>: %d lookups\n", i+1, telegramfmt.Escape(course), stats[course]))
+	}
+
+	sb.WriteString("\n👀 <b>Most watched sections</b>\n")
+	if len(popular) == 0 {
+		sb.WriteString("<i>Nothing yet</i>\n")
+	}
+	for i, p := range popular {
+		sb.WriteString(fmt.Sprintf("%d. <b>%s</b> %s: %d subscribers\n", i+1,
+			telegramfmt.Escape(p.Course), telegramfmt.Escape(p.Section), p.Subscribers))
+	}
+
+	sb.WriteString("\n📈 <b>Filling fastest since the last update</b>\n")
+	rising := h.CoursesRepo.GetLastDiff().FastestRising(trendingLimit)
+	if len(rising) == 0 {
+		sb.WriteString("<i>No enrollment changes</i>\n")
+	}
+	for i, c := range rising {
+		sb.WriteString(fmt.Sprintf("%d. <b>%s</b> %s: +%d (%d/%d)\n", i+1,
+			telegramfmt.Escape(c.Course), telegramfmt.Escape(c.Section), c.New.Size-c.Old.Size, c.New.Size, c.New.Cap))
+	}
+
+	return mf.ImmediateMessage(sb.String())
+}
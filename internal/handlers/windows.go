@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/TheTeemka/telegram_bot_cources/internal/telegramfmt"
+	"github.com/TheTeemka/telegram_bot_cources/internal/ticker"
+	tapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const windowsUsage = "<b>Usage:</b>\n" +
+	"• <code>/windows</code> list registration windows\n" +
+	"• <code>/addwindow 2026-12-17T09:00+05:00 First Priority 4UG</code> add a window ending at the given time\n" +
+	"• <code>/addwindow 2027-01-20T18:00+05:00 ramp=add-drop Add/Drop</code> poll around it with another ramp\n" +
+	"• <code>/delwindow 3</code> remove a window added with /addwindow\n\n" +
+	"<i>Times without an offset are in UTC+5. Windows from the schedule file are changed in the file.</i>"
+
+// HandleWindows lists registration windows the catalog is refreshed more often around
+func (h *MessageHandler) HandleWindows(cmd *tapi.Message) []tapi.Chattable {
+	mf := telegramfmt.NewMessageFormatter(cmd.From.ID)
+
+	windows := h.Schedule.Windows()
+	if len(windows) == 0 {
+		return mf.ImmediateMessage("No registration windows, the catalog is refreshed at the default interval.\n\n" + windowsUsage)
+	}
+
+	location := time.FixedZone("UTC+5", 5*60*60)
+	now := h.Clock.Now()
+	var sb strings.Builder
+	sb.WriteString("🗓 <b>Registration windows</b>\n\n")
+	for _, w := range windows {
+		source := "file"
+		if w.ID != 0 {
+			source = fmt.Sprintf("#%d", w.ID)
+		}
+		mark := ""
+		if w.Profile != "" {
+			mark += " <i>(" + telegramfmt.Escape(w.Profile) + ")</i>"
+		}
+		if w.Till.Before(now) {
+			mark += " ✅"
+		}
+		sb.WriteString(fmt.Sprintf("• <code>%s</code> %s: %s%s\n",
+			source, w.Till.In(location).Format("15:04 02.01.2006"), telegramfmt.Escape(w.Label), mark))
+	}
+	sb.WriteString(fmt.Sprintf("\nRamps: %s\n", telegramfmt.Escape(strings.Join(h.Schedule.ProfileNames(), ", "))))
+	sb.WriteString(fmt.Sprintf("Next update time is: %s\n\n", h.Refresher.NextRefresh().In(location).Format("15:04:05 02.01.2006")))
+	sb.WriteString(windowsUsage)
+
+	return mf.ImmediateMessage(sb.String())
+}
+
+func (h *MessageHandler) HandleAddWindow(cmd *tapi.Message) []tapi.Chattable {
+	mf := telegramfmt.NewMessageFormatter(cmd.From.ID)
+
+	args := strings.Fields(cmd.CommandArguments())
+	if len(args) < 2 {
+		return mf.ImmediateMessage("❌ You haven't provided valid parameters for the command.\n\n" + windowsUsage)
+	}
+	till, err := parseWindowTime(args[0])
+	if err != nil {
+		return mf.ImmediateMessage("❌ " + telegramfmt.Escape(err.Error()) + "\n\n" + windowsUsage)
+	}
+	candidate := ticker.TickerIntervalConfig{Till: till}
+	if profile, ok := strings.CutPrefix(args[1], "ramp="); ok {
+		candidate.Profile = profile
+		args = args[1:]
+	}
+	candidate.Label = strings.Join(args[1:], " ")
+	if candidate.Label == "" {
+		return mf.ImmediateMessage("❌ Please provide a label for the window.\n\n" + windowsUsage)
+	}
+
+	h.windowsMu.Lock()
+	defer h.windowsMu.Unlock()
+
+	if err := h.Schedule.Check(ticker.SourceAdmin, append(h.Schedule.SourceWindows(ticker.SourceAdmin), candidate)); err != nil {
+		return mf.ImmediateMessage("❌ " + telegramfmt.Escape(err.Error()))
+	}
+
+	id, err := h.WindowRepo.Add(candidate, cmd.From.ID)
+	if err != nil {
+		slog.Error("Failed to add registration window", "error", err)
+		return mf.ImmediateMessage("⚠️ Failed to add the window. Please try again later.")
+	}
+	if err := h.reloadWindows(); err != nil {
+		slog.Error("Failed to reload registration windows", "error", err)
+		return mf.ImmediateMessage("⚠️ The window is saved, but the schedule failed to reload: " + telegramfmt.Escape(err.Error()))
+	}
+
+	slog.Info("Registration window added", "id", id, "till", till, "label", candidate.Label, "ramp", candidate.Profile, "admin_id", cmd.From.ID)
+	return mf.ImmediateMessage(fmt.Sprintf("✅ Window <code>#%d</code> %s added.", id, telegramfmt.Escape(candidate.Label)))
+}
+
+func (h *MessageHandler) HandleDeleteWindow(cmd *tapi.Message) []tapi.Chattable {
+	mf := telegramfmt.NewMessageFormatter(cmd.From.ID)
+
+	id, err := strconv.ParseInt(strings.TrimPrefix(strings.TrimSpace(cmd.CommandArguments()), "#"), 10, 64)
+	if err != nil || id <= 0 {
+		return mf.ImmediateMessage("❌ Please provide the number of the window from /windows.\n\n" + windowsUsage)
+	}
+
+	h.windowsMu.Lock()
+	defer h.windowsMu.Unlock()
+
+	deleted, err := h.WindowRepo.Delete(id)
+	if err != nil {
+		slog.Error("Failed to delete registration window", "error", err, "id", id)
+		return mf.ImmediateMessage("⚠️ Failed to remove the window. Please try again later.")
+	}
+	if !deleted {
+		return mf.ImmediateMessage(fmt.Sprintf("❌ There is no window <code>#%d</code>, windows from the schedule file are changed in the file.", id))
+	}
+	if err := h.reloadWindows(); err != nil {
+		slog.Error("Failed to reload registration windows", "error", err)
+		return mf.ImmediateMessage("⚠️ The window is removed, but the schedule failed to reload: " + telegramfmt.Escape(err.Error()))
+	}
+
+	slog.Info("Registration window deleted", "id", id, "admin_id", cmd.From.ID)
+	return mf.ImmediateMessage(fmt.Sprintf("✅ Window <code>#%d</code> removed.", id))
+}
+
+// reloadWindows feeds windows saved by admins into the schedule, the ticker picks them up right away
+func (h *MessageHandler) reloadWindows() error {
+	windows, err := h.WindowRepo.GetAll()
+	if err != nil {
+		return err
+	}
+	return h.Schedule.Set(ticker.SourceAdmin, windows)
+}
+
+// parseWindowTime accepts times with an offset, times without one are in UTC+5
+func parseWindowTime(s string) (time.Time, error) {
+	for _, layout := range []string{"2006-01-02T15:04Z07:00", time.RFC3339} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	if t, err := time.ParseInLocation("2006-01-02T15:04", s, time.FixedZone("UTC+5", 5*60*60)); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("time %q should look like 2026-12-17T09:00+05:00", s)
+}
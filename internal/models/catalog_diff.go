@@ -0,0 +1,168 @@
+package models
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+)
+
+// SectionChange describes how a single section differs between two parses.
+// Old is nil for a brand-new section, New is nil for a removed one
+type SectionChange struct {
+	Course  string
+	Section string
+	Old     *Section
+	New     *Section
+}
+
+func (c SectionChange) IsNew() bool {
+	return c.Old == nil && c.New != nil
+}
+
+func (c SectionChange) IsRemoved() bool {
+	return c.Old != nil && c.New == nil
+}
+
+func (c SectionChange) CapChanged() bool {
+	return c.Old != nil && c.New != nil && c.Old.Cap != c.New.Cap
+}
+
+func (c SectionChange) SizeChanged() bool {
+	return c.Old != nil && c.New != nil && c.Old.Size != c.New.Size
+}
+
+type CatalogDiff struct {
+	// Initial is set when there was no previous catalog to compare with
+	Initial bool
+	Changes []SectionChange
+	// OldSections is the number of sections in the previous catalog
+	OldSections int
+}
+
+// SeatChanges is the number of sections whose enrollment changed
+func (d *CatalogDiff) SeatChanges() int {
+	if d == nil || d.Initial {
+		return 0
+	}
+	n := 0
+	for _, c := range d.Changes {
+		if c.SizeChanged() {
+			n++
+		}
+	}
+	return n
+}
+
+// ByCourse groups changes by course abbreviation keeping their order
+func (d *CatalogDiff) ByCourse() map[string][]SectionChange {
+	res := make(map[string][]SectionChange)
+	if d == nil {
+		return res
+	}
+	for _, c := range d.Changes {
+		res[c.Course] = append(res[c.Course], c)
+	}
+	return res
+}
+
+// ChangedSections lists changed sections per course name. Cross-listed courses are listed
+// under every name they are known by, as users may subscribe with any of them
+func (d *CatalogDiff) ChangedSections() map[string][]string {
+	res := make(map[string][]string)
+	if d == nil {
+		return res
+	}
+	for _, c := range d.Changes {
+		for _, name := range courseNames(c.Course) {
+			res[name] = append(res[name], c.Section)
+		}
+	}
+	return res
+}
+
+// courseNames mirrors how the catalog is keyed: "TUR 280/LING 280" is also "TUR 280" and "LING 280"
+func courseNames(abbr string) []string {
+	names := strings.Split(abbr, "/")
+	if len(names) > 1 {
+		names = append(names, abbr)
+	}
+	return names
+}
+
+// FastestRising returns sections whose enrollment grew the most, biggest growth first
+func (d *CatalogDiff) FastestRising(limit int) []SectionChange {
+	if d == nil || d.Initial {
+		return nil
+	}
+
+	var rising []SectionChange
+	for _, c := range d.Changes {
+		if c.SizeChanged() && c.New.Size > c.Old.Size {
+			rising = append(rising, c)
+		}
+	}
+	slices.SortStableFunc(rising, func(a, b SectionChange) int {
+		return cmp.Compare(b.New.Size-b.Old.Size, a.New.Size-a.Old.Size)
+	})
+	return rising[:min(limit, len(rising))]
+}
+
+// DiffCatalogs compares two parsed catalogs. Courses are compared by AbbrName, so
+// aliases like "TUR 280" and "LING 280" of "TUR 280/LING 280" are reported once
+func DiffCatalogs(old, new map[string]*Course) *CatalogDiff {
+	diff := &CatalogDiff{Initial: len(old) == 0}
+
+	oldCourses := canonicalCourses(old)
+	newCourses := canonicalCourses(new)
+
+	for abbr, course := range newCourses {
+		oldCourse := oldCourses[abbr]
+		for _, sect := range course.Sections {
+			var oldSect *Section
+			if oldCourse != nil {
+				oldSect = findSection(oldCourse, sect.SectionName)
+			}
+			if oldSect == nil || oldSect.Size != sect.Size || oldSect.Cap != sect.Cap {
+				diff.Changes = append(diff.Changes, SectionChange{
+					Course:  abbr,
+					Section: sect.SectionName,
+					Old:     oldSect,
+					New:     sect,
+				})
+			}
+		}
+	}
+
+	for abbr, course := range oldCourses {
+		diff.OldSections += len(course.Sections)
+		newCourse := newCourses[abbr]
+		for _, sect := range course.Sections {
+			if newCourse == nil || findSection(newCourse, sect.SectionName) == nil {
+				diff.Changes = append(diff.Changes, SectionChange{
+					Course:  abbr,
+					Section: sect.SectionName,
+					Old:     sect,
+				})
+			}
+		}
+	}
+
+	return diff
+}
+
+func canonicalCourses(courses map[string]*Course) map[string]*Course {
+	res := make(map[string]*Course, len(courses))
+	for _, c := range courses {
+		res[c.AbbrName] = c
+	}
+	return res
+}
+
+func findSection(course *Course, sectionName string) *Section {
+	for _, s := range course.Sections {
+		if s.SectionName == sectionName {
+			return s
+		}
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCatalogDiffChangedSections(t *testing.T) {
+	crossListed := &Course{AbbrName: "TUR 280/LING 280", Sections: []*Section{{SectionName: "1L", Size: 10, Cap: 20}}}
+	old := map[string]*Course{
+		"CSCI 151": {AbbrName: "CSCI 151", Sections: []*Section{
+			{SectionName: "1L", Size: 10, Cap: 20},
+			{SectionName: "2L", Size: 20, Cap: 20},
+		}},
+		"TUR 280":          crossListed,
+		"LING 280":         crossListed,
+		"TUR 280/LING 280": crossListed,
+	}
+
+	crossListed = &Course{AbbrName: "TUR 280/LING 280", Sections: []*Section{{SectionName: "1L", Size: 11, Cap: 20}}}
+	new := map[string]*Course{
+		"CSCI 151": {AbbrName: "CSCI 151", Sections: []*Section{
+			{SectionName: "1L", Size: 10, Cap: 20},
+			{SectionName: "2L", Size: 19, Cap: 20},
+		}},
+		"TUR 280":          crossListed,
+		"LING 280":         crossListed,
+		"TUR 280/LING 280": crossListed,
+	}
+
+	assert.Equal(t, map[string][]string{
+		"CSCI 151":         {"2L"},
+		"TUR 280":          {"1L"},
+		"LING 280":         {"1L"},
+		"TUR 280/LING 280": {"1L"},
+	}, DiffCatalogs(old, new).ChangedSections())
+
+	var noDiff *CatalogDiff
+	assert.Empty(t, noDiff.ChangedSections())
+}
+
+func TestCatalogDiffFastestRising(t *testing.T) {
+	diff := &CatalogDiff{Changes: []SectionChange{
+		{Course: "CSCI 151", Section: "1L", Old: &Section{Size: 10, Cap: 20}, New: &Section{Size: 12, Cap: 20}},
+		{Course: "CSCI 151", Section: "2L", Old: &Section{Size: 20, Cap: 20}, New: &Section{Size: 19, Cap: 20}},
+		{Course: "MATH 161", Section: "1L", Old: &Section{Size: 5, Cap: 30}, New: &Section{Size: 15, Cap: 30}},
+		{Course: "MATH 161", Section: "2L", New: &Section{Size: 25, Cap: 30}},
+	}}
+
+	rising := diff.FastestRising(5)
+	if assert.Len(t, rising, 2) {
+		assert.Equal(t, "MATH 161", rising[0].Course)
+		assert.Equal(t, "CSCI 151", rising[1].Course)
+	}
+	assert.Len(t, diff.FastestRising(1), 1)
+
+	var noDiff *CatalogDiff
+	assert.Empty(t, noDiff.FastestRising(5))
+}
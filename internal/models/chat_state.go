@@ -0,0 +1,59 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// StateName is the dialog a user is in, the bot treats their next message as its answer
+type StateName string
+
+const (
+	StateIdle        StateName = ""
+	StateSubscribe   StateName = "subscribe"
+	StateBundle      StateName = "bundle"
+	StateNewSections StateName = "newsections"
+	StateUrgent      StateName = "urgent"
+	StateExpire      StateName = "expire"
+	StateUnsubscribe StateName = "unsubscribe"
+)
+
+// ChatState is where a user is in a dialog. Payload carries answers of the previous steps,
+// the state is forgotten after ExpiresAt
+type ChatState struct {
+	TelegramID int64
+	Name       StateName
+	Step       int
+	Payload    json.RawMessage
+	ExpiresAt  time.Time
+}
+
+func (s *ChatState) Idle() bool {
+	return s == nil || s.Name == StateIdle
+}
+
+func (s *ChatState) Expired(now time.Time) bool {
+	return !s.ExpiresAt.After(now)
+}
+
+// SetPayload stores v as the payload of the state
+func (s *ChatState) SetPayload(v any) error {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("encoding payload of %q: %w", s.Name, err)
+	}
+	s.Payload = payload
+	return nil
+}
+
+// DecodePayload reads the payload into v, an empty payload leaves v as is
+func (s *ChatState) DecodePayload(v any) error {
+	if len(s.Payload) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(s.Payload, v); err != nil {
+		return fmt.Errorf("decoding payload of %q: %w", s.Name, err)
+	}
+	return nil
+}
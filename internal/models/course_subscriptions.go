@@ -1,8 +1,50 @@
 package models
 
+import "time"
+
 type CourseSubscription struct {
+	TelegramID   int64
+	Course       string
+	Section      string
+	IsFull       bool
+	Urgent       bool
+	SnoozedUntil time.Time // zero when the subscription is not snoozed
+	ExpiresAt    time.Time // zero when the subscription never expires
+}
+
+// Snoozed reports whether notifications about the subscription are muted at the moment
+func (s *CourseSubscription) Snoozed(now time.Time) bool {
+	return now.Before(s.SnoozedUntil)
+}
+
+type BundleState int
+
+const (
+	BundleUnavailable BundleState = iota // at least one section is full and none is free
+	BundlePartial                        // some sections are free, but not all of them
+	BundleAvailable                      // every section of the bundle is free
+	BundleBroken                         // a section of the bundle is gone from the catalog
+)
+
+// BundleSubscription fires only when every section of the set has free seats at the same time
+type BundleSubscription struct {
+	ID            int64
+	TelegramID    int64
+	Course        string
+	Sections      []string
+	State         BundleState
+	NotifyPartial bool
+}
+
+// CourseInterest is a wish to be notified once the course appears in the catalog
+type CourseInterest struct {
 	TelegramID int64
 	Course     string
-	Section    string
-	IsFull     bool
+}
+
+// SectionPopularity is the number of users subscribed to a section
+type SectionPopularity struct {
+	Course      string
+	Section     string
+	Subscribers int
 }
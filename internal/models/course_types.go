@@ -18,6 +18,11 @@ type Section struct {
 	Cap         int
 }
 
+// Component is the kind of the section, like "L" for 2L or "PLB" for 1PLB
+func (s *Section) Component() string {
+	return trimNumbersFromPrefix(s.SectionName)
+}
+
 func SortSections(sections []*Section) []*Section {
 	slices.SortFunc(sections, func(a, b *Section) int {
 		atrim, btrim := trimNumbersFromPrefix(a.SectionName), trimNumbersFromPrefix(b.SectionName)
@@ -0,0 +1,67 @@
+package models
+
+import "time"
+
+type NotificationKind int
+
+const (
+	NotificationSeat          NotificationKind = iota // section became free or full
+	NotificationSectionGone                           // subscribed section disappeared from the catalog
+	NotificationCourseGone                            // subscribed course disappeared from the catalog
+	NotificationBundle                                // bundle availability changed
+	NotificationCapacity                              // capacity of a section changed
+	NotificationNewSections                           // new sections of a course appeared
+	NotificationCourseOffered                         // awaited course appeared in the catalog
+	NotificationAdminAlert                            // something is wrong with the bot itself
+	NotificationExpiring                              // subscriptions are about to expire
+	NotificationReminder                              // registration window of the user is coming
+)
+
+// Notification is a single change a user has to hear about. Text is HTML formatted
+// and does not repeat the course name, since notifications are grouped by course
+type Notification struct {
+	ID         int64
+	TelegramID int64
+	Kind       NotificationKind
+	Course     string
+	Section    string
+	BundleID   int64
+	Text       string
+	Urgent     bool
+	Silent     bool
+
+	// Position among Recipients notified about the same free seat at once, zero when nobody competes
+	Position   int
+	Recipients int
+
+	DeliverAt time.Time
+	Attempts  int
+}
+
+// Standalone notifications are sent as separate messages instead of being a part of a digest
+func (n *Notification) Standalone() bool {
+	return n.Urgent || n.Kind == NotificationCourseOffered || n.Kind == NotificationAdminAlert || n.Kind == NotificationReminder
+}
+
+// TrackingChanges is everything a single tracker tick has to persist at once,
+// so a notification is never lost after the state it reports about was saved
+type TrackingChanges struct {
+	Subscriptions []*CourseSubscription
+	Bundles       []*BundleSubscription
+	Interests     []*CourseInterest // fulfilled ones, to be removed
+	Notifications []*Notification
+}
+
+// NotificationLogEntry is a persisted record of a user being notified about a free seat
+type NotificationLogEntry struct {
+	TelegramID  int64
+	Course      string
+	Section     string
+	Position    int // in the order of the fairness policy
+	Recipients  int
+	QueuedAt    time.Time
+	DeliveredAt time.Time // zero till the notification is delivered
+	// DeliveredRank is the place among the recipients in the order they actually got the
+	// notification, which quiet hours and digests may change. Zero till it is delivered
+	DeliveredRank int
+}
@@ -0,0 +1,119 @@
+package models
+
+import (
+	"math"
+	"slices"
+	"time"
+)
+
+// SectionKey identifies a section of a course
+type SectionKey struct {
+	Course  string
+	Section string
+}
+
+// EnrollmentSample is the enrollment of a section observed at some moment
+type EnrollmentSample struct {
+	Size int
+	Cap  int
+	At   time.Time
+}
+
+// MaxPredictionHorizon limits how far predictions reach, a slow trend says nothing about next week
+const MaxPredictionHorizon = 72 * time.Hour
+
+// PredictFullAt fits a line through the samples with least squares and returns when the
+// enrollment reaches the capacity of the latest sample. Sections which are full already,
+// not filling or too far from being full have no prediction
+func PredictFullAt(samples []EnrollmentSample) (time.Time, bool) {
+	if len(samples) < 2 {
+		return time.Time{}, false
+	}
+	samples = slices.Clone(samples)
+	slices.SortFunc(samples, func(a, b EnrollmentSample) int {
+		return a.At.Compare(b.At)
+	})
+	last := samples[len(samples)-1]
+	if last.Size >= last.Cap {
+		return time.Time{}, false
+	}
+
+	// x is hours since the first sample, y is the enrollment
+	origin := samples[0].At
+	var sumX, sumY, sumXX, sumXY float64
+	for _, s := range samples {
+		x := s.At.Sub(origin).Hours()
+		y := float64(s.Size)
+		sumX += x
+		sumY += y
+		sumXX += x * x
+		sumXY += x * y
+	}
+	n := float64(len(samples))
+	denominator := n*sumXX - sumX*sumX
+	if denominator == 0 {
+		return time.Time{}, false
+	}
+	slope := (n*sumXY - sumX*sumY) / denominator // seats per hour
+	if slope <= 0 {
+		return time.Time{}, false
+	}
+
+	hoursLeft := float64(last.Cap-last.Size) / slope
+	if hoursLeft > MaxPredictionHorizon.Hours() || math.IsInf(hoursLeft, 0) {
+		return time.Time{}, false
+	}
+	return last.At.Add(time.Duration(hoursLeft * float64(time.Hour))), true
+}
+
+// PredictionOutcome is a past prediction and what really happened
+type PredictionOutcome struct {
+	Course       string
+	Section      string
+	PredictedAt  time.Time
+	PredictedFor time.Time // when the section was expected to be full
+	FullAt       time.Time // zero while the section is not full
+}
+
+// PredictionAccuracy summarizes outcomes of predictions, whose time has come
+type PredictionAccuracy struct {
+	Resolved    int           // the section got full
+	Missed      int           // the predicted time passed long ago, but the section is not full
+	WithinHour  int           // resolved ones, that were off by less than an hour
+	MeanError   time.Duration // mean absolute error of resolved ones
+	MedianError time.Duration
+}
+
+// predictionGrace is how late a section may get full before the prediction counts as missed
+const predictionGrace = 6 * time.Hour
+
+func SummarizePredictions(outcomes []*PredictionOutcome, now time.Time) PredictionAccuracy {
+	var (
+		acc   PredictionAccuracy
+		errs  []time.Duration
+		total time.Duration
+	)
+	for _, o := range outcomes {
+		if o.FullAt.IsZero() {
+			if now.Sub(o.PredictedFor) > predictionGrace {
+				acc.Missed++
+			}
+			continue
+		}
+
+		e := o.FullAt.Sub(o.PredictedFor).Abs()
+		acc.Resolved++
+		if e < time.Hour {
+			acc.WithinHour++
+		}
+		errs = append(errs, e)
+		total += e
+	}
+
+	if len(errs) != 0 {
+		slices.Sort(errs)
+		acc.MeanError = total / time.Duration(len(errs))
+		acc.MedianError = errs[len(errs)/2]
+	}
+	return acc
+}
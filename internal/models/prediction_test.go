@@ -0,0 +1,93 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPredictFullAt(t *testing.T) {
+	start := time.Date(2025, 12, 17, 9, 0, 0, 0, time.UTC)
+	at := func(minutes int) time.Time {
+		return start.Add(time.Duration(minutes) * time.Minute)
+	}
+
+	tests := []struct {
+		name    string
+		samples []EnrollmentSample
+		want    time.Time
+		ok      bool
+	}{
+		{
+			name: "steady growth",
+			samples: []EnrollmentSample{
+				{Size: 10, Cap: 30, At: at(0)},
+				{Size: 15, Cap: 30, At: at(60)},
+				{Size: 20, Cap: 30, At: at(120)},
+			},
+			want: at(240),
+			ok:   true,
+		},
+		{
+			name: "unordered samples",
+			samples: []EnrollmentSample{
+				{Size: 20, Cap: 30, At: at(120)},
+				{Size: 10, Cap: 30, At: at(0)},
+			},
+			want: at(240),
+			ok:   true,
+		},
+		{
+			name: "already full",
+			samples: []EnrollmentSample{
+				{Size: 10, Cap: 30, At: at(0)},
+				{Size: 30, Cap: 30, At: at(60)},
+			},
+		},
+		{
+			name: "dropping",
+			samples: []EnrollmentSample{
+				{Size: 20, Cap: 30, At: at(0)},
+				{Size: 15, Cap: 30, At: at(60)},
+			},
+		},
+		{
+			name: "too slow",
+			samples: []EnrollmentSample{
+				{Size: 10, Cap: 300, At: at(0)},
+				{Size: 11, Cap: 300, At: at(60)},
+			},
+		},
+		{
+			name:    "single sample",
+			samples: []EnrollmentSample{{Size: 10, Cap: 30, At: at(0)}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := PredictFullAt(tt.samples)
+			assert.Equal(t, tt.ok, ok)
+			assert.WithinDuration(t, tt.want, got, time.Second)
+		})
+	}
+}
+
+func TestSummarizePredictions(t *testing.T) {
+	now := time.Date(2025, 12, 18, 9, 0, 0, 0, time.UTC)
+	acc := SummarizePredictions([]*PredictionOutcome{
+		{PredictedFor: now.Add(-10 * time.Hour), FullAt: now.Add(-10*time.Hour + 30*time.Minute)},
+		{PredictedFor: now.Add(-10 * time.Hour), FullAt: now.Add(-7 * time.Hour)},
+		{PredictedFor: now.Add(-10 * time.Hour)},
+		{PredictedFor: now.Add(-time.Hour)},
+	}, now)
+
+	assert.Equal(t, PredictionAccuracy{
+		Resolved:    2,
+		Missed:      1,
+		WithinHour:  1,
+		MeanError:   105 * time.Minute,
+		MedianError: 3 * time.Hour,
+	}, acc)
+}
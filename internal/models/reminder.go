@@ -0,0 +1,45 @@
+package models
+
+import (
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ReminderLeads are how long before a registration window opens users of its cohort are reminded about it
+var ReminderLeads = []time.Duration{24 * time.Hour, time.Hour, 10 * time.Minute}
+
+// MaxStudyYear is the last undergraduate year a user can pick
+const MaxStudyYear = 6
+
+// Reminder is a planned message to a user about their registration window
+type Reminder struct {
+	ID          int64
+	TelegramID  int64
+	WindowLabel string
+	WindowAt    time.Time // when registration of the window opens
+	RemindAt    time.Time
+}
+
+// cohortPattern finds the cohort in window labels like "First Priority for 4,5,6 UG" or "Third Priority for All UG"
+var cohortPattern = regexp.MustCompile(`(?i)\b(all|\d(?:\s*,\s*\d)*)\s*UG\b`)
+
+// WindowForYear tells whether a registration window is meant for students of the year.
+// Windows without a cohort in their label are meant for everyone
+func WindowForYear(label string, year int) bool {
+	m := cohortPattern.FindStringSubmatch(label)
+	if m == nil || strings.EqualFold(m[1], "all") {
+		return true
+	}
+
+	var years []int
+	for _, y := range strings.Split(m[1], ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(y))
+		if err == nil {
+			years = append(years, n)
+		}
+	}
+	return slices.Contains(years, year)
+}
@@ -0,0 +1,26 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWindowForYear(t *testing.T) {
+	tests := []struct {
+		label string
+		year  int
+		want  bool
+	}{
+		{"First Priority for 4,5,6 UG", 5, true},
+		{"First Priority for 4,5,6 UG", 3, false},
+		{"First Priority for 3 UG", 3, true},
+		{"Second Priority for 1 UG", 2, false},
+		{"First Priority 4UG", 4, true},
+		{"Third Priority for All UG", 1, true},
+		{"Add/Drop", 2, true},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, WindowForYear(tt.label, tt.year), "%q for year %d", tt.label, tt.year)
+	}
+}
@@ -0,0 +1,71 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// DayTime is a time of a day in minutes since midnight
+type DayTime int
+
+func ParseDayTime(s string) (DayTime, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("parsing day time %q: %w", s, err)
+	}
+	return DayTime(t.Hour()*60 + t.Minute()), nil
+}
+
+func (d DayTime) String() string {
+	return fmt.Sprintf("%02d:%02d", int(d)/60, int(d)%60)
+}
+
+// next returns the closest moment at or after now, which has the day time d
+func (d DayTime) next(now time.Time) time.Time {
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	t := midnight.Add(time.Duration(d) * time.Minute)
+	if t.Before(now) {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t
+}
+
+type UserSettings struct {
+	TelegramID int64
+	QuietFrom  *DayTime
+	QuietTill  *DayTime
+	DigestAt   *DayTime
+	StudyYear  int // zero when the user didn't pick one
+}
+
+func (s *UserSettings) HasQuietHours() bool {
+	return s.QuietFrom != nil && s.QuietTill != nil
+}
+
+// InQuietHours reports whether now falls into the quiet window, which may wrap over midnight
+func (s *UserSettings) InQuietHours(now time.Time) bool {
+	if !s.HasQuietHours() {
+		return false
+	}
+	cur := DayTime(now.Hour()*60 + now.Minute())
+	from, till := *s.QuietFrom, *s.QuietTill
+	if from <= till {
+		return from <= cur && cur < till
+	}
+	return cur >= from || cur < till
+}
+
+// NextDelivery returns when a non-urgent notification created at now has to be delivered.
+// now is returned for instant delivery. Times are compared in the location of now
+func (s *UserSettings) NextDelivery(now time.Time) time.Time {
+	if s == nil {
+		return now
+	}
+	if s.DigestAt != nil {
+		return s.DigestAt.next(now)
+	}
+	if s.InQuietHours(now) {
+		return s.QuietTill.next(now)
+	}
+	return now
+}
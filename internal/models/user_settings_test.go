@@ -0,0 +1,88 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func dayTime(s string) *DayTime {
+	d, err := ParseDayTime(s)
+	if err != nil {
+		panic(err)
+	}
+	return &d
+}
+
+func TestUserSettingsNextDelivery(t *testing.T) {
+	location := time.FixedZone("UTC+5", 5*60*60)
+	at := func(s string) time.Time {
+		t, err := time.ParseInLocation("2006-01-02 15:04", s, location)
+		if err != nil {
+			panic(err)
+		}
+		return t
+	}
+
+	tests := []struct {
+		name     string
+		settings *UserSettings
+		now      time.Time
+		want     time.Time
+	}{
+		{
+			name:     "No settings",
+			settings: nil,
+			now:      at("2025-12-17 10:00"),
+			want:     at("2025-12-17 10:00"),
+		},
+		{
+			name:     "Outside of quiet hours",
+			settings: &UserSettings{QuietFrom: dayTime("23:00"), QuietTill: dayTime("08:00")},
+			now:      at("2025-12-17 10:00"),
+			want:     at("2025-12-17 10:00"),
+		},
+		{
+			name:     "Quiet hours before midnight",
+			settings: &UserSettings{QuietFrom: dayTime("23:00"), QuietTill: dayTime("08:00")},
+			now:      at("2025-12-17 23:30"),
+			want:     at("2025-12-18 08:00"),
+		},
+		{
+			name:     "Quiet hours after midnight",
+			settings: &UserSettings{QuietFrom: dayTime("23:00"), QuietTill: dayTime("08:00")},
+			now:      at("2025-12-18 07:59"),
+			want:     at("2025-12-18 08:00"),
+		},
+		{
+			name:     "Quiet hours within a day",
+			settings: &UserSettings{QuietFrom: dayTime("13:00"), QuietTill: dayTime("14:00")},
+			now:      at("2025-12-17 13:15"),
+			want:     at("2025-12-17 14:00"),
+		},
+		{
+			name:     "End of quiet hours is not quiet",
+			settings: &UserSettings{QuietFrom: dayTime("23:00"), QuietTill: dayTime("08:00")},
+			now:      at("2025-12-18 08:00"),
+			want:     at("2025-12-18 08:00"),
+		},
+		{
+			name:     "Daily digest later today",
+			settings: &UserSettings{DigestAt: dayTime("20:00")},
+			now:      at("2025-12-17 10:00"),
+			want:     at("2025-12-17 20:00"),
+		},
+		{
+			name:     "Daily digest tomorrow",
+			settings: &UserSettings{DigestAt: dayTime("09:00"), QuietFrom: dayTime("23:00"), QuietTill: dayTime("08:00")},
+			now:      at("2025-12-17 10:00"),
+			want:     at("2025-12-18 09:00"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.settings.NextDelivery(tt.now))
+		})
+	}
+}
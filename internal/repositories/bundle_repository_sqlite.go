@@ -0,0 +1,185 @@
+package repositories
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/TheTeemka/telegram_bot_cources/internal/clock"
+	"github.com/TheTeemka/telegram_bot_cources/internal/models"
+)
+
+type BundleSubscriptionRepository interface {
+	Subscribe(telegramID int64, course string, sections []string, notifyPartial bool) error
+	GetBundles(int64) ([]*models.BundleSubscription, error)
+	GetAll() ([]*models.BundleSubscription, error)
+	GetPending(courses []string, since time.Time) ([]*models.BundleSubscription, error)
+	Delete(userID int64, bundleID int64) error
+	DeleteByCourse(userID int64, course string) error
+	SaveStatesTx(tx *sql.Tx, bundles []*models.BundleSubscription) error
+}
+
+type sqliteBundleRepo struct {
+	db    *sql.DB
+	clock clock.Clock
+}
+
+func NewSQLiteBundleRepo(db *sql.DB, clk clock.Clock) BundleSubscriptionRepository {
+	_, err := db.Exec(`
+        CREATE TABLE IF NOT EXISTS bundle_subscriptions (
+            id INTEGER PRIMARY KEY AUTOINCREMENT,
+            telegram_id INTEGER NOT NULL,
+            course TEXT NOT NULL,
+            sections TEXT NOT NULL,
+            notify_partial BOOLEAN DEFAULT FALSE,
+            state INTEGER DEFAULT 0,
+            created_at  DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+            updated_at DATETIME,
+            UNIQUE (telegram_id, course, sections)
+        );
+		CREATE INDEX IF NOT EXISTS idx_bundle_subscriptions_telegram_id ON bundle_subscriptions(telegram_id);
+    `)
+
+	if err != nil {
+		panic(fmt.Errorf("creating bundle_subscriptions table: %w", err))
+	}
+
+	return &sqliteBundleRepo{db: db, clock: clk}
+}
+
+func (r *sqliteBundleRepo) Subscribe(telegramID int64, course string, sections []string, notifyPartial bool) error {
+	query := `
+		INSERT INTO bundle_subscriptions (telegram_id, course, sections, notify_partial, created_at, updated_at)
+        VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT(telegram_id, course, sections) DO UPDATE SET notify_partial = excluded.notify_partial
+    `
+
+	now := r.clock.Now().UTC()
+	_, err := r.db.Exec(query, telegramID, course, strings.Join(sections, ","), notifyPartial, now, now)
+	if err != nil {
+		return fmt.Errorf("inserting bundle subscription: %w", err)
+	}
+	return nil
+}
+
+func (r *sqliteBundleRepo) GetBundles(userID int64) ([]*models.BundleSubscription, error) {
+	rows, err := r.db.Query(`
+        SELECT id, telegram_id, course, sections, state, notify_partial
+        FROM bundle_subscriptions
+        WHERE telegram_id = ?
+        ORDER BY course ASC, id ASC
+    `, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanBundles(rows)
+}
+
+func (r *sqliteBundleRepo) GetAll() ([]*models.BundleSubscription, error) {
+	rows, err := r.db.Query(`
+        SELECT id, telegram_id, course, sections, state, notify_partial
+        FROM bundle_subscriptions
+        WHERE ` + activeUsersOnly + `
+    `)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanBundles(rows)
+}
+
+// GetPending returns bundles of the changed courses, the ones created since the given time
+// and the ones of users who came back since then
+func (r *sqliteBundleRepo) GetPending(courses []string, since time.Time) ([]*models.BundleSubscription, error) {
+	query := `
+        SELECT id, telegram_id, course, sections, state, notify_partial
+        FROM bundle_subscriptions
+        WHERE (created_at >= ? OR ` + reactivatedSince
+	args := []any{since.UTC(), since.UTC()}
+	if len(courses) != 0 {
+		query += ` OR course IN (?` + strings.Repeat(", ?", len(courses)-1) + `)`
+		for _, course := range courses {
+			args = append(args, course)
+		}
+	}
+	rows, err := r.db.Query(query+`) AND `+activeUsersOnly, args...)
+	if err != nil {
+		return nil, fmt.Errorf("getting pending bundle subscriptions: %w", err)
+	}
+	defer rows.Close()
+
+	return scanBundles(rows)
+}
+
+// SaveStatesTx saves the states of bundles, as the tracker saw them, within its transaction
+func (r *sqliteBundleRepo) SaveStatesTx(tx *sql.Tx, bundles []*models.BundleSubscription) error {
+	if len(bundles) == 0 {
+		return nil
+	}
+
+	stmt, err := tx.Prepare(`
+		UPDATE bundle_subscriptions
+		SET updated_at = ?, state = ?
+		WHERE id = ?
+	`)
+	if err != nil {
+		return fmt.Errorf("preparing bundle subscription update: %w", err)
+	}
+	defer stmt.Close()
+
+	now := r.clock.Now().UTC()
+	for _, b := range bundles {
+		if _, err := stmt.Exec(now, b.State, b.ID); err != nil {
+			return fmt.Errorf("updating bundle subscription: %w", err)
+		}
+	}
+	return nil
+}
+
+func scanBundles(rows *sql.Rows) ([]*models.BundleSubscription, error) {
+	var bundles []*models.BundleSubscription
+	for rows.Next() {
+		var (
+			b        models.BundleSubscription
+			sections string
+		)
+		err := rows.Scan(&b.ID, &b.TelegramID, &b.Course, &sections, &b.State, &b.NotifyPartial)
+		if err != nil {
+			return nil, err
+		}
+		b.Sections = strings.Split(sections, ",")
+		bundles = append(bundles, &b)
+	}
+
+	return bundles, rows.Err()
+}
+
+func (r *sqliteBundleRepo) Delete(userID int64, bundleID int64) error {
+	query := `
+		DELETE FROM bundle_subscriptions
+		WHERE telegram_id = ? AND id = ?
+    `
+
+	_, err := r.db.Exec(query, userID, bundleID)
+	if err != nil {
+		return fmt.Errorf("deleting bundle subscription: %w", err)
+	}
+	return nil
+}
+
+func (r *sqliteBundleRepo) DeleteByCourse(userID int64, course string) error {
+	query := `
+		DELETE FROM bundle_subscriptions
+		WHERE telegram_id = ? AND course = ?
+    `
+
+	_, err := r.db.Exec(query, userID, course)
+	if err != nil {
+		return fmt.Errorf("deleting bundle subscriptions of course: %w", err)
+	}
+	return nil
+}
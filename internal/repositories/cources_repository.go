@@ -9,14 +9,15 @@ import (
 	"log/slog"
 	"net/http"
 	"os"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/TheTeemka/telegram_bot_cources/internal/clock"
 	"github.com/TheTeemka/telegram_bot_cources/internal/config"
 	"github.com/TheTeemka/telegram_bot_cources/internal/models"
-	"github.com/TheTeemka/telegram_bot_cources/internal/ticker"
 	"github.com/shakinm/xlsReader/xls"
 	"github.com/shakinm/xlsReader/xls/structure"
 )
@@ -27,24 +28,24 @@ type CourseRepository struct {
 
 	Courses         map[string]*models.Course
 	LastTimeParsed  time.Time
-	NextTimeToParse time.Time
 	SemesterName    string
 	SectionAbbrList []string
+	LastDiff        *models.CatalogDiff
 
-	mutex  sync.RWMutex
-	ticker *ticker.DynamicTicker
+	mutex sync.RWMutex
+	clock clock.Clock
 
 	TimeIntervalBetweenParse time.Duration
 }
 
-func NewCourseRepo(apiConfig config.APIConfig) *CourseRepository {
+func NewCourseRepo(apiConfig config.APIConfig, clk clock.Clock) *CourseRepository {
 	r := &CourseRepository{
 		CoursesURL:               apiConfig.CourseURL,
 		IsExampleData:            apiConfig.IsExampleData,
 		TimeIntervalBetweenParse: apiConfig.TimeIntervalBetweenParses,
 
 		Courses: map[string]*models.Course{},
-		ticker:  ticker.NewDynamicTicker(apiConfig.TimeIntervalBetweenParses),
+		clock:   clk,
 	}
 
 	err := r.Parse()
@@ -56,19 +57,16 @@ func NewCourseRepo(apiConfig config.APIConfig) *CourseRepository {
 	return r
 }
 
-// func (r *CourseRepository) Watch() {
-// 	for range r.ticker.C {
-// 		if err := r.Parse(); err != nil {
-// 			slog.Error("Failed to parse courses", "error", err)
-// 			continue
-// 		}
-// 	}
-// }
-
 func (r *CourseRepository) Parse() error {
 	r.mutex.Lock()
 	slog.Info("Courses parsing")
 	defer r.mutex.Unlock()
+
+	old := r.Courses
+	defer func() {
+		r.LastDiff = models.DiffCatalogs(old, r.Courses)
+	}()
+
 	if r.IsExampleData {
 		return r.ParseExampleData()
 	}
@@ -83,8 +81,7 @@ func (r *CourseRepository) Parse() error {
 	r.Courses = cources
 
 	location := time.FixedZone("UTC+5", 5*60*60)
-	r.LastTimeParsed = time.Now().In(location)
-	r.NextTimeToParse = r.ticker.TimePoint
+	r.LastTimeParsed = r.clock.Now().In(location)
 
 	r.SemesterName = semesterName
 	r.SectionAbbrList = sectionAbbrList
@@ -142,6 +139,41 @@ func (r *CourseRepository) GetCourse(name string) (*models.Course, bool) {
 	return course, exists
 }
 
+// SearchCourses finds courses by a part of their standardized code or their title, ignoring case
+// of the title, sorted by code
+func (r *CourseRepository) SearchCourses(code, title string, limit int) []*models.Course {
+	title = strings.ToLower(title)
+	if code == "" && title == "" {
+		return nil
+	}
+
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	var found []*models.Course
+	for _, c := range r.Courses {
+		// cross-listed courses like TUR 280/LING 280 are kept under every code, each with its own copy
+		if slices.ContainsFunc(found, func(f *models.Course) bool { return f.AbbrName == c.AbbrName }) {
+			continue
+		}
+		if (code != "" && strings.Contains(c.AbbrName, code)) || (title != "" && strings.Contains(strings.ToLower(c.FullName), title)) {
+			found = append(found, c)
+		}
+	}
+	slices.SortFunc(found, func(a, b *models.Course) int {
+		return strings.Compare(a.AbbrName, b.AbbrName)
+	})
+	return found[:min(limit, len(found))]
+}
+
+// GetLastDiff returns the changes made to the catalog by the latest parse
+func (r *CourseRepository) GetLastDiff() *models.CatalogDiff {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	return r.LastDiff
+}
+
 func (r *CourseRepository) GetSection(courseName, SectionName string) (*models.Section, bool) {
 
 	r.mutex.RLock()
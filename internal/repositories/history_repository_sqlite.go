@@ -0,0 +1,168 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/TheTeemka/telegram_bot_cources/internal/models"
+)
+
+// EnrollmentHistoryRepository keeps every observed enrollment of a section. Only changes are
+// recorded, so a section keeps its latest size till the next row of it
+type EnrollmentHistoryRepository interface {
+	Record(changes []models.SectionChange, at time.Time) error
+	GetSince(since time.Time) (map[models.SectionKey][]models.EnrollmentSample, error)
+
+	RecordPrediction(key models.SectionKey, predictedAt, fullAt time.Time) error
+	ResolvePredictions(key models.SectionKey, fullAt time.Time) error
+	GetPredictionOutcomes(since time.Time) ([]*models.PredictionOutcome, error)
+}
+
+type sqliteHistoryRepo struct {
+	db *sql.DB
+}
+
+func NewSQLiteHistoryRepo(db *sql.DB) EnrollmentHistoryRepository {
+	_, err := db.Exec(`
+        CREATE TABLE IF NOT EXISTS enrollment_history (
+            id INTEGER PRIMARY KEY AUTOINCREMENT,
+            course TEXT NOT NULL,
+            section TEXT NOT NULL,
+            size INTEGER NOT NULL,
+            cap INTEGER NOT NULL,
+            recorded_at DATETIME NOT NULL
+        );
+		CREATE INDEX IF NOT EXISTS idx_enrollment_history_section ON enrollment_history(course, section, recorded_at);
+		CREATE INDEX IF NOT EXISTS idx_enrollment_history_recorded_at ON enrollment_history(recorded_at);
+
+        CREATE TABLE IF NOT EXISTS fill_predictions (
+            id INTEGER PRIMARY KEY AUTOINCREMENT,
+            course TEXT NOT NULL,
+            section TEXT NOT NULL,
+            predicted_at DATETIME NOT NULL,
+            predicted_full_at DATETIME NOT NULL,
+            full_at DATETIME
+        );
+		CREATE INDEX IF NOT EXISTS idx_fill_predictions_section ON fill_predictions(course, section);
+    `)
+	if err != nil {
+		panic(fmt.Errorf("creating enrollment_history table: %w", err))
+	}
+
+	return &sqliteHistoryRepo{db: db}
+}
+
+// Record saves the new state of changed sections, removed ones are skipped
+func (r *sqliteHistoryRepo) Record(changes []models.SectionChange, at time.Time) error {
+	tx, err := r.db.BeginTx(context.Background(), nil)
+	if err != nil {
+		return fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`
+		INSERT INTO enrollment_history (course, section, size, cap, recorded_at)
+		VALUES (?, ?, ?, ?, ?)
+	`)
+	if err != nil {
+		return fmt.Errorf("preparing enrollment history insertion: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, c := range changes {
+		if c.New == nil {
+			continue
+		}
+		_, err := stmt.Exec(c.Course, c.Section, c.New.Size, c.New.Cap, at.UTC())
+		if err != nil {
+			return fmt.Errorf("inserting enrollment history: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("committing transaction: %w", err)
+	}
+	return nil
+}
+
+// GetSince returns enrollment samples of every section recorded since the given time, oldest first
+func (r *sqliteHistoryRepo) GetSince(since time.Time) (map[models.SectionKey][]models.EnrollmentSample, error) {
+	rows, err := r.db.Query(`
+		SELECT course, section, size, cap, recorded_at
+		FROM enrollment_history
+		WHERE recorded_at >= ?
+		ORDER BY recorded_at ASC
+    `, since.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("getting enrollment history: %w", err)
+	}
+	defer rows.Close()
+
+	res := make(map[models.SectionKey][]models.EnrollmentSample)
+	for rows.Next() {
+		var (
+			key    models.SectionKey
+			sample models.EnrollmentSample
+		)
+		err := rows.Scan(&key.Course, &key.Section, &sample.Size, &sample.Cap, &sample.At)
+		if err != nil {
+			return nil, fmt.Errorf("scanning enrollment history: %w", err)
+		}
+		res[key] = append(res[key], sample)
+	}
+	return res, rows.Err()
+}
+
+func (r *sqliteHistoryRepo) RecordPrediction(key models.SectionKey, predictedAt, fullAt time.Time) error {
+	_, err := r.db.Exec(`
+		INSERT INTO fill_predictions (course, section, predicted_at, predicted_full_at)
+		VALUES (?, ?, ?, ?)
+    `, key.Course, key.Section, predictedAt.UTC(), fullAt.UTC())
+	if err != nil {
+		return fmt.Errorf("inserting fill prediction: %w", err)
+	}
+	return nil
+}
+
+// ResolvePredictions sets the moment the section got full to its predictions made before it
+func (r *sqliteHistoryRepo) ResolvePredictions(key models.SectionKey, fullAt time.Time) error {
+	_, err := r.db.Exec(`
+		UPDATE fill_predictions
+		SET full_at = ?
+		WHERE course = ? AND section = ? AND full_at IS NULL AND predicted_at <= ?
+    `, fullAt.UTC(), key.Course, key.Section, fullAt.UTC())
+	if err != nil {
+		return fmt.Errorf("resolving fill predictions: %w", err)
+	}
+	return nil
+}
+
+func (r *sqliteHistoryRepo) GetPredictionOutcomes(since time.Time) ([]*models.PredictionOutcome, error) {
+	rows, err := r.db.Query(`
+		SELECT course, section, predicted_at, predicted_full_at, full_at
+		FROM fill_predictions
+		WHERE predicted_at >= ?
+		ORDER BY id ASC
+    `, since.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("getting fill predictions: %w", err)
+	}
+	defer rows.Close()
+
+	var outcomes []*models.PredictionOutcome
+	for rows.Next() {
+		var (
+			o      models.PredictionOutcome
+			fullAt sql.NullTime
+		)
+		err := rows.Scan(&o.Course, &o.Section, &o.PredictedAt, &o.PredictedFor, &fullAt)
+		if err != nil {
+			return nil, fmt.Errorf("scanning fill prediction: %w", err)
+		}
+		o.FullAt = fullAt.Time
+		outcomes = append(outcomes, &o)
+	}
+	return outcomes, rows.Err()
+}
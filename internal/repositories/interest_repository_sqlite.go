@@ -0,0 +1,159 @@
+package repositories
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/TheTeemka/telegram_bot_cources/internal/clock"
+	"github.com/TheTeemka/telegram_bot_cources/internal/models"
+)
+
+// CourseInterestRepository keeps courses users are waiting for, that are not in the catalog yet
+type CourseInterestRepository interface {
+	Add(userID int64, course string) error
+	Remove(userID int64, course string) (bool, error)
+	GetInterests(userID int64) ([]*models.CourseInterest, error)
+	GetAll() ([]*models.CourseInterest, error)
+	GetPending(courses []string, since time.Time) ([]*models.CourseInterest, error)
+	RemoveTx(tx *sql.Tx, interests []*models.CourseInterest) error
+}
+
+type sqliteInterestRepo struct {
+	db    *sql.DB
+	clock clock.Clock
+}
+
+func NewSQLiteInterestRepo(db *sql.DB, clk clock.Clock) CourseInterestRepository {
+	_, err := db.Exec(`
+        CREATE TABLE IF NOT EXISTS course_interests (
+            telegram_id INTEGER NOT NULL,
+            course TEXT NOT NULL,
+            created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+            PRIMARY KEY (telegram_id, course)
+        );
+    `)
+	if err != nil {
+		panic(fmt.Errorf("creating course_interests table: %w", err))
+	}
+
+	return &sqliteInterestRepo{db: db, clock: clk}
+}
+
+func (r *sqliteInterestRepo) Add(userID int64, course string) error {
+	query := `
+		INSERT OR IGNORE INTO course_interests (telegram_id, course, created_at)
+		VALUES (?, ?, ?)
+    `
+
+	_, err := r.db.Exec(query, userID, course, r.clock.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("inserting course interest: %w", err)
+	}
+	return nil
+}
+
+func (r *sqliteInterestRepo) Remove(userID int64, course string) (bool, error) {
+	query := `
+		DELETE FROM course_interests
+		WHERE telegram_id = ? AND course = ?
+    `
+
+	res, err := r.db.Exec(query, userID, course)
+	if err != nil {
+		return false, fmt.Errorf("deleting course interest: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("deleting course interest: %w", err)
+	}
+	return n > 0, nil
+}
+
+func (r *sqliteInterestRepo) GetInterests(userID int64) ([]*models.CourseInterest, error) {
+	rows, err := r.db.Query(`
+        SELECT telegram_id, course
+        FROM course_interests
+        WHERE telegram_id = ?
+        ORDER BY course ASC
+    `, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanInterests(rows)
+}
+
+func (r *sqliteInterestRepo) GetAll() ([]*models.CourseInterest, error) {
+	rows, err := r.db.Query(`
+        SELECT telegram_id, course
+        FROM course_interests
+        WHERE ` + activeUsersOnly + `
+    `)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanInterests(rows)
+}
+
+// GetPending returns interests in the changed courses, the ones added since the given time
+// and the ones of users who came back since then
+func (r *sqliteInterestRepo) GetPending(courses []string, since time.Time) ([]*models.CourseInterest, error) {
+	query := `
+        SELECT telegram_id, course
+        FROM course_interests
+        WHERE (created_at >= ? OR ` + reactivatedSince
+	args := []any{since.UTC(), since.UTC()}
+	if len(courses) != 0 {
+		query += ` OR course IN (?` + strings.Repeat(", ?", len(courses)-1) + `)`
+		for _, course := range courses {
+			args = append(args, course)
+		}
+	}
+	rows, err := r.db.Query(query+`) AND `+activeUsersOnly, args...)
+	if err != nil {
+		return nil, fmt.Errorf("getting pending course interests: %w", err)
+	}
+	defer rows.Close()
+
+	return scanInterests(rows)
+}
+
+// RemoveTx forgets interests in courses that appeared, within the transaction of the tracker
+func (r *sqliteInterestRepo) RemoveTx(tx *sql.Tx, interests []*models.CourseInterest) error {
+	if len(interests) == 0 {
+		return nil
+	}
+
+	stmt, err := tx.Prepare(`
+		DELETE FROM course_interests
+		WHERE telegram_id = ? AND course = ?
+	`)
+	if err != nil {
+		return fmt.Errorf("preparing course interest deletion: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, i := range interests {
+		if _, err := stmt.Exec(i.TelegramID, i.Course); err != nil {
+			return fmt.Errorf("deleting course interest: %w", err)
+		}
+	}
+	return nil
+}
+
+func scanInterests(rows *sql.Rows) ([]*models.CourseInterest, error) {
+	var interests []*models.CourseInterest
+	for rows.Next() {
+		var i models.CourseInterest
+		if err := rows.Scan(&i.TelegramID, &i.Course); err != nil {
+			return nil, err
+		}
+		interests = append(interests, &i)
+	}
+	return interests, rows.Err()
+}
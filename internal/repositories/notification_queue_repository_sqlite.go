@@ -0,0 +1,340 @@
+package repositories
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/TheTeemka/telegram_bot_cources/internal/clock"
+	"github.com/TheTeemka/telegram_bot_cources/internal/database"
+	"github.com/TheTeemka/telegram_bot_cources/internal/models"
+)
+
+const (
+	NotificationPending   = "pending"
+	NotificationDelivered = "delivered"
+	NotificationFailed    = "failed"
+)
+
+// NotificationQueueRepository is the outbox of notifications. Rows stay there with their delivery
+// status. Notifications about contested seats are also written to the notification log
+type NotificationQueueRepository interface {
+	Enqueue(notifications []*models.Notification) error
+	EnqueueTx(tx *sql.Tx, notifications []*models.Notification) error
+	Due(now time.Time, limit int) ([]*models.Notification, error)
+	MarkDelivered(ids []int64) error
+	Retry(ids []int64, reason string, retryAt time.Time) error
+	MarkFailed(ids []int64, reason string) error
+	DropPending(userID int64, reason string) error
+	PurgeSettled(before time.Time) (int64, error)
+
+	LastNotified(course, section string) (map[int64]time.Time, error)
+	LastFirstNotified(course, section string) (int64, error)
+	GetLog(userID int64, limit int) ([]*models.NotificationLogEntry, error)
+}
+
+type sqliteNotificationQueueRepo struct {
+	db    *sql.DB
+	clock clock.Clock
+}
+
+func NewSQLiteNotificationQueueRepo(db *sql.DB, clk clock.Clock) NotificationQueueRepository {
+	_, err := db.Exec(`
+        CREATE TABLE IF NOT EXISTS notification_queue (
+            id INTEGER PRIMARY KEY AUTOINCREMENT,
+            telegram_id INTEGER NOT NULL,
+            kind INTEGER NOT NULL,
+            course TEXT NOT NULL,
+            section TEXT NOT NULL DEFAULT '',
+            bundle_id INTEGER NOT NULL DEFAULT 0,
+            text TEXT NOT NULL,
+            urgent BOOLEAN DEFAULT FALSE,
+            silent BOOLEAN DEFAULT FALSE,
+            deliver_at DATETIME NOT NULL,
+            created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+        );
+		CREATE INDEX IF NOT EXISTS idx_notification_queue_deliver_at ON notification_queue(deliver_at);
+    `)
+	if err != nil {
+		panic(fmt.Errorf("creating notification_queue table: %w", err))
+	}
+
+	columns := [][2]string{
+		{"status", "TEXT NOT NULL DEFAULT 'pending'"},
+		{"attempts", "INTEGER NOT NULL DEFAULT 0"},
+		{"last_error", "TEXT"},
+		{"updated_at", "DATETIME"},
+	}
+	for _, c := range columns {
+		if err := database.EnsureColumn(db, "notification_queue", c[0], c[1]); err != nil {
+			panic(fmt.Errorf("migrating notification_queue table: %w", err))
+		}
+	}
+	_, err = db.Exec(`CREATE INDEX IF NOT EXISTS idx_notification_queue_status ON notification_queue(status, deliver_at)`)
+	if err != nil {
+		panic(fmt.Errorf("creating notification_queue index: %w", err))
+	}
+
+	_, err = db.Exec(`
+        CREATE TABLE IF NOT EXISTS notification_log (
+            id INTEGER PRIMARY KEY AUTOINCREMENT,
+            notification_id INTEGER NOT NULL,
+            telegram_id INTEGER NOT NULL,
+            course TEXT NOT NULL,
+            section TEXT NOT NULL,
+            position INTEGER NOT NULL,
+            recipients INTEGER NOT NULL,
+            queued_at DATETIME NOT NULL,
+            delivered_at DATETIME
+        );
+		CREATE INDEX IF NOT EXISTS idx_notification_log_section ON notification_log(course, section);
+		CREATE INDEX IF NOT EXISTS idx_notification_log_telegram_id ON notification_log(telegram_id);
+		CREATE INDEX IF NOT EXISTS idx_notification_log_notification_id ON notification_log(notification_id);
+    `)
+	if err != nil {
+		panic(fmt.Errorf("creating notification_log table: %w", err))
+	}
+
+	return &sqliteNotificationQueueRepo{db: db, clock: clk}
+}
+
+// Enqueue adds notifications to the outbox in a transaction of their own
+func (r *sqliteNotificationQueueRepo) Enqueue(notifications []*models.Notification) error {
+	return database.InTx(r.db, func(tx *sql.Tx) error {
+		return r.EnqueueTx(tx, notifications)
+	})
+}
+
+// EnqueueTx adds notifications to the outbox within the transaction of the caller, so they are
+// saved together with the state they report about. Notifications about contested seats are
+// logged as well
+func (r *sqliteNotificationQueueRepo) EnqueueTx(tx *sql.Tx, notifications []*models.Notification) error {
+	if len(notifications) == 0 {
+		return nil
+	}
+	now := r.clock.Now()
+
+	enqueue, err := tx.Prepare(`
+		INSERT INTO notification_queue (telegram_id, kind, course, section, bundle_id, text, urgent, silent, deliver_at, status)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`)
+	if err != nil {
+		return fmt.Errorf("preparing notification insertion: %w", err)
+	}
+	defer enqueue.Close()
+	logEntry, err := tx.Prepare(`
+		INSERT INTO notification_log (notification_id, telegram_id, course, section, position, recipients, queued_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`)
+	if err != nil {
+		return fmt.Errorf("preparing notification log insertion: %w", err)
+	}
+	defer logEntry.Close()
+	for _, n := range notifications {
+		res, err := enqueue.Exec(n.TelegramID, n.Kind, n.Course, n.Section, n.BundleID,
+			n.Text, n.Urgent, n.Silent, n.DeliverAt.UTC(), NotificationPending)
+		if err != nil {
+			return fmt.Errorf("enqueueing notification: %w", err)
+		}
+		if n.Recipients == 0 {
+			continue
+		}
+
+		id, err := res.LastInsertId()
+		if err != nil {
+			return fmt.Errorf("enqueueing notification: %w", err)
+		}
+		_, err = logEntry.Exec(id, n.TelegramID, n.Course, n.Section, n.Position, n.Recipients, now.UTC())
+		if err != nil {
+			return fmt.Errorf("logging notification: %w", err)
+		}
+	}
+	return nil
+}
+
+// Due returns pending notifications which have to be delivered by now, oldest first
+func (r *sqliteNotificationQueueRepo) Due(now time.Time, limit int) ([]*models.Notification, error) {
+	rows, err := r.db.Query(`
+		SELECT id, telegram_id, kind, course, section, bundle_id, text, urgent, silent, deliver_at, attempts
+		FROM notification_queue
+		WHERE status = ? AND deliver_at <= ?
+		ORDER BY id ASC
+		LIMIT ?
+    `, NotificationPending, now.UTC(), limit)
+	if err != nil {
+		return nil, fmt.Errorf("getting due notifications: %w", err)
+	}
+	defer rows.Close()
+
+	var notifications []*models.Notification
+	for rows.Next() {
+		var n models.Notification
+		err := rows.Scan(&n.ID, &n.TelegramID, &n.Kind, &n.Course, &n.Section, &n.BundleID,
+			&n.Text, &n.Urgent, &n.Silent, &n.DeliverAt, &n.Attempts)
+		if err != nil {
+			return nil, fmt.Errorf("scanning notification: %w", err)
+		}
+		notifications = append(notifications, &n)
+	}
+	return notifications, rows.Err()
+}
+
+func (r *sqliteNotificationQueueRepo) MarkDelivered(ids []int64) error {
+	err := r.setStatus(ids, `status = ?, attempts = attempts + 1, updated_at = ?`,
+		NotificationDelivered, r.clock.Now())
+	if err != nil || len(ids) == 0 {
+		return err
+	}
+
+	args := []any{r.clock.Now().UTC()}
+	for _, id := range ids {
+		args = append(args, id)
+	}
+	_, err = r.db.Exec(`
+		UPDATE notification_log SET delivered_at = ?
+		WHERE notification_id IN (?`+strings.Repeat(", ?", len(ids)-1)+`)
+	`, args...)
+	if err != nil {
+		return fmt.Errorf("logging notification delivery: %w", err)
+	}
+	return nil
+}
+
+func (r *sqliteNotificationQueueRepo) Retry(ids []int64, reason string, retryAt time.Time) error {
+	return r.setStatus(ids, `attempts = attempts + 1, last_error = ?, deliver_at = ?, updated_at = ?`,
+		reason, retryAt.UTC(), r.clock.Now())
+}
+
+func (r *sqliteNotificationQueueRepo) MarkFailed(ids []int64, reason string) error {
+	return r.setStatus(ids, `status = ?, attempts = attempts + 1, last_error = ?, updated_at = ?`,
+		NotificationFailed, reason, r.clock.Now())
+}
+
+// DropPending fails every pending notification of the user, who can't be reached anymore
+func (r *sqliteNotificationQueueRepo) DropPending(userID int64, reason string) error {
+	_, err := r.db.Exec(`
+		UPDATE notification_queue
+		SET status = ?, last_error = ?, updated_at = ?
+		WHERE telegram_id = ? AND status = ?
+    `, NotificationFailed, reason, r.clock.Now(), userID, NotificationPending)
+	if err != nil {
+		return fmt.Errorf("dropping pending notifications: %w", err)
+	}
+	return nil
+}
+
+// PurgeSettled deletes delivered and failed notifications settled before the given time.
+// The notification log keeps its own rows
+func (r *sqliteNotificationQueueRepo) PurgeSettled(before time.Time) (int64, error) {
+	res, err := r.db.Exec(`
+		DELETE FROM notification_queue
+		WHERE status IN (?, ?) AND COALESCE(updated_at, created_at) < ?
+    `, NotificationDelivered, NotificationFailed, before.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("purging settled notifications: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("purging settled notifications: %w", err)
+	}
+	return n, nil
+}
+
+func (r *sqliteNotificationQueueRepo) setStatus(ids []int64, set string, args ...any) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	query := "UPDATE notification_queue SET " + set +
+		" WHERE id IN (?" + strings.Repeat(", ?", len(ids)-1) + ")"
+	for _, id := range ids {
+		args = append(args, id)
+	}
+
+	_, err := r.db.Exec(query, args...)
+	if err != nil {
+		return fmt.Errorf("updating notifications status: %w", err)
+	}
+	return nil
+}
+
+// LastNotified returns when each user was last notified about a free seat in the section
+func (r *sqliteNotificationQueueRepo) LastNotified(course, section string) (map[int64]time.Time, error) {
+	rows, err := r.db.Query(`
+		SELECT telegram_id, queued_at
+		FROM notification_log
+		WHERE course = ? AND section = ?
+		ORDER BY id DESC
+    `, course, section)
+	if err != nil {
+		return nil, fmt.Errorf("getting last notified users: %w", err)
+	}
+	defer rows.Close()
+
+	res := make(map[int64]time.Time)
+	for rows.Next() {
+		var (
+			id       int64
+			queuedAt time.Time
+		)
+		if err := rows.Scan(&id, &queuedAt); err != nil {
+			return nil, fmt.Errorf("scanning notification log: %w", err)
+		}
+		if _, ok := res[id]; !ok {
+			res[id] = queuedAt
+		}
+	}
+	return res, rows.Err()
+}
+
+// LastFirstNotified returns the user who was first in line the last time a seat of the section
+// was contested, zero if it never was
+func (r *sqliteNotificationQueueRepo) LastFirstNotified(course, section string) (int64, error) {
+	var userID int64
+	err := r.db.QueryRow(`
+		SELECT telegram_id
+		FROM notification_log
+		WHERE course = ? AND section = ? AND position = 1
+		ORDER BY id DESC
+		LIMIT 1
+    `, course, section).Scan(&userID)
+	if err != nil && err != sql.ErrNoRows {
+		return 0, fmt.Errorf("getting last first notified user: %w", err)
+	}
+	return userID, nil
+}
+
+// GetLog returns the latest free seat notifications of the user, newest first. The place in the
+// order of delivery is counted among the users notified about the same seat in the same tick
+func (r *sqliteNotificationQueueRepo) GetLog(userID int64, limit int) ([]*models.NotificationLogEntry, error) {
+	rows, err := r.db.Query(`
+		SELECT l.telegram_id, l.course, l.section, l.position, l.recipients, l.queued_at, l.delivered_at,
+			(SELECT COUNT(*) FROM notification_log o
+			 WHERE o.course = l.course AND o.section = l.section AND o.queued_at = l.queued_at
+			   AND (o.delivered_at < l.delivered_at OR (o.delivered_at = l.delivered_at AND o.id <= l.id)))
+		FROM notification_log l
+		WHERE l.telegram_id = ?
+		ORDER BY l.id DESC
+		LIMIT ?
+    `, userID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("getting notification log: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []*models.NotificationLogEntry
+	for rows.Next() {
+		var (
+			e           models.NotificationLogEntry
+			deliveredAt sql.NullTime
+		)
+		err := rows.Scan(&e.TelegramID, &e.Course, &e.Section, &e.Position, &e.Recipients, &e.QueuedAt, &deliveredAt, &e.DeliveredRank)
+		if err != nil {
+			return nil, fmt.Errorf("scanning notification log: %w", err)
+		}
+		e.DeliveredAt = deliveredAt.Time
+		entries = append(entries, &e)
+	}
+	return entries, rows.Err()
+}
@@ -0,0 +1,50 @@
+package repositories
+
+import (
+	"testing"
+	"time"
+
+	"github.com/TheTeemka/telegram_bot_cources/internal/clock"
+	"github.com/TheTeemka/telegram_bot_cources/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNotificationLogDeliveredRank(t *testing.T) {
+	clk := clock.NewFake(time.Date(2025, 12, 19, 15, 0, 0, 0, time.UTC))
+	repo := NewSQLiteNotificationQueueRepo(newTestDB(t), clk)
+
+	var notifications []*models.Notification
+	for i, id := range []int64{1, 2, 3} {
+		notifications = append(notifications, &models.Notification{
+			TelegramID: id,
+			Kind:       models.NotificationSeat,
+			Course:     "CSCI 151",
+			Section:    "1L",
+			Text:       "opened",
+			DeliverAt:  clk.Now(),
+			Position:   i + 1,
+			Recipients: 3,
+		})
+	}
+	assert.NoError(t, repo.Enqueue(notifications))
+
+	due, err := repo.Due(clk.Now(), 10)
+	assert.NoError(t, err)
+	assert.Len(t, due, 3)
+	// the first user is in quiet hours, the other two get it right away
+	assert.NoError(t, repo.MarkDelivered([]int64{due[1].ID, due[2].ID}))
+	clk.Advance(time.Hour)
+	assert.NoError(t, repo.MarkDelivered([]int64{due[0].ID}))
+
+	rank := func(userID int64) (position, delivered int) {
+		entries, err := repo.GetLog(userID, 10)
+		assert.NoError(t, err)
+		assert.Len(t, entries, 1)
+		return entries[0].Position, entries[0].DeliveredRank
+	}
+	for userID, want := range map[int64][2]int{1: {1, 3}, 2: {2, 1}, 3: {3, 2}} {
+		position, delivered := rank(userID)
+		assert.Equal(t, want[0], position, "user %d", userID)
+		assert.Equal(t, want[1], delivered, "user %d", userID)
+	}
+}
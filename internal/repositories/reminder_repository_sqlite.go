@@ -0,0 +1,136 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/TheTeemka/telegram_bot_cources/internal/models"
+)
+
+// ReminderRepository keeps reminders planned for users about their registration windows.
+// Sent reminders are kept till their window is long over, so replanning never repeats them
+type ReminderRepository interface {
+	ReplacePending(userID int64, reminders []*models.Reminder) error
+	ReplaceAllPending(reminders []*models.Reminder) error
+	Due(now time.Time) ([]*models.Reminder, error)
+	MarkSent(ids []int64, at time.Time) error
+	PurgeBefore(t time.Time) (int64, error)
+}
+
+type sqliteReminderRepo struct {
+	db *sql.DB
+}
+
+func NewSQLiteReminderRepo(db *sql.DB) ReminderRepository {
+	_, err := db.Exec(`
+        CREATE TABLE IF NOT EXISTS reminders (
+            id INTEGER PRIMARY KEY AUTOINCREMENT,
+            telegram_id INTEGER NOT NULL,
+            window_label TEXT NOT NULL,
+            window_at DATETIME NOT NULL,
+            remind_at DATETIME NOT NULL,
+            sent_at DATETIME,
+            UNIQUE (telegram_id, window_at, remind_at)
+        );
+		CREATE INDEX IF NOT EXISTS idx_reminders_due ON reminders(sent_at, remind_at);
+    `)
+	if err != nil {
+		panic(fmt.Errorf("creating reminders table: %w", err))
+	}
+
+	return &sqliteReminderRepo{db: db}
+}
+
+// ReplacePending drops reminders of the user that weren't sent yet and plans the new ones
+func (r *sqliteReminderRepo) ReplacePending(userID int64, reminders []*models.Reminder) error {
+	return r.replace(`DELETE FROM reminders WHERE sent_at IS NULL AND telegram_id = ?`, []any{userID}, reminders)
+}
+
+// ReplaceAllPending drops every reminder that wasn't sent yet and plans the new ones
+func (r *sqliteReminderRepo) ReplaceAllPending(reminders []*models.Reminder) error {
+	return r.replace(`DELETE FROM reminders WHERE sent_at IS NULL`, nil, reminders)
+}
+
+func (r *sqliteReminderRepo) replace(deleteQuery string, args []any, reminders []*models.Reminder) error {
+	tx, err := r.db.BeginTx(context.Background(), nil)
+	if err != nil {
+		return fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(deleteQuery, args...); err != nil {
+		return fmt.Errorf("deleting pending reminders: %w", err)
+	}
+
+	stmt, err := tx.Prepare(`
+		INSERT OR IGNORE INTO reminders (telegram_id, window_label, window_at, remind_at)
+		VALUES (?, ?, ?, ?)
+	`)
+	if err != nil {
+		return fmt.Errorf("preparing reminder insertion: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, rem := range reminders {
+		_, err := stmt.Exec(rem.TelegramID, rem.WindowLabel, rem.WindowAt.UTC(), rem.RemindAt.UTC())
+		if err != nil {
+			return fmt.Errorf("inserting reminder: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("committing transaction: %w", err)
+	}
+	return nil
+}
+
+func (r *sqliteReminderRepo) Due(now time.Time) ([]*models.Reminder, error) {
+	rows, err := r.db.Query(`
+        SELECT id, telegram_id, window_label, window_at, remind_at
+        FROM reminders
+        WHERE sent_at IS NULL AND remind_at <= ? AND `+activeUsersOnly+`
+        ORDER BY remind_at
+    `, now.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("getting due reminders: %w", err)
+	}
+	defer rows.Close()
+
+	var reminders []*models.Reminder
+	for rows.Next() {
+		var rem models.Reminder
+		if err := rows.Scan(&rem.ID, &rem.TelegramID, &rem.WindowLabel, &rem.WindowAt, &rem.RemindAt); err != nil {
+			return nil, fmt.Errorf("scanning reminder: %w", err)
+		}
+		reminders = append(reminders, &rem)
+	}
+	return reminders, rows.Err()
+}
+
+func (r *sqliteReminderRepo) MarkSent(ids []int64, at time.Time) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	args := []any{at.UTC()}
+	for _, id := range ids {
+		args = append(args, id)
+	}
+	_, err := r.db.Exec(`UPDATE reminders SET sent_at = ? WHERE id IN (?`+strings.Repeat(", ?", len(ids)-1)+`)`, args...)
+	if err != nil {
+		return fmt.Errorf("marking reminders sent: %w", err)
+	}
+	return nil
+}
+
+// PurgeBefore removes reminders about windows that were over before t
+func (r *sqliteReminderRepo) PurgeBefore(t time.Time) (int64, error) {
+	res, err := r.db.Exec(`DELETE FROM reminders WHERE window_at < ?`, t.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("purging reminders: %w", err)
+	}
+	return res.RowsAffected()
+}
@@ -4,22 +4,30 @@ import (
 	"database/sql"
 	"fmt"
 	"time"
+
+	"github.com/TheTeemka/telegram_bot_cources/internal/clock"
+	"github.com/TheTeemka/telegram_bot_cources/internal/database"
+	"github.com/TheTeemka/telegram_bot_cources/internal/models"
 )
 
 type StateRepository interface {
-	Upsert(telegram_id int64, state string) error
-	GetState(telegram_id int64) (string, error)
+	Save(state *models.ChatState) error
+	// Get returns nil when the user is not in a dialog or it has expired
+	Get(telegramID int64) (*models.ChatState, error)
+	Clear(telegramID int64) error
+	PurgeExpired(now time.Time) (int64, error)
 }
 
 type stateRepository struct {
-	db *sql.DB
+	db    *sql.DB
+	clock clock.Clock
 }
 
-func NewStateRepository(db *sql.DB) StateRepository {
+func NewStateRepository(db *sql.DB, clk clock.Clock) StateRepository {
 	_, err := db.Exec(`
 		CREATE TABLE IF NOT EXISTS chat_states (
-			telegram_id INTEGER NOT NULL, 
-			state TEXT , 
+			telegram_id INTEGER NOT NULL,
+			state TEXT ,
 			created_at  DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP ,
 			updated_at DATETIME ,
 			PRIMARY KEY (telegram_id)
@@ -28,34 +36,78 @@ func NewStateRepository(db *sql.DB) StateRepository {
 	if err != nil {
 		panic(err)
 	}
-	return &stateRepository{db: db}
+	columns := [][2]string{
+		{"step", "INTEGER NOT NULL DEFAULT 0"},
+		{"payload", "TEXT"},
+		{"expires_at", "DATETIME"},
+	}
+	for _, c := range columns {
+		if err := database.EnsureColumn(db, "chat_states", c[0], c[1]); err != nil {
+			panic(fmt.Errorf("migrating chat_states table: %w", err))
+		}
+	}
+	return &stateRepository{db: db, clock: clk}
 }
 
-func (r *stateRepository) Upsert(telegram_id int64, state string) error {
+func (r *stateRepository) Save(state *models.ChatState) error {
 	query := `
-		INSERT OR REPLACE INTO chat_states (telegram_id, state, updated_at)
-        VALUES (?, ?, ?)`
+		INSERT OR REPLACE INTO chat_states (telegram_id, state, step, payload, expires_at, updated_at)
+        VALUES (?, ?, ?, ?, ?, ?)`
 
-	location := time.FixedZone("UTC+5", 5*60*60)
-	_, err := r.db.Exec(query, telegram_id, state, time.Now().In(location))
+	var payload any
+	if len(state.Payload) != 0 {
+		payload = string(state.Payload)
+	}
+	_, err := r.db.Exec(query, state.TelegramID, string(state.Name), state.Step, payload,
+		state.ExpiresAt.UTC(), r.clock.Now().UTC())
 	if err != nil {
-		return fmt.Errorf("upserting chat state: %w", err)
+		return fmt.Errorf("saving chat state: %w", err)
 	}
 	return nil
 }
 
-func (r *stateRepository) GetState(telegram_id int64) (string, error) {
+func (r *stateRepository) Get(telegramID int64) (*models.ChatState, error) {
 	query := `
-		SELECT state FROM chat_states 
-		WHERE telegram_id = ?`
+		SELECT state, step, payload, expires_at FROM chat_states
+		WHERE telegram_id = ? AND state != '' AND expires_at > ?`
 
-	var state string
-	err := r.db.QueryRow(query, telegram_id).Scan(&state)
+	state := &models.ChatState{TelegramID: telegramID}
+	var payload sql.NullString
+	err := r.db.QueryRow(query, telegramID, r.clock.Now().UTC()).Scan(&state.Name, &state.Step, &payload, &state.ExpiresAt)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return "", nil
+			return nil, nil
 		}
-		return "", fmt.Errorf("upserting chat state: %w", err)
+		return nil, fmt.Errorf("getting chat state: %w", err)
+	}
+	if payload.Valid {
+		state.Payload = []byte(payload.String)
 	}
 	return state, nil
 }
+
+func (r *stateRepository) Clear(telegramID int64) error {
+	_, err := r.db.Exec(`DELETE FROM chat_states WHERE telegram_id = ?`, telegramID)
+	if err != nil {
+		return fmt.Errorf("clearing chat state: %w", err)
+	}
+	return nil
+}
+
+// PurgeExpired removes states of users who started a dialog and never finished it.
+// States saved before they had an expiry are dropped as well
+func (r *stateRepository) PurgeExpired(now time.Time) (int64, error) {
+	query := `
+		DELETE FROM chat_states
+		WHERE expires_at IS NULL OR expires_at <= ?`
+
+	res, err := r.db.Exec(query, now.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("purging expired chat states: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("purging expired chat states: %w", err)
+	}
+	return n, nil
+}
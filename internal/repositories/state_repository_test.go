@@ -0,0 +1,56 @@
+package repositories
+
+import (
+	"testing"
+	"time"
+
+	"github.com/TheTeemka/telegram_bot_cources/internal/clock"
+	"github.com/TheTeemka/telegram_bot_cources/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStateRepository(t *testing.T) {
+	clk := clock.NewFake(time.Date(2025, 12, 17, 9, 0, 0, 0, time.UTC))
+	db := newTestDB(t)
+	repo := NewStateRepository(db, clk)
+
+	state := &models.ChatState{TelegramID: 1, Name: models.StateSubscribe, Step: 1, ExpiresAt: clk.Now().Add(30 * time.Minute)}
+	assert.NoError(t, state.SetPayload(map[string]string{"course": "PHYS 161"}))
+	assert.NoError(t, repo.Save(state))
+
+	got, err := repo.Get(1)
+	assert.NoError(t, err)
+	if assert.NotNil(t, got) {
+		assert.Equal(t, state.Name, got.Name)
+		assert.Equal(t, state.Step, got.Step)
+		assert.JSONEq(t, string(state.Payload), string(got.Payload))
+		assert.True(t, state.ExpiresAt.Equal(got.ExpiresAt))
+	}
+
+	clk.Advance(30 * time.Minute)
+	got, err = repo.Get(1)
+	assert.NoError(t, err)
+	assert.True(t, got.Idle(), "expired states are ignored")
+
+	n, err := repo.PurgeExpired(clk.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+}
+
+func TestStateRepositoryDropsLegacyStates(t *testing.T) {
+	clk := clock.NewFake(time.Date(2025, 12, 17, 9, 0, 0, 0, time.UTC))
+	db := newTestDB(t)
+	repo := NewStateRepository(db, clk)
+
+	// saved as a plain string before states had a step, a payload and an expiry
+	_, err := db.Exec(`INSERT INTO chat_states (telegram_id, state) VALUES (1, 'subscribe')`)
+	assert.NoError(t, err)
+
+	got, err := repo.Get(1)
+	assert.NoError(t, err)
+	assert.True(t, got.Idle(), "states without an expiry are ignored")
+
+	n, err := repo.PurgeExpired(clk.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+}
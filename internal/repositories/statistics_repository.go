@@ -7,15 +7,20 @@ import (
 	"log/slog"
 	"sync"
 	"time"
+
+	"github.com/TheTeemka/telegram_bot_cources/internal/clock"
 )
 
+const statisticsFlushInterval = 3 * time.Hour
+
 type StatisticsRepository struct {
 	db    *sql.DB
+	clock clock.Clock
 	mux   sync.RWMutex
 	Stats map[string]int64
 }
 
-func NewStatisticsRepository(db *sql.DB) *StatisticsRepository {
+func NewStatisticsRepository(db *sql.DB, clk clock.Clock) *StatisticsRepository {
 	_, err := db.Exec(`
 		CREATE TABLE IF NOT EXISTS statistics (
 			action TEXT , 
@@ -28,6 +33,7 @@ func NewStatisticsRepository(db *sql.DB) *StatisticsRepository {
 	}
 	return &StatisticsRepository{
 		db:    db,
+		clock: clk,
 		Stats: map[string]int64{},
 	}
 
@@ -40,7 +46,8 @@ func (r *StatisticsRepository) AddOne(action string) {
 }
 
 func (r *StatisticsRepository) Run(ctx context.Context) {
-	ticker := time.NewTicker(3 * time.Hour)
+	ticker := r.clock.NewTicker(statisticsFlushInterval)
+	defer ticker.Stop()
 
 	for {
 		select {
@@ -50,7 +57,7 @@ func (r *StatisticsRepository) Run(ctx context.Context) {
 				slog.Error("Failed to upsert statistics", "error", err)
 			}
 			return
-		case <-ticker.C:
+		case <-ticker.C():
 			_, err := r.Upsert()
 			if err != nil {
 				slog.Error("Failed to upsert statistics", "error", err)
@@ -61,6 +68,7 @@ func (r *StatisticsRepository) Run(ctx context.Context) {
 
 func (r *StatisticsRepository) Upsert() (int, error) {
 	r.mux.Lock()
+	defer r.mux.Unlock()
 
 	slog.Info("Upserting statistics", "len", len(r.Stats))
 	query := `
@@ -89,33 +97,38 @@ func (r *StatisticsRepository) Upsert() (int, error) {
 	if err != nil {
 		return cnt, fmt.Errorf("committing transaction: %w", err)
 	}
-	r.mux.Unlock()
 
 	return cnt, nil
 }
 
-// func (r *StatisticsRepository) GetAll() error {
-// 	query := `
-// 		SELECT action, count FROM statistics`
-// 	rows, err := r.db.Query(query)
-// 	if err != nil {
-// 		return fmt.Errorf("querying statistics: %w", err)
-// 	}
-// 	defer rows.Close()
-
-// 	stats := make(map[string]int64)
-// 	var action string
-// 	var count int64
-// 	for rows.Next() {
-// 		if err := rows.Scan(&action, &count); err != nil {
-// 			return fmt.Errorf("scanning row: %w", err)
-// 		}
-// 		stats[action] = count
-// 	}
-
-// 	if err := rows.Err(); err != nil {
-// 		return fmt.Errorf("error in rows: %w", err)
-// 	}
-// 	r.Stats = stats
-// 	return nil
-// }
+// GetAll returns saved counters together with the ones not upserted yet
+func (r *StatisticsRepository) GetAll() (map[string]int64, error) {
+	query := `
+		SELECT action, count FROM statistics`
+	rows, err := r.db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("querying statistics: %w", err)
+	}
+	defer rows.Close()
+
+	stats := make(map[string]int64)
+	var action string
+	var count int64
+	for rows.Next() {
+		if err := rows.Scan(&action, &count); err != nil {
+			return nil, fmt.Errorf("scanning row: %w", err)
+		}
+		stats[action] = count
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error in rows: %w", err)
+	}
+
+	r.mux.RLock()
+	for action, count := range r.Stats {
+		stats[action] += count
+	}
+	r.mux.RUnlock()
+	return stats, nil
+}
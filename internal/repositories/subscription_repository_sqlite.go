@@ -4,8 +4,11 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"strings"
 	"time"
 
+	"github.com/TheTeemka/telegram_bot_cources/internal/clock"
+	"github.com/TheTeemka/telegram_bot_cources/internal/database"
 	"github.com/TheTeemka/telegram_bot_cources/internal/models"
 	_ "github.com/mattn/go-sqlite3"
 )
@@ -14,18 +17,35 @@ type CourseSubscriptionRepository interface {
 	Subscribe(int64, string, []string) error
 	GetSubscriptions(int64) ([]*models.CourseSubscription, error)
 	GetAll() ([]*models.CourseSubscription, error)
-	Update(*models.CourseSubscription) error
+	GetBySections(course string, sections []string) ([]*models.CourseSubscription, error)
+	GetPendingSince(since, now time.Time) ([]*models.CourseSubscription, error)
 	UnSubscribe(int64, string) error
 	UnSubscribeSection(int64, string, string) error
+	GetCourseSubscribers(course string) ([]int64, error)
+	ToggleNewSections(userID int64, course string) (enabled, subscribed bool, err error)
+	SetUrgent(userID int64, course string, sections []string, urgent bool) (int64, error)
+	Snooze(userID int64, course string, section string, until time.Time) (bool, error)
+	GetNewSectionsWatchers(course string) ([]int64, error)
+	GetMostSubscribed(limit int) ([]*models.SectionPopularity, error)
+
+	SetExpiry(userID int64, course string, sections []string, expiresAt time.Time) (int64, error)
+	ApplyExpiryPolicy(createdBefore, expiresAt time.Time) (int64, error)
+	GetExpiring(before time.Time) ([]*models.CourseSubscription, error)
+	KeepWatching(userID int64, course string, until time.Time) (int64, error)
+	PurgeExpired(now time.Time) (int64, error)
 
 	ClearSubscriptions(int64) error
+
+	SaveStatesTx(tx *sql.Tx, subs []*models.CourseSubscription) error
+	MarkExpiryRemindedTx(tx *sql.Tx, subs []*models.CourseSubscription) error
 }
 
 type sqliteSubscriptionRepo struct {
-	db *sql.DB
+	db    *sql.DB
+	clock clock.Clock
 }
 
-func NewSQLiteSubscriptionRepo(db *sql.DB) CourseSubscriptionRepository {
+func NewSQLiteSubscriptionRepo(db *sql.DB, clk clock.Clock) CourseSubscriptionRepository {
 	_, err := db.Exec(`
         CREATE TABLE IF NOT EXISTS subscriptions (
             telegram_id INTEGER NOT NULL,
@@ -37,20 +57,63 @@ func NewSQLiteSubscriptionRepo(db *sql.DB) CourseSubscriptionRepository {
             PRIMARY KEY (telegram_id, course, section)
         );
 		CREATE INDEX IF NOT EXISTS idx_subscriptions_telegram_id ON subscriptions(telegram_id);
-    	CREATE INDEX IF NOT EXISTS idx_subscriptions_course ON subscriptions(course);
+		DROP INDEX IF EXISTS idx_subscriptions_course;
+		CREATE INDEX IF NOT EXISTS idx_subscriptions_course_section ON subscriptions(course, section);
     `)
 
 	if err != nil {
 		panic(fmt.Errorf("creating subscriptions table: %w", err))
 	}
+	columns := [][2]string{
+		{"urgent", "BOOLEAN DEFAULT FALSE"},
+		{"snoozed_until", "DATETIME"},
+		{"expires_at", "DATETIME"},
+		{"expiry_reminded", "BOOLEAN DEFAULT FALSE"},
+		{"expiry_exempt", "BOOLEAN DEFAULT FALSE"},
+		{"notify_new_sections", "BOOLEAN DEFAULT FALSE"},
+	}
+	for _, c := range columns {
+		if err := database.EnsureColumn(db, "subscriptions", c[0], c[1]); err != nil {
+			panic(fmt.Errorf("migrating subscriptions table: %w", err))
+		}
+	}
+	if err := migrateCourseSettings(db); err != nil {
+		panic(fmt.Errorf("migrating course settings: %w", err))
+	}
 
-	return &sqliteSubscriptionRepo{db: db}
+	return &sqliteSubscriptionRepo{db: db, clock: clk}
 }
 
+// migrateCourseSettings moves the new sections flag of older databases from the course_settings
+// table to the subscriptions of the course. Flags of courses without subscriptions are dropped
+func migrateCourseSettings(db *sql.DB) error {
+	return database.InTx(db, func(tx *sql.Tx) error {
+		var n int
+		err := tx.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'course_settings'`).Scan(&n)
+		if err != nil || n == 0 {
+			return err
+		}
+
+		_, err = tx.Exec(`
+			UPDATE subscriptions SET notify_new_sections = TRUE
+			WHERE EXISTS (
+				SELECT 1 FROM course_settings cs
+				WHERE cs.telegram_id = subscriptions.telegram_id AND cs.course = subscriptions.course
+				  AND cs.notify_new_sections = TRUE
+			);
+			DROP TABLE course_settings;
+		`)
+		return err
+	})
+}
+
+// Subscribe adds sections to the subscription of the course, they follow its new sections flag
 func (r *sqliteSubscriptionRepo) Subscribe(telegramID int64, course string, sections []string) error {
 	query := `
-		INSERT OR IGNORE INTO subscriptions (telegram_id, course, section, updated_at)
-        VALUES (?, ?, ?, ?)
+		INSERT OR IGNORE INTO subscriptions (telegram_id, course, section, created_at, updated_at, notify_new_sections)
+        VALUES (?, ?, ?, ?, ?, (
+            SELECT COALESCE(MAX(notify_new_sections), FALSE) FROM subscriptions WHERE telegram_id = ? AND course = ?
+        ))
     `
 	tx, err := r.db.BeginTx(context.Background(), nil)
 	if err != nil {
@@ -58,7 +121,8 @@ func (r *sqliteSubscriptionRepo) Subscribe(telegramID int64, course string, sect
 	}
 
 	for _, sect := range sections {
-		_, err = tx.Exec(query, telegramID, course, sect, time.Now())
+		now := r.clock.Now().UTC()
+		_, err = tx.Exec(query, telegramID, course, sect, now, now, telegramID, course)
 		if err != nil {
 
 			tx.Rollback()
@@ -104,7 +168,7 @@ func (r *sqliteSubscriptionRepo) UnSubscribeSection(userID int64, course string,
 func (r *sqliteSubscriptionRepo) ClearSubscriptions(userID int64) error {
 	query := `
 		DELETE FROM subscriptions 
-		WHERE telegram_id = ? 
+		WHERE telegram_id = ?
     `
 
 	_, err := r.db.Exec(query, userID)
@@ -117,7 +181,7 @@ func (r *sqliteSubscriptionRepo) ClearSubscriptions(userID int64) error {
 
 func (r *sqliteSubscriptionRepo) GetSubscriptions(userID int64) ([]*models.CourseSubscription, error) {
 	rows, err := r.db.Query(`
-        SELECT telegram_id, course, section, is_full
+        SELECT telegram_id, course, section, is_full, urgent, snoozed_until, expires_at
         FROM subscriptions
         WHERE telegram_id = ?
         ORDER BY course ASC, section ASC
@@ -128,61 +192,370 @@ func (r *sqliteSubscriptionRepo) GetSubscriptions(userID int64) ([]*models.Cours
 	}
 	defer rows.Close()
 
+	return scanSubscriptions(rows)
+}
+
+func (r *sqliteSubscriptionRepo) GetAll() ([]*models.CourseSubscription, error) {
+	rows, err := r.db.Query(`
+        SELECT telegram_id, course, section, is_full, urgent, snoozed_until, expires_at
+        FROM subscriptions
+        WHERE ` + activeUsersOnly + `
+    `)
+
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanSubscriptions(rows)
+}
+
+// GetBySections returns subscriptions to the given sections of the course
+func (r *sqliteSubscriptionRepo) GetBySections(course string, sections []string) ([]*models.CourseSubscription, error) {
+	if len(sections) == 0 {
+		return nil, nil
+	}
+
+	args := []any{course}
+	for _, section := range sections {
+		args = append(args, section)
+	}
+	rows, err := r.db.Query(`
+        SELECT telegram_id, course, section, is_full, urgent, snoozed_until, expires_at
+        FROM subscriptions
+        WHERE course = ? AND section IN (?`+strings.Repeat(", ?", len(sections)-1)+`) AND `+activeUsersOnly+`
+    `, args...)
+	if err != nil {
+		return nil, fmt.Errorf("getting subscriptions of sections: %w", err)
+	}
+	defer rows.Close()
+
+	return scanSubscriptions(rows)
+}
+
+// GetPendingSince returns subscriptions the tracker skipped since the given time: new ones,
+// the ones whose snooze ended by now and the ones of users who came back
+func (r *sqliteSubscriptionRepo) GetPendingSince(since, now time.Time) ([]*models.CourseSubscription, error) {
+	rows, err := r.db.Query(`
+        SELECT telegram_id, course, section, is_full, urgent, snoozed_until, expires_at
+        FROM subscriptions
+        WHERE (created_at >= ? OR (snoozed_until > ? AND snoozed_until <= ?) OR `+reactivatedSince+`)
+            AND `+activeUsersOnly+`
+    `, since.UTC(), since.UTC(), now.UTC(), since.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("getting pending subscriptions: %w", err)
+	}
+	defer rows.Close()
+
+	return scanSubscriptions(rows)
+}
+
+// SaveStatesTx saves whether the sections were full, as the tracker saw them, within its transaction
+func (r *sqliteSubscriptionRepo) SaveStatesTx(tx *sql.Tx, subs []*models.CourseSubscription) error {
+	if len(subs) == 0 {
+		return nil
+	}
+
+	stmt, err := tx.Prepare(`
+		UPDATE subscriptions
+		SET updated_at = ?, is_full = ?
+		WHERE telegram_id = ? AND course = ? AND section = ?
+	`)
+	if err != nil {
+		return fmt.Errorf("preparing subscription update: %w", err)
+	}
+	defer stmt.Close()
+
+	now := r.clock.Now().UTC()
+	for _, sub := range subs {
+		if _, err := stmt.Exec(now, sub.IsFull, sub.TelegramID, sub.Course, sub.Section); err != nil {
+			return fmt.Errorf("updating subscription: %w", err)
+		}
+	}
+	return nil
+}
+
+func scanSubscriptions(rows *sql.Rows) ([]*models.CourseSubscription, error) {
 	var subs []*models.CourseSubscription
 	for rows.Next() {
-		var sub models.CourseSubscription
-		err := rows.Scan(&sub.TelegramID, &sub.Course, &sub.Section, &sub.IsFull)
+		var (
+			sub                     models.CourseSubscription
+			snoozedUntil, expiresAt sql.NullTime
+		)
+		err := rows.Scan(&sub.TelegramID, &sub.Course, &sub.Section, &sub.IsFull, &sub.Urgent, &snoozedUntil, &expiresAt)
 		if err != nil {
 			return nil, err
 		}
+		sub.SnoozedUntil = snoozedUntil.Time
+		sub.ExpiresAt = expiresAt.Time
 		subs = append(subs, &sub)
 	}
 
-	return subs, nil
+	return subs, rows.Err()
 }
 
-func (r *sqliteSubscriptionRepo) GetAll() ([]*models.CourseSubscription, error) {
+func (r *sqliteSubscriptionRepo) GetCourseSubscribers(course string) ([]int64, error) {
 	rows, err := r.db.Query(`
-        SELECT telegram_id, course, section, is_full
+        SELECT DISTINCT telegram_id
         FROM subscriptions
-    `)
+        WHERE course = ? AND `+activeUsersOnly+`
+    `, course)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanTelegramIDs(rows)
+}
+
+// ToggleNewSections flips the "notify me about new sections" flag of the subscription to the course
+// and returns its new value. Users without a subscription to the course have no flag to flip
+func (r *sqliteSubscriptionRepo) ToggleNewSections(userID int64, course string) (enabled, subscribed bool, err error) {
+	err = database.InTx(r.db, func(tx *sql.Tx) error {
+		var (
+			sections int
+			current  sql.NullBool
+		)
+		err := tx.QueryRow(`
+			SELECT COUNT(*), MAX(notify_new_sections)
+			FROM subscriptions
+			WHERE telegram_id = ? AND course = ?
+		`, userID, course).Scan(&sections, &current)
+		if err != nil || sections == 0 {
+			return err
+		}
 
+		subscribed, enabled = true, !current.Bool
+		_, err = tx.Exec(`
+			UPDATE subscriptions
+			SET notify_new_sections = ?, updated_at = ?
+			WHERE telegram_id = ? AND course = ?
+		`, enabled, r.clock.Now().UTC(), userID, course)
+		return err
+	})
+	if err != nil {
+		return false, false, fmt.Errorf("toggling new sections notifications: %w", err)
+	}
+	return enabled, subscribed, nil
+}
+
+func (r *sqliteSubscriptionRepo) GetNewSectionsWatchers(course string) ([]int64, error) {
+	rows, err := r.db.Query(`
+        SELECT DISTINCT telegram_id
+        FROM subscriptions
+        WHERE course = ? AND notify_new_sections = TRUE AND `+activeUsersOnly+`
+    `, course)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var subs []*models.CourseSubscription
+	return scanTelegramIDs(rows)
+}
+
+// SetUrgent marks sections as high-priority ones, notified instantly instead of in a digest.
+// Empty sections mean every section of the course
+func (r *sqliteSubscriptionRepo) SetUrgent(userID int64, course string, sections []string, urgent bool) (int64, error) {
+	query := `
+		UPDATE subscriptions
+		SET urgent = ?
+		WHERE telegram_id = ? AND course = ?
+    `
+	args := []any{urgent, userID, course}
+	if len(sections) != 0 {
+		query += " AND section IN (?" + strings.Repeat(", ?", len(sections)-1) + ")"
+		for _, sect := range sections {
+			args = append(args, sect)
+		}
+	}
+
+	res, err := r.db.Exec(query, args...)
+	if err != nil {
+		return 0, fmt.Errorf("setting urgent subscriptions: %w", err)
+	}
+	return res.RowsAffected()
+}
+
+func scanTelegramIDs(rows *sql.Rows) ([]int64, error) {
+	var ids []int64
 	for rows.Next() {
-		var sub models.CourseSubscription
-		err := rows.Scan(&sub.TelegramID, &sub.Course, &sub.Section, &sub.IsFull)
-		if err != nil {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
 			return nil, err
 		}
-		subs = append(subs, &sub)
+		ids = append(ids, id)
 	}
+	return ids, rows.Err()
+}
 
-	return subs, nil
+func (r *sqliteSubscriptionRepo) Close() error {
+	return r.db.Close()
 }
 
-func (r *sqliteSubscriptionRepo) Update(sub *models.CourseSubscription) error {
+// Snooze mutes notifications about the section till the given time. The tracker leaves the
+// subscription alone meanwhile and compares it with the catalog once the snooze is over
+func (r *sqliteSubscriptionRepo) Snooze(userID int64, course string, section string, until time.Time) (bool, error) {
 	query := `
-        UPDATE subscriptions
-        SET updated_at = ?, is_full = ?
-        WHERE telegram_id = ? AND course = ? AND section = ?
+		UPDATE subscriptions
+		SET snoozed_until = ?
+		WHERE telegram_id = ? AND course = ? AND section = ?
     `
 
-	location := time.FixedZone("UTC+5", 5*60*60)
-	_, err := r.db.Exec(query,
-		time.Now().In(location),
-		sub.IsFull,
-		sub.TelegramID,
-		sub.Course,
-		sub.Section,
-	)
-	return err
+	res, err := r.db.Exec(query, until.UTC(), userID, course, section)
+	if err != nil {
+		return false, fmt.Errorf("snoozing subscription: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("snoozing subscription: %w", err)
+	}
+	return n > 0, nil
 }
 
-func (r *sqliteSubscriptionRepo) Close() error {
-	return r.db.Close()
+// SetExpiry sets when subscriptions to the sections expire, zero time makes them never expire
+// regardless of the global policy. Empty sections mean every section of the course
+func (r *sqliteSubscriptionRepo) SetExpiry(userID int64, course string, sections []string, expiresAt time.Time) (int64, error) {
+	var expires any
+	if !expiresAt.IsZero() {
+		expires = expiresAt.UTC()
+	}
+
+	query := `
+		UPDATE subscriptions
+		SET expires_at = ?, expiry_exempt = ?, expiry_reminded = FALSE
+		WHERE telegram_id = ? AND course = ?
+    `
+	args := []any{expires, expiresAt.IsZero(), userID, course}
+	if len(sections) != 0 {
+		query += ` AND section IN (?` + strings.Repeat(", ?", len(sections)-1) + `)`
+		for _, section := range sections {
+			args = append(args, section)
+		}
+	}
+
+	res, err := r.db.Exec(query, args...)
+	if err != nil {
+		return 0, fmt.Errorf("setting subscription expiry: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("setting subscription expiry: %w", err)
+	}
+	return n, nil
+}
+
+// ApplyExpiryPolicy makes subscriptions created before the given time expire at the given
+// time, unless they already have their own expiry or the user asked to never expire them
+func (r *sqliteSubscriptionRepo) ApplyExpiryPolicy(createdBefore, expiresAt time.Time) (int64, error) {
+	res, err := r.db.Exec(`
+		UPDATE subscriptions
+		SET expires_at = ?
+		WHERE expires_at IS NULL AND expiry_exempt = FALSE AND created_at < ?
+    `, expiresAt.UTC(), createdBefore.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("applying expiry policy: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("applying expiry policy: %w", err)
+	}
+	return n, nil
+}
+
+// GetExpiring returns subscriptions expiring before the given time, whose users were not reminded yet
+func (r *sqliteSubscriptionRepo) GetExpiring(before time.Time) ([]*models.CourseSubscription, error) {
+	rows, err := r.db.Query(`
+        SELECT telegram_id, course, section, is_full, urgent, snoozed_until, expires_at
+        FROM subscriptions
+        WHERE expires_at <= ? AND expiry_reminded = FALSE AND `+activeUsersOnly+`
+        ORDER BY telegram_id ASC, course ASC, section ASC
+    `, before.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("getting expiring subscriptions: %w", err)
+	}
+	defer rows.Close()
+
+	return scanSubscriptions(rows)
+}
+
+// MarkExpiryRemindedTx marks subscriptions as reminded about their expiry within the transaction
+// enqueueing the reminder
+func (r *sqliteSubscriptionRepo) MarkExpiryRemindedTx(tx *sql.Tx, subs []*models.CourseSubscription) error {
+	if len(subs) == 0 {
+		return nil
+	}
+
+	stmt, err := tx.Prepare(`
+		UPDATE subscriptions
+		SET expiry_reminded = TRUE
+		WHERE telegram_id = ? AND course = ? AND section = ?
+	`)
+	if err != nil {
+		return fmt.Errorf("preparing expiry reminder update: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, sub := range subs {
+		if _, err := stmt.Exec(sub.TelegramID, sub.Course, sub.Section); err != nil {
+			return fmt.Errorf("marking expiry reminded: %w", err)
+		}
+	}
+	return nil
+}
+
+// KeepWatching postpones the expiry of every expiring subscription to the course
+func (r *sqliteSubscriptionRepo) KeepWatching(userID int64, course string, until time.Time) (int64, error) {
+	res, err := r.db.Exec(`
+		UPDATE subscriptions
+		SET expires_at = ?, expiry_reminded = FALSE
+		WHERE telegram_id = ? AND course = ? AND expires_at IS NOT NULL
+    `, until.UTC(), userID, course)
+	if err != nil {
+		return 0, fmt.Errorf("postponing subscription expiry: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("postponing subscription expiry: %w", err)
+	}
+	return n, nil
+}
+
+func (r *sqliteSubscriptionRepo) PurgeExpired(now time.Time) (int64, error) {
+	res, err := r.db.Exec(`
+		DELETE FROM subscriptions
+		WHERE expires_at <= ?
+    `, now.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("purging expired subscriptions: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("purging expired subscriptions: %w", err)
+	}
+	return n, nil
+}
+
+func (r *sqliteSubscriptionRepo) GetMostSubscribed(limit int) ([]*models.SectionPopularity, error) {
+	rows, err := r.db.Query(`
+        SELECT course, section, COUNT(*) AS subscribers
+        FROM subscriptions
+        WHERE `+activeUsersOnly+`
+        GROUP BY course, section
+        ORDER BY subscribers DESC, course ASC, section ASC
+        LIMIT ?
+    `, limit)
+	if err != nil {
+		return nil, fmt.Errorf("getting most subscribed sections: %w", err)
+	}
+	defer rows.Close()
+
+	var res []*models.SectionPopularity
+	for rows.Next() {
+		var p models.SectionPopularity
+		if err := rows.Scan(&p.Course, &p.Section, &p.Subscribers); err != nil {
+			return nil, fmt.Errorf("scanning most subscribed sections: %w", err)
+		}
+		res = append(res, &p)
+	}
+	return res, rows.Err()
 }
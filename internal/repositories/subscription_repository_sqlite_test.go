@@ -0,0 +1,114 @@
+package repositories
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/TheTeemka/telegram_bot_cources/internal/clock"
+	"github.com/TheTeemka/telegram_bot_cources/internal/database"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestDB(t *testing.T) *sql.DB {
+	db := database.NewSQLiteDB(filepath.Join(t.TempDir(), "db.db"))
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestApplyExpiryPolicy(t *testing.T) {
+	clk := clock.NewFake(time.Date(2025, 12, 10, 12, 0, 0, 0, time.UTC))
+	db := newTestDB(t)
+	NewSQLiteUserRepo(db, clk)
+	repo := NewSQLiteSubscriptionRepo(db, clk)
+
+	assert.NoError(t, repo.Subscribe(1, "PHYS 161", []string{"1L", "2L"}))
+	assert.NoError(t, repo.Subscribe(2, "PHYS 161", []string{"1L"}))
+	_, err := repo.SetExpiry(2, "PHYS 161", nil, time.Time{})
+	assert.NoError(t, err)
+	clk.Advance(time.Hour)
+	createdBefore := clk.Now()
+	assert.NoError(t, repo.Subscribe(3, "PHYS 161", []string{"1L"}))
+
+	expiresAt := time.Date(2025, 12, 20, 9, 0, 0, 0, time.UTC)
+	n, err := repo.ApplyExpiryPolicy(createdBefore, expiresAt)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), n, "only subscriptions created before the time without their own expiry")
+
+	subs, err := repo.GetSubscriptions(1)
+	assert.NoError(t, err)
+	for _, sub := range subs {
+		assert.True(t, expiresAt.Equal(sub.ExpiresAt), "%s expires at %s", sub.Section, sub.ExpiresAt)
+	}
+	for _, userID := range []int64{2, 3} {
+		subs, err := repo.GetSubscriptions(userID)
+		assert.NoError(t, err)
+		assert.True(t, subs[0].ExpiresAt.IsZero(), "subscription of user %d must not expire", userID)
+	}
+
+	n, err = repo.ApplyExpiryPolicy(clk.Now().Add(time.Hour), expiresAt.Add(time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), n, "expiries already set are kept")
+}
+
+func TestToggleNewSections(t *testing.T) {
+	clk := clock.NewFake(time.Date(2025, 12, 10, 12, 0, 0, 0, time.UTC))
+	db := newTestDB(t)
+	NewSQLiteUserRepo(db, clk)
+	repo := NewSQLiteSubscriptionRepo(db, clk)
+
+	_, subscribed, err := repo.ToggleNewSections(1, "PHYS 161")
+	assert.NoError(t, err)
+	assert.False(t, subscribed, "there is no subscription to keep the flag")
+
+	assert.NoError(t, repo.Subscribe(1, "PHYS 161", []string{"1L"}))
+	assert.NoError(t, repo.Subscribe(2, "PHYS 161", []string{"1L"}))
+	enabled, subscribed, err := repo.ToggleNewSections(1, "PHYS 161")
+	assert.NoError(t, err)
+	assert.True(t, subscribed)
+	assert.True(t, enabled)
+
+	assert.NoError(t, repo.Subscribe(1, "PHYS 161", []string{"2L"}))
+	assert.NoError(t, repo.UnSubscribeSection(1, "PHYS 161", "1L"))
+	watchers, err := repo.GetNewSectionsWatchers("PHYS 161")
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{1}, watchers, "sections added later follow the flag of the subscription")
+
+	assert.NoError(t, repo.UnSubscribe(1, "PHYS 161"))
+	assert.NoError(t, repo.Subscribe(1, "PHYS 161", []string{"1L"}))
+	watchers, err = repo.GetNewSectionsWatchers("PHYS 161")
+	assert.NoError(t, err)
+	assert.Empty(t, watchers, "the flag is gone with the subscription")
+}
+
+func TestMigrateCourseSettings(t *testing.T) {
+	clk := clock.NewFake(time.Date(2025, 12, 10, 12, 0, 0, 0, time.UTC))
+	db := newTestDB(t)
+	NewSQLiteUserRepo(db, clk)
+	repo := NewSQLiteSubscriptionRepo(db, clk)
+	assert.NoError(t, repo.Subscribe(1, "PHYS 161", []string{"1L", "2L"}))
+	assert.NoError(t, repo.Subscribe(2, "PHYS 161", []string{"1L"}))
+
+	_, err := db.Exec(`
+		CREATE TABLE course_settings (
+			telegram_id INTEGER NOT NULL,
+			course TEXT NOT NULL,
+			notify_new_sections BOOLEAN DEFAULT FALSE,
+			updated_at DATETIME,
+			PRIMARY KEY (telegram_id, course)
+		);
+		INSERT INTO course_settings (telegram_id, course, notify_new_sections)
+		VALUES (1, 'PHYS 161', TRUE), (2, 'PHYS 161', FALSE), (3, 'PHYS 161', TRUE);
+	`)
+	assert.NoError(t, err)
+
+	repo = NewSQLiteSubscriptionRepo(db, clk)
+	watchers, err := repo.GetNewSectionsWatchers("PHYS 161")
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{1}, watchers)
+
+	var n int
+	assert.NoError(t, db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE name = 'course_settings'`).Scan(&n))
+	assert.Zero(t, n)
+}
@@ -0,0 +1,59 @@
+package repositories
+
+import (
+	"database/sql"
+
+	"github.com/TheTeemka/telegram_bot_cources/internal/database"
+	"github.com/TheTeemka/telegram_bot_cources/internal/models"
+)
+
+// TrackingStore saves a tracker tick, or expiry reminders of the janitor, in a single transaction,
+// while every table is still written by the repository owning it. A notification is never lost
+// after the state it reports about was saved, nor sent twice
+type TrackingStore struct {
+	db               *sql.DB
+	subscriptionRepo CourseSubscriptionRepository
+	bundleRepo       BundleSubscriptionRepository
+	interestRepo     CourseInterestRepository
+	queueRepo        NotificationQueueRepository
+}
+
+func NewTrackingStore(db *sql.DB,
+	subscriptionRepo CourseSubscriptionRepository,
+	bundleRepo BundleSubscriptionRepository,
+	interestRepo CourseInterestRepository,
+	queueRepo NotificationQueueRepository) *TrackingStore {
+	return &TrackingStore{
+		db:               db,
+		subscriptionRepo: subscriptionRepo,
+		bundleRepo:       bundleRepo,
+		interestRepo:     interestRepo,
+		queueRepo:        queueRepo,
+	}
+}
+
+func (s *TrackingStore) Commit(changes *models.TrackingChanges) error {
+	return database.InTx(s.db, func(tx *sql.Tx) error {
+		if err := s.subscriptionRepo.SaveStatesTx(tx, changes.Subscriptions); err != nil {
+			return err
+		}
+		if err := s.bundleRepo.SaveStatesTx(tx, changes.Bundles); err != nil {
+			return err
+		}
+		if err := s.interestRepo.RemoveTx(tx, changes.Interests); err != nil {
+			return err
+		}
+		return s.queueRepo.EnqueueTx(tx, changes.Notifications)
+	})
+}
+
+// CommitExpiryReminders enqueues reminders about expiring subscriptions together with marking them
+// reminded, so a failure doesn't make the janitor remind about them again
+func (s *TrackingStore) CommitExpiryReminders(subs []*models.CourseSubscription, notifications []*models.Notification) error {
+	return database.InTx(s.db, func(tx *sql.Tx) error {
+		if err := s.subscriptionRepo.MarkExpiryRemindedTx(tx, subs); err != nil {
+			return err
+		}
+		return s.queueRepo.EnqueueTx(tx, notifications)
+	})
+}
@@ -0,0 +1,91 @@
+package repositories
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/TheTeemka/telegram_bot_cources/internal/clock"
+	"github.com/TheTeemka/telegram_bot_cources/internal/database"
+)
+
+// activeUsersOnly filters out rows of users the bot can't reach anymore
+const activeUsersOnly = "telegram_id NOT IN (SELECT telegram_id FROM users WHERE is_active = FALSE)"
+
+// reactivatedSince matches rows of users who came back after the time given as the argument,
+// their rows were skipped while they were away
+const reactivatedSince = "telegram_id IN (SELECT telegram_id FROM users WHERE reactivated_at >= ?)"
+
+type UserRepository interface {
+	Touch(userID int64, username string) (reactivated bool, err error)
+	Deactivate(userID int64, reason string) error
+}
+
+type sqliteUserRepo struct {
+	db    *sql.DB
+	clock clock.Clock
+}
+
+func NewSQLiteUserRepo(db *sql.DB, clk clock.Clock) UserRepository {
+	_, err := db.Exec(`
+        CREATE TABLE IF NOT EXISTS users (
+            telegram_id INTEGER NOT NULL,
+            username TEXT,
+            is_active BOOLEAN NOT NULL DEFAULT TRUE,
+            inactive_reason TEXT,
+            last_seen_at DATETIME,
+            created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+            updated_at DATETIME,
+            PRIMARY KEY (telegram_id)
+        );
+    `)
+	if err != nil {
+		panic(fmt.Errorf("creating users table: %w", err))
+	}
+	if err := database.EnsureColumn(db, "users", "reactivated_at", "DATETIME"); err != nil {
+		panic(fmt.Errorf("migrating users table: %w", err))
+	}
+
+	return &sqliteUserRepo{db: db, clock: clk}
+}
+
+// Touch records that the user wrote to the bot, which makes an inactive user active again
+func (r *sqliteUserRepo) Touch(userID int64, username string) (bool, error) {
+	var wasActive sql.NullBool
+	err := r.db.QueryRow(`SELECT is_active FROM users WHERE telegram_id = ?`, userID).Scan(&wasActive)
+	if err != nil && err != sql.ErrNoRows {
+		return false, fmt.Errorf("getting user: %w", err)
+	}
+
+	now := r.clock.Now().UTC()
+	_, err = r.db.Exec(`
+		INSERT INTO users (telegram_id, username, is_active, last_seen_at, updated_at)
+		VALUES (?, ?, TRUE, ?, ?)
+		ON CONFLICT(telegram_id) DO UPDATE SET
+			username = excluded.username,
+			is_active = TRUE,
+			inactive_reason = NULL,
+			reactivated_at = CASE WHEN users.is_active THEN users.reactivated_at ELSE excluded.last_seen_at END,
+			last_seen_at = excluded.last_seen_at,
+			updated_at = excluded.updated_at
+    `, userID, username, now, now)
+	if err != nil {
+		return false, fmt.Errorf("touching user: %w", err)
+	}
+
+	return wasActive.Valid && !wasActive.Bool, nil
+}
+
+func (r *sqliteUserRepo) Deactivate(userID int64, reason string) error {
+	_, err := r.db.Exec(`
+		INSERT INTO users (telegram_id, is_active, inactive_reason, updated_at)
+		VALUES (?, FALSE, ?, ?)
+		ON CONFLICT(telegram_id) DO UPDATE SET
+			is_active = FALSE,
+			inactive_reason = excluded.inactive_reason,
+			updated_at = excluded.updated_at
+    `, userID, reason, r.clock.Now())
+	if err != nil {
+		return fmt.Errorf("deactivating user: %w", err)
+	}
+	return nil
+}
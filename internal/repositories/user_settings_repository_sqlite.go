@@ -0,0 +1,156 @@
+package repositories
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/TheTeemka/telegram_bot_cources/internal/clock"
+	"github.com/TheTeemka/telegram_bot_cources/internal/database"
+	"github.com/TheTeemka/telegram_bot_cources/internal/models"
+)
+
+type UserSettingsRepository interface {
+	Get(userID int64) (*models.UserSettings, error)
+	SetQuietHours(userID int64, from, till *models.DayTime) error
+	SetDigestTime(userID int64, at *models.DayTime) error
+	SetStudyYear(userID int64, year int) error
+	GetStudyYears() (map[int64]int, error)
+}
+
+type sqliteUserSettingsRepo struct {
+	db    *sql.DB
+	clock clock.Clock
+}
+
+func NewSQLiteUserSettingsRepo(db *sql.DB, clk clock.Clock) UserSettingsRepository {
+	_, err := db.Exec(`
+        CREATE TABLE IF NOT EXISTS user_settings (
+            telegram_id INTEGER NOT NULL,
+            quiet_from INTEGER,
+            quiet_till INTEGER,
+            digest_at INTEGER,
+            updated_at DATETIME,
+            PRIMARY KEY (telegram_id)
+        );
+    `)
+	if err != nil {
+		panic(fmt.Errorf("creating user_settings table: %w", err))
+	}
+	if err := database.EnsureColumn(db, "user_settings", "study_year", "INTEGER"); err != nil {
+		panic(fmt.Errorf("migrating user_settings table: %w", err))
+	}
+
+	return &sqliteUserSettingsRepo{db: db, clock: clk}
+}
+
+// Get returns empty settings for users who never changed them
+func (r *sqliteUserSettingsRepo) Get(userID int64) (*models.UserSettings, error) {
+	query := `
+		SELECT quiet_from, quiet_till, digest_at, study_year
+		FROM user_settings
+		WHERE telegram_id = ?
+    `
+
+	var quietFrom, quietTill, digestAt, studyYear sql.NullInt64
+	err := r.db.QueryRow(query, userID).Scan(&quietFrom, &quietTill, &digestAt, &studyYear)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("getting user settings: %w", err)
+	}
+
+	return &models.UserSettings{
+		TelegramID: userID,
+		QuietFrom:  nullDayTime(quietFrom),
+		QuietTill:  nullDayTime(quietTill),
+		DigestAt:   nullDayTime(digestAt),
+		StudyYear:  int(studyYear.Int64),
+	}, nil
+}
+
+func (r *sqliteUserSettingsRepo) SetQuietHours(userID int64, from, till *models.DayTime) error {
+	query := `
+		INSERT INTO user_settings (telegram_id, quiet_from, quiet_till, updated_at)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT(telegram_id) DO UPDATE SET
+			quiet_from = excluded.quiet_from,
+			quiet_till = excluded.quiet_till,
+			updated_at = excluded.updated_at
+    `
+
+	_, err := r.db.Exec(query, userID, dayTimeValue(from), dayTimeValue(till), r.clock.Now())
+	if err != nil {
+		return fmt.Errorf("setting quiet hours: %w", err)
+	}
+	return nil
+}
+
+func (r *sqliteUserSettingsRepo) SetDigestTime(userID int64, at *models.DayTime) error {
+	query := `
+		INSERT INTO user_settings (telegram_id, digest_at, updated_at)
+		VALUES (?, ?, ?)
+		ON CONFLICT(telegram_id) DO UPDATE SET
+			digest_at = excluded.digest_at,
+			updated_at = excluded.updated_at
+    `
+
+	_, err := r.db.Exec(query, userID, dayTimeValue(at), r.clock.Now())
+	if err != nil {
+		return fmt.Errorf("setting digest time: %w", err)
+	}
+	return nil
+}
+
+// SetStudyYear stores the year of the user, zero forgets it
+func (r *sqliteUserSettingsRepo) SetStudyYear(userID int64, year int) error {
+	query := `
+		INSERT INTO user_settings (telegram_id, study_year, updated_at)
+		VALUES (?, ?, ?)
+		ON CONFLICT(telegram_id) DO UPDATE SET
+			study_year = excluded.study_year,
+			updated_at = excluded.updated_at
+    `
+
+	studyYear := sql.NullInt64{Int64: int64(year), Valid: year != 0}
+	_, err := r.db.Exec(query, userID, studyYear, r.clock.Now())
+	if err != nil {
+		return fmt.Errorf("setting study year: %w", err)
+	}
+	return nil
+}
+
+// GetStudyYears returns the year of every reachable user who picked one
+func (r *sqliteUserSettingsRepo) GetStudyYears() (map[int64]int, error) {
+	rows, err := r.db.Query(`
+		SELECT telegram_id, study_year
+		FROM user_settings
+		WHERE study_year IS NOT NULL AND ` + activeUsersOnly)
+	if err != nil {
+		return nil, fmt.Errorf("getting study years: %w", err)
+	}
+	defer rows.Close()
+
+	years := make(map[int64]int)
+	for rows.Next() {
+		var userID int64
+		var year int
+		if err := rows.Scan(&userID, &year); err != nil {
+			return nil, fmt.Errorf("scanning study year: %w", err)
+		}
+		years[userID] = year
+	}
+	return years, rows.Err()
+}
+
+func nullDayTime(v sql.NullInt64) *models.DayTime {
+	if !v.Valid {
+		return nil
+	}
+	d := models.DayTime(v.Int64)
+	return &d
+}
+
+func dayTimeValue(d *models.DayTime) sql.NullInt64 {
+	if d == nil {
+		return sql.NullInt64{}
+	}
+	return sql.NullInt64{Int64: int64(*d), Valid: true}
+}
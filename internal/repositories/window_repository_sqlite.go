@@ -0,0 +1,91 @@
+package repositories
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/TheTeemka/telegram_bot_cources/internal/database"
+	"github.com/TheTeemka/telegram_bot_cources/internal/ticker"
+)
+
+// RegistrationWindowRepository keeps registration windows added by admins at runtime
+type RegistrationWindowRepository interface {
+	Add(window ticker.TickerIntervalConfig, addedBy int64) (int64, error)
+	Delete(id int64) (bool, error)
+	GetAll() ([]ticker.TickerIntervalConfig, error)
+}
+
+type sqliteWindowRepo struct {
+	db *sql.DB
+}
+
+func NewSQLiteWindowRepo(db *sql.DB) RegistrationWindowRepository {
+	_, err := db.Exec(`
+        CREATE TABLE IF NOT EXISTS registration_windows (
+            id INTEGER PRIMARY KEY AUTOINCREMENT,
+            till DATETIME NOT NULL,
+            label TEXT NOT NULL,
+            added_by INTEGER NOT NULL,
+            created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+        )
+    `)
+	if err != nil {
+		panic(fmt.Errorf("creating registration_windows table: %w", err))
+	}
+	if err := database.EnsureColumn(db, "registration_windows", "profile", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		panic(fmt.Errorf("migrating registration_windows table: %w", err))
+	}
+
+	return &sqliteWindowRepo{db: db}
+}
+
+func (r *sqliteWindowRepo) Add(window ticker.TickerIntervalConfig, addedBy int64) (int64, error) {
+	res, err := r.db.Exec(`
+        INSERT INTO registration_windows (till, label, profile, added_by)
+        VALUES (?, ?, ?, ?)
+    `, window.Till.UTC(), window.Label, window.Profile, addedBy)
+	if err != nil {
+		return 0, fmt.Errorf("adding registration window: %w", err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("getting registration window id: %w", err)
+	}
+	return id, nil
+}
+
+func (r *sqliteWindowRepo) Delete(id int64) (bool, error) {
+	res, err := r.db.Exec(`DELETE FROM registration_windows WHERE id = ?`, id)
+	if err != nil {
+		return false, fmt.Errorf("deleting registration window: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("getting deleted registration windows: %w", err)
+	}
+	return n != 0, nil
+}
+
+func (r *sqliteWindowRepo) GetAll() ([]ticker.TickerIntervalConfig, error) {
+	rows, err := r.db.Query(`
+        SELECT id, till, label, profile
+        FROM registration_windows
+        ORDER BY till
+    `)
+	if err != nil {
+		return nil, fmt.Errorf("getting registration windows: %w", err)
+	}
+	defer rows.Close()
+
+	var windows []ticker.TickerIntervalConfig
+	for rows.Next() {
+		var w ticker.TickerIntervalConfig
+		if err := rows.Scan(&w.ID, &w.Till, &w.Label, &w.Profile); err != nil {
+			return nil, fmt.Errorf("scanning registration window: %w", err)
+		}
+		windows = append(windows, w)
+	}
+	return windows, rows.Err()
+}
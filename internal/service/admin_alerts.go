@@ -0,0 +1,111 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/TheTeemka/telegram_bot_cources/internal/clock"
+	"github.com/TheTeemka/telegram_bot_cources/internal/events"
+	"github.com/TheTeemka/telegram_bot_cources/internal/models"
+	"github.com/TheTeemka/telegram_bot_cources/internal/repositories"
+	"github.com/TheTeemka/telegram_bot_cources/internal/telegramfmt"
+)
+
+const (
+	// repeatFailureAlertEvery keeps a long outage from flooding admins
+	repeatFailureAlertEvery = 10
+	// removedSectionsAlertShare of the catalog disappearing at once usually means a broken parse
+	removedSectionsAlertShare = 0.2
+)
+
+// AdminAlerts tells admins about failing refreshes and suspicious catalog changes
+type AdminAlerts struct {
+	adminIDs     []int64
+	queueRepo    repositories.NotificationQueueRepository
+	outboxSignal chan<- struct{}
+	updates      <-chan events.CatalogUpdated
+	failures     <-chan events.CatalogRefreshFailed
+	clock        clock.Clock
+
+	failedInRow int
+}
+
+func NewAdminAlerts(adminIDs []int64,
+	queueRepo repositories.NotificationQueueRepository,
+	bus *events.Bus,
+	outboxSignal chan<- struct{},
+	clk clock.Clock) *AdminAlerts {
+	return &AdminAlerts{
+		adminIDs:     adminIDs,
+		queueRepo:    queueRepo,
+		outboxSignal: outboxSignal,
+		updates:      bus.CatalogUpdated.Subscribe(),
+		failures:     bus.CatalogRefreshFailed.Subscribe(),
+		clock:        clk,
+	}
+}
+
+func (a *AdminAlerts) Start(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			slog.Info("Admin alerts stopped")
+			return
+
+		case failure := <-a.failures:
+			a.failedInRow++
+			if a.failedInRow == 1 || a.failedInRow%repeatFailureAlertEvery == 0 {
+				a.alert(fmt.Sprintf("⚠️ Catalog refresh failed (%d in a row)\n<code>%s</code>",
+					a.failedInRow, telegramfmt.Escape(failure.Err.Error())))
+			}
+
+		case update := <-a.updates:
+			if a.failedInRow != 0 {
+				a.alert(fmt.Sprintf("✅ Catalog refresh recovered after %d failures", a.failedInRow))
+				a.failedInRow = 0
+			}
+			a.checkDiff(update.Diff)
+		}
+	}
+}
+
+func (a *AdminAlerts) checkDiff(diff *models.CatalogDiff) {
+	if diff == nil || diff.Initial {
+		return
+	}
+
+	var removed int
+	for _, c := range diff.Changes {
+		if c.IsRemoved() {
+			removed++
+		}
+	}
+	if removed != 0 && float64(removed) >= removedSectionsAlertShare*float64(diff.OldSections) {
+		a.alert(fmt.Sprintf("⚠️ %d sections disappeared from the catalog at once, check the source", removed))
+	}
+}
+
+func (a *AdminAlerts) alert(text string) {
+	slog.Warn("Admin alert", "text", text)
+
+	var notifications []*models.Notification
+	for _, id := range a.adminIDs {
+		notifications = append(notifications, &models.Notification{
+			TelegramID: id,
+			Kind:       models.NotificationAdminAlert,
+			Text:       text,
+			Urgent:     true,
+			DeliverAt:  a.clock.Now(),
+		})
+	}
+	if err := a.queueRepo.Enqueue(notifications); err != nil {
+		slog.Error("Failed to enqueue admin alert", "error", err)
+		return
+	}
+
+	select {
+	case a.outboxSignal <- struct{}{}:
+	default: // sender is already woken up
+	}
+}
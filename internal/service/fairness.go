@@ -0,0 +1,104 @@
+package service
+
+import (
+	"cmp"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"slices"
+	"time"
+
+	"github.com/TheTeemka/telegram_bot_cources/internal/models"
+	"github.com/TheTeemka/telegram_bot_cources/internal/repositories"
+)
+
+type FairnessPolicy string
+
+const (
+	FairnessRandom      FairnessPolicy = "random"       // shuffled on every opening
+	FairnessRoundRobin  FairnessPolicy = "round-robin"  // the first place moves to the next user on every opening
+	FairnessLeastRecent FairnessPolicy = "least-recent" // who waited the longest since the last notification goes first
+)
+
+func ParseFairnessPolicy(s string) (FairnessPolicy, error) {
+	switch p := FairnessPolicy(s); p {
+	case FairnessRandom, FairnessRoundRobin, FairnessLeastRecent:
+		return p, nil
+	default:
+		return "", fmt.Errorf("unknown fairness policy %q", s)
+	}
+}
+
+// Fairness orders users notified about the same free seat, so the same users don't always
+// hear about it first. Every policy reads the past from the notification log, so it survives restarts
+type Fairness struct {
+	policy    FairnessPolicy
+	queueRepo repositories.NotificationQueueRepository
+}
+
+func NewFairness(policy FairnessPolicy, queueRepo repositories.NotificationQueueRepository) *Fairness {
+	return &Fairness{
+		policy:    policy,
+		queueRepo: queueRepo,
+	}
+}
+
+// Order sorts notifications about a free seat in the section and numbers their positions
+func (f *Fairness) Order(course, section string, notifications []*models.Notification) {
+	switch f.policy {
+	case FairnessRoundRobin:
+		f.roundRobin(course, section, notifications)
+	case FairnessLeastRecent:
+		f.leastRecent(course, section, notifications)
+	default:
+		rand.Shuffle(len(notifications), func(i, j int) {
+			notifications[i], notifications[j] = notifications[j], notifications[i]
+		})
+	}
+
+	for i, n := range notifications {
+		n.Position = i + 1
+		n.Recipients = len(notifications)
+	}
+}
+
+// roundRobin starts right after the user who was first the last time
+func (f *Fairness) roundRobin(course, section string, notifications []*models.Notification) {
+	slices.SortFunc(notifications, func(a, b *models.Notification) int {
+		return cmp.Compare(a.TelegramID, b.TelegramID)
+	})
+
+	cursor, err := f.queueRepo.LastFirstNotified(course, section)
+	if err != nil {
+		slog.Error("Failed to get the last first notified user, starting from the lowest id", "error", err,
+			"course", course, "section", section)
+	}
+	start := 0
+	if cursor != 0 {
+		start = slices.IndexFunc(notifications, func(n *models.Notification) bool {
+			return n.TelegramID > cursor
+		})
+		if start == -1 {
+			start = 0
+		}
+	}
+	rotated := append(slices.Clone(notifications[start:]), notifications[:start]...)
+	copy(notifications, rotated)
+}
+
+func (f *Fairness) leastRecent(course, section string, notifications []*models.Notification) {
+	lastNotified, err := f.queueRepo.LastNotified(course, section)
+	if err != nil {
+		slog.Error("Failed to get last notified users, falling back to random order", "error", err,
+			"course", course, "section", section)
+		lastNotified = map[int64]time.Time{}
+	}
+
+	// shuffling first breaks ties between users who were never notified randomly
+	rand.Shuffle(len(notifications), func(i, j int) {
+		notifications[i], notifications[j] = notifications[j], notifications[i]
+	})
+	slices.SortStableFunc(notifications, func(a, b *models.Notification) int {
+		return lastNotified[a.TelegramID].Compare(lastNotified[b.TelegramID])
+	})
+}
@@ -0,0 +1,69 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/TheTeemka/telegram_bot_cources/internal/clock"
+	"github.com/TheTeemka/telegram_bot_cources/internal/models"
+	"github.com/TheTeemka/telegram_bot_cources/internal/repositories"
+	"github.com/stretchr/testify/assert"
+)
+
+// newFairnessTest returns a function ordering and enqueueing an opening of CSCI 151 1L for the users,
+// one minute after the previous one
+func newFairnessTest(t *testing.T, policy FairnessPolicy) func(ids ...int64) []int64 {
+	clk := clock.NewFake(time.Date(2025, 12, 19, 15, 0, 0, 0, time.UTC))
+	queueRepo := repositories.NewSQLiteNotificationQueueRepo(newTestDB(t, clk), clk)
+
+	return func(ids ...int64) []int64 {
+		clk.Advance(time.Minute)
+		// every run starts with a new instance, nothing may be kept in memory
+		f := NewFairness(policy, queueRepo)
+
+		var notifications []*models.Notification
+		for _, id := range ids {
+			notifications = append(notifications, &models.Notification{
+				TelegramID: id,
+				Kind:       models.NotificationSeat,
+				Course:     "CSCI 151",
+				Section:    "1L",
+				Text:       "opened",
+				DeliverAt:  clk.Now(),
+			})
+		}
+		f.Order("CSCI 151", "1L", notifications)
+		assert.NoError(t, queueRepo.Enqueue(notifications))
+
+		res := make([]int64, 0, len(notifications))
+		for i, n := range notifications {
+			assert.Equal(t, i+1, n.Position)
+			assert.Equal(t, len(ids), n.Recipients)
+			res = append(res, n.TelegramID)
+		}
+		return res
+	}
+}
+
+func TestFairnessRoundRobin(t *testing.T) {
+	order := newFairnessTest(t, FairnessRoundRobin)
+
+	assert.Equal(t, []int64{1, 2, 3}, order(3, 1, 2))
+	assert.Equal(t, []int64{2, 3, 1}, order(3, 1, 2))
+	assert.Equal(t, []int64{3, 1, 2}, order(1, 2, 3))
+	assert.Equal(t, []int64{4, 1, 2}, order(1, 2, 4))
+	assert.Equal(t, []int64{1, 2, 4}, order(1, 2, 4))
+}
+
+func TestFairnessLeastRecent(t *testing.T) {
+	order := newFairnessTest(t, FairnessLeastRecent)
+
+	assert.Equal(t, []int64{1}, order(1))
+	assert.Equal(t, []int64{2}, order(2))
+	assert.Equal(t, []int64{3, 1}, order(1, 3), "never notified users go first")
+
+	// 4 was never notified, 2 was notified before 1 and 3, which were notified together last
+	res := order(3, 1, 2, 4)
+	assert.Equal(t, []int64{4, 2}, res[:2])
+	assert.ElementsMatch(t, []int64{1, 3}, res[2:])
+}
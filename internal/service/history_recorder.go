@@ -0,0 +1,46 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/TheTeemka/telegram_bot_cources/internal/events"
+	"github.com/TheTeemka/telegram_bot_cources/internal/repositories"
+)
+
+// HistoryRecorder saves enrollment of every changed section after each refresh and tells
+// readers of the history that it is up to date
+type HistoryRecorder struct {
+	historyRepo repositories.EnrollmentHistoryRepository
+	bus         *events.Bus
+	updates     <-chan events.CatalogUpdated
+}
+
+func NewHistoryRecorder(historyRepo repositories.EnrollmentHistoryRepository, bus *events.Bus) *HistoryRecorder {
+	return &HistoryRecorder{
+		historyRepo: historyRepo,
+		bus:         bus,
+		updates:     bus.CatalogUpdated.Subscribe(),
+	}
+}
+
+func (h *HistoryRecorder) Start(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			slog.Info("History recorder stopped")
+			return
+
+		case update := <-h.updates:
+			if update.Diff != nil {
+				if err := h.historyRepo.Record(update.Diff.Changes, update.ParsedAt); err != nil {
+					slog.Error("Failed to record enrollment history", "error", err, "changes", len(update.Diff.Changes))
+				}
+			}
+			// readers still go on with the history they have if recording failed
+			if err := h.bus.EnrollmentRecorded.Publish(ctx, events.EnrollmentRecorded{CatalogUpdated: update}); err != nil {
+				slog.Error("Failed to publish recorded enrollment", "error", err)
+			}
+		}
+	}
+}
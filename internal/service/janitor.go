@@ -0,0 +1,179 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/TheTeemka/telegram_bot_cources/internal/clock"
+	"github.com/TheTeemka/telegram_bot_cources/internal/models"
+	"github.com/TheTeemka/telegram_bot_cources/internal/repositories"
+	"github.com/TheTeemka/telegram_bot_cources/internal/telegramfmt"
+	"github.com/TheTeemka/telegram_bot_cources/internal/ticker"
+)
+
+const (
+	janitorInterval = time.Hour
+	// expiryReminderLead is how long before the expiry users are reminded about it
+	expiryReminderLead = 24 * time.Hour
+	// notificationRetention is how long delivered and failed notifications stay in the outbox
+	notificationRetention = 30 * 24 * time.Hour
+)
+
+// Janitor expires subscriptions after the registration is over, reminding users beforehand,
+// and purges rows nobody needs anymore
+type Janitor struct {
+	subscriptionRepo repositories.CourseSubscriptionRepository
+	stateRepo        repositories.StateRepository
+	queueRepo        repositories.NotificationQueueRepository
+	store            *repositories.TrackingStore
+	notifier         *Notifier
+	outboxSignal     chan<- struct{}
+	schedule         *ticker.Schedule
+	clock            clock.Clock
+
+	// expireAfter is the grace period after the last registration window, zero disables the policy
+	expireAfter time.Duration
+}
+
+func NewJanitor(subscriptionRepo repositories.CourseSubscriptionRepository,
+	stateRepo repositories.StateRepository,
+	queueRepo repositories.NotificationQueueRepository,
+	store *repositories.TrackingStore,
+	notifier *Notifier,
+	outboxSignal chan<- struct{},
+	schedule *ticker.Schedule,
+	expireAfter time.Duration,
+	clk clock.Clock) *Janitor {
+	return &Janitor{
+		subscriptionRepo: subscriptionRepo,
+		stateRepo:        stateRepo,
+		queueRepo:        queueRepo,
+		store:            store,
+		notifier:         notifier,
+		outboxSignal:     outboxSignal,
+		schedule:         schedule,
+		expireAfter:      expireAfter,
+		clock:            clk,
+	}
+}
+
+func (j *Janitor) Start(ctx context.Context) {
+	t := j.clock.NewTicker(janitorInterval)
+	defer t.Stop()
+
+	j.Clean()
+	for {
+		select {
+		case <-ctx.Done():
+			slog.Info("Janitor stopped")
+			return
+		case <-t.C():
+			j.Clean()
+		}
+	}
+}
+
+func (j *Janitor) Clean() {
+	now := j.clock.Now()
+
+	// the policy is over once its expiry time passed, a stale schedule must not expire
+	// subscriptions made for the next registration
+	if end := j.schedule.RegistrationEnd(); j.expireAfter > 0 && !end.IsZero() && now.Before(end.Add(j.expireAfter)) {
+		// users always get their reminder, even if the grace period is almost over
+		expiresAt := end.Add(j.expireAfter)
+		if earliest := now.Add(expiryReminderLead); expiresAt.Before(earliest) {
+			expiresAt = earliest
+		}
+		n, err := j.subscriptionRepo.ApplyExpiryPolicy(now, expiresAt)
+		if err != nil {
+			slog.Error("Failed to apply expiry policy", "error", err)
+		} else if n != 0 {
+			slog.Info("Subscriptions will expire after the registration", "count", n, "expires_at", expiresAt)
+		}
+	}
+
+	if err := j.remindExpiring(now); err != nil {
+		slog.Error("Failed to remind about expiring subscriptions", "error", err)
+	}
+
+	n, err := j.subscriptionRepo.PurgeExpired(now)
+	if err != nil {
+		slog.Error("Failed to purge expired subscriptions", "error", err)
+	} else if n != 0 {
+		slog.Info("Purged expired subscriptions", "count", n)
+	}
+
+	n, err = j.stateRepo.PurgeExpired(now)
+	if err != nil {
+		slog.Error("Failed to purge expired chat states", "error", err)
+	} else if n != 0 {
+		slog.Info("Purged expired chat states", "count", n)
+	}
+
+	n, err = j.queueRepo.PurgeSettled(now.Add(-notificationRetention))
+	if err != nil {
+		slog.Error("Failed to purge settled notifications", "error", err)
+	} else if n != 0 {
+		slog.Info("Purged settled notifications", "count", n)
+	}
+}
+
+// remindExpiring sends a single reminder per user and course about subscriptions expiring within a day
+func (j *Janitor) remindExpiring(now time.Time) error {
+	subs, err := j.subscriptionRepo.GetExpiring(now.Add(expiryReminderLead))
+	if err != nil {
+		return err
+	}
+	if len(subs) == 0 {
+		return nil
+	}
+
+	type key struct {
+		telegramID int64
+		course     string
+	}
+	var keys []key
+	byCourse := make(map[key][]*models.CourseSubscription)
+	for _, sub := range subs {
+		k := key{sub.TelegramID, sub.Course}
+		if _, ok := byCourse[k]; !ok {
+			keys = append(keys, k)
+		}
+		byCourse[k] = append(byCourse[k], sub)
+	}
+
+	location := time.FixedZone("UTC+5", 5*60*60)
+	var notifications []*models.Notification
+	for _, k := range keys {
+		var sections []string
+		expiresAt := byCourse[k][0].ExpiresAt
+		for _, sub := range byCourse[k] {
+			sections = append(sections, sub.Section)
+			if sub.ExpiresAt.Before(expiresAt) {
+				expiresAt = sub.ExpiresAt
+			}
+		}
+		notifications = append(notifications, &models.Notification{
+			TelegramID: k.telegramID,
+			Kind:       models.NotificationExpiring,
+			Course:     k.course,
+			Text: fmt.Sprintf("⏳ Watching %s ends on %s",
+				telegramfmt.Escape(strings.Join(sections, ", ")), expiresAt.In(location).Format("15:04 02.01")),
+			Silent: true,
+		})
+	}
+	j.notifier.Schedule(notifications)
+
+	if err := j.store.CommitExpiryReminders(subs, notifications); err != nil {
+		return err
+	}
+
+	select {
+	case j.outboxSignal <- struct{}{}:
+	default: // sender is already woken up
+	}
+	return nil
+}
@@ -0,0 +1,183 @@
+package service
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/TheTeemka/telegram_bot_cources/internal/clock"
+	"github.com/TheTeemka/telegram_bot_cources/internal/database"
+	"github.com/TheTeemka/telegram_bot_cources/internal/models"
+	"github.com/TheTeemka/telegram_bot_cources/internal/repositories"
+	"github.com/TheTeemka/telegram_bot_cources/internal/ticker"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestDB creates the tables of every repository the services write to
+func newTestDB(t *testing.T, clk clock.Clock) *sql.DB {
+	db := database.NewSQLiteDB(filepath.Join(t.TempDir(), "db.db"))
+	t.Cleanup(func() { db.Close() })
+
+	repositories.NewSQLiteUserRepo(db, clk)
+	repositories.NewSQLiteSubscriptionRepo(db, clk)
+	repositories.NewSQLiteBundleRepo(db, clk)
+	repositories.NewSQLiteInterestRepo(db, clk)
+	repositories.NewSQLiteNotificationQueueRepo(db, clk)
+	return db
+}
+
+func newTestStore(db *sql.DB, clk clock.Clock) *repositories.TrackingStore {
+	return repositories.NewTrackingStore(db,
+		repositories.NewSQLiteSubscriptionRepo(db, clk),
+		repositories.NewSQLiteBundleRepo(db, clk),
+		repositories.NewSQLiteInterestRepo(db, clk),
+		repositories.NewSQLiteNotificationQueueRepo(db, clk))
+}
+
+func TestJanitorClean(t *testing.T) {
+	location := time.FixedZone("UTC+5", 5*60*60)
+	end := time.Date(2025, 12, 19, 15, 0, 0, 0, location)
+	clk := clock.NewFake(end.Add(-48 * time.Hour))
+
+	db := newTestDB(t, clk)
+	subscriptionRepo := repositories.NewSQLiteSubscriptionRepo(db, clk)
+	queueRepo := repositories.NewSQLiteNotificationQueueRepo(db, clk)
+	notifier := NewNotifier(repositories.NewSQLiteUserSettingsRepo(db, clk), clk)
+
+	schedule := ticker.NewSchedule()
+	assert.NoError(t, schedule.Set(ticker.SourceFile, []ticker.TickerIntervalConfig{{Till: end, Label: "Third Priority for All UG"}}))
+
+	j := NewJanitor(subscriptionRepo, repositories.NewStateRepository(db, clk), queueRepo, newTestStore(db, clk),
+		notifier, make(chan struct{}, 1), schedule, 72*time.Hour, clk)
+	expiresAt := func(userID int64) time.Time {
+		subs, err := subscriptionRepo.GetSubscriptions(userID)
+		assert.NoError(t, err)
+		if len(subs) == 0 {
+			return time.Time{}
+		}
+		return subs[0].ExpiresAt
+	}
+
+	assert.NoError(t, subscriptionRepo.Subscribe(1, "PHYS 161", []string{"1L"}))
+	clk.Advance(time.Minute)
+	j.Clean()
+	assert.True(t, end.Add(72*time.Hour).Equal(expiresAt(1)), "expires at %s", expiresAt(1))
+
+	// subscribed in the grace period, still gets a day to react to the reminder
+	clk.Advance(48*time.Hour + 60*time.Hour - time.Minute)
+	assert.NoError(t, subscriptionRepo.Subscribe(2, "PHYS 161", []string{"1L"}))
+	clk.Advance(time.Minute)
+	j.Clean()
+	assert.True(t, clk.Now().Add(expiryReminderLead).Equal(expiresAt(2)), "expires at %s", expiresAt(2))
+
+	due, err := queueRepo.Due(clk.Now().Add(24*time.Hour), 10)
+	assert.NoError(t, err)
+	assert.Len(t, due, 2, "both users are reminded about the expiry")
+
+	clk.Advance(25 * time.Hour)
+	j.Clean()
+	assert.True(t, expiresAt(1).IsZero(), "expired subscriptions are purged")
+	assert.True(t, expiresAt(2).IsZero(), "expired subscriptions are purged")
+
+	// the registration is long over, the schedule is stale
+	assert.NoError(t, subscriptionRepo.Subscribe(3, "PHYS 161", []string{"1L"}))
+	clk.Advance(time.Minute)
+	j.Clean()
+	subs, err := subscriptionRepo.GetSubscriptions(3)
+	assert.NoError(t, err)
+	if assert.Len(t, subs, 1) {
+		assert.True(t, subs[0].ExpiresAt.IsZero(), "subscriptions made after the grace period must not expire")
+	}
+}
+
+func TestJanitorCleanDisabled(t *testing.T) {
+	clk := clock.NewFake(time.Date(2025, 12, 18, 12, 0, 0, 0, time.UTC))
+	db := newTestDB(t, clk)
+	subscriptionRepo := repositories.NewSQLiteSubscriptionRepo(db, clk)
+	queueRepo := repositories.NewSQLiteNotificationQueueRepo(db, clk)
+
+	schedule := ticker.NewSchedule()
+	assert.NoError(t, schedule.Set(ticker.SourceFile, []ticker.TickerIntervalConfig{{Till: clk.Now().Add(time.Hour), Label: "Add/Drop"}}))
+
+	j := NewJanitor(subscriptionRepo, repositories.NewStateRepository(db, clk), queueRepo, newTestStore(db, clk),
+		NewNotifier(repositories.NewSQLiteUserSettingsRepo(db, clk), clk), make(chan struct{}, 1), schedule, 0, clk)
+
+	assert.NoError(t, subscriptionRepo.Subscribe(1, "PHYS 161", []string{"1L"}))
+	clk.Advance(time.Minute)
+	j.Clean()
+	subs, err := subscriptionRepo.GetSubscriptions(1)
+	assert.NoError(t, err)
+	if assert.Len(t, subs, 1) {
+		assert.True(t, subs[0].ExpiresAt.IsZero())
+	}
+}
+
+func TestJanitorRemindsOnce(t *testing.T) {
+	clk := clock.NewFake(time.Date(2025, 12, 18, 12, 0, 0, 0, time.UTC))
+	db := newTestDB(t, clk)
+	subscriptionRepo := repositories.NewSQLiteSubscriptionRepo(db, clk)
+	queueRepo := repositories.NewSQLiteNotificationQueueRepo(db, clk)
+
+	j := NewJanitor(subscriptionRepo, repositories.NewStateRepository(db, clk), queueRepo, newTestStore(db, clk),
+		NewNotifier(repositories.NewSQLiteUserSettingsRepo(db, clk), clk), make(chan struct{}, 1), ticker.NewSchedule(), 0, clk)
+
+	assert.NoError(t, subscriptionRepo.Subscribe(1, "PHYS 161", []string{"1L"}))
+	_, err := subscriptionRepo.SetExpiry(1, "PHYS 161", nil, clk.Now().Add(12*time.Hour))
+	assert.NoError(t, err)
+	due := func() int {
+		due, err := queueRepo.Due(clk.Now(), 10)
+		assert.NoError(t, err)
+		return len(due)
+	}
+
+	_, err = db.Exec(`CREATE TRIGGER fail_reminded BEFORE UPDATE OF expiry_reminded ON subscriptions
+		BEGIN SELECT RAISE(ABORT, 'disk is full'); END`)
+	assert.NoError(t, err)
+	j.Clean()
+	assert.Equal(t, 0, due(), "the reminder is not queued if the subscription can't be marked reminded")
+
+	_, err = db.Exec(`DROP TRIGGER fail_reminded`)
+	assert.NoError(t, err)
+	j.Clean()
+	j.Clean()
+	assert.Equal(t, 1, due())
+}
+
+func TestJanitorPurgesSettledNotifications(t *testing.T) {
+	clk := clock.NewFake(time.Date(2025, 12, 18, 12, 0, 0, 0, time.UTC))
+	db := newTestDB(t, clk)
+	queueRepo := repositories.NewSQLiteNotificationQueueRepo(db, clk)
+
+	j := NewJanitor(repositories.NewSQLiteSubscriptionRepo(db, clk), repositories.NewStateRepository(db, clk), queueRepo, newTestStore(db, clk),
+		NewNotifier(repositories.NewSQLiteUserSettingsRepo(db, clk), clk), make(chan struct{}, 1), ticker.NewSchedule(), 0, clk)
+
+	enqueue := func() int64 {
+		n := &models.Notification{TelegramID: 1, Kind: models.NotificationSeat, Course: "PHYS 161", Section: "1L",
+			Text: "opened", DeliverAt: clk.Now().Add(90 * 24 * time.Hour)}
+		assert.NoError(t, queueRepo.Enqueue([]*models.Notification{n}))
+		var id int64
+		assert.NoError(t, db.QueryRow(`SELECT MAX(id) FROM notification_queue`).Scan(&id))
+		return id
+	}
+	delivered, failed, pending := enqueue(), enqueue(), enqueue()
+	assert.NoError(t, queueRepo.MarkDelivered([]int64{delivered}))
+	assert.NoError(t, queueRepo.MarkFailed([]int64{failed}, "bad request"))
+
+	clk.Advance(notificationRetention - time.Hour)
+	recent := enqueue()
+	assert.NoError(t, queueRepo.MarkDelivered([]int64{recent}))
+	clk.Advance(2 * time.Hour)
+	j.Clean()
+
+	var left []int64
+	rows, err := db.Query(`SELECT id FROM notification_queue ORDER BY id`)
+	assert.NoError(t, err)
+	defer rows.Close()
+	for rows.Next() {
+		var id int64
+		assert.NoError(t, rows.Scan(&id))
+		left = append(left, id)
+	}
+	assert.Equal(t, []int64{pending, recent}, left, "pending and recently settled notifications stay")
+}
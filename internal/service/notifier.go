@@ -0,0 +1,51 @@
+package service
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/TheTeemka/telegram_bot_cources/internal/clock"
+	"github.com/TheTeemka/telegram_bot_cources/internal/models"
+	"github.com/TheTeemka/telegram_bot_cources/internal/repositories"
+)
+
+// Notifier is the scheduling layer between the tracker and the notifications outbox.
+// It holds non-urgent notifications during quiet hours or till the daily digest
+type Notifier struct {
+	settingsRepo repositories.UserSettingsRepository
+	clock        clock.Clock
+}
+
+func NewNotifier(settingsRepo repositories.UserSettingsRepository, clk clock.Clock) *Notifier {
+	return &Notifier{
+		settingsRepo: settingsRepo,
+		clock:        clk,
+	}
+}
+
+// Schedule sets the delivery time of every notification. Urgent ones are delivered
+// instantly, the rest wait for the end of quiet hours or for the daily digest
+func (n *Notifier) Schedule(notifications []*models.Notification) {
+	location := time.FixedZone("UTC+5", 5*60*60)
+	now := n.clock.Now().In(location)
+
+	settings := make(map[int64]*models.UserSettings)
+	for _, notification := range notifications {
+		if notification.Urgent {
+			notification.DeliverAt = now
+			continue
+		}
+
+		s, ok := settings[notification.TelegramID]
+		if !ok {
+			var err error
+			s, err = n.settingsRepo.Get(notification.TelegramID)
+			if err != nil {
+				slog.Error("Failed to get user settings", "error", err, "user_id", notification.TelegramID)
+			}
+			settings[notification.TelegramID] = s
+		}
+
+		notification.DeliverAt = s.NextDelivery(now)
+	}
+}
@@ -0,0 +1,147 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/TheTeemka/telegram_bot_cources/internal/clock"
+	"github.com/TheTeemka/telegram_bot_cources/internal/events"
+	"github.com/TheTeemka/telegram_bot_cources/internal/models"
+	"github.com/TheTeemka/telegram_bot_cources/internal/repositories"
+)
+
+const (
+	// predictionWindow is how much of the recent history the trend is fitted on
+	predictionWindow = 6 * time.Hour
+	// predictionRecordEvery keeps a prediction per section per hour for the accuracy report
+	predictionRecordEvery = time.Hour
+)
+
+// Predictor estimates when sections get full from their recent enrollment history.
+// Predictions are recomputed after the history recorder saved every refresh
+type Predictor struct {
+	courseRepo  *repositories.CourseRepository
+	historyRepo repositories.EnrollmentHistoryRepository
+	updates     <-chan events.EnrollmentRecorded
+	clock       clock.Clock
+
+	mu          sync.RWMutex
+	predictions map[models.SectionKey]time.Time
+
+	recordedAt map[models.SectionKey]time.Time
+}
+
+func NewPredictor(courseRepo *repositories.CourseRepository,
+	historyRepo repositories.EnrollmentHistoryRepository,
+	bus *events.Bus,
+	clk clock.Clock) *Predictor {
+	return &Predictor{
+		courseRepo:  courseRepo,
+		historyRepo: historyRepo,
+		updates:     bus.EnrollmentRecorded.Subscribe(),
+		clock:       clk,
+		predictions: make(map[models.SectionKey]time.Time),
+		recordedAt:  make(map[models.SectionKey]time.Time),
+	}
+}
+
+func (p *Predictor) Start(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			slog.Info("Predictor stopped")
+			return
+
+		case update := <-p.updates:
+			p.resolve(update.CatalogUpdated)
+			p.predict(update.ParsedAt)
+		}
+	}
+}
+
+// PredictFullAt returns when the section of the course is likely to be full
+func (p *Predictor) PredictFullAt(course, section string) (time.Time, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	at, ok := p.predictions[models.SectionKey{Course: course, Section: section}]
+	return at, ok
+}
+
+// CoursePredictions returns predictions for every section of the course which has one
+func (p *Predictor) CoursePredictions(course string) map[string]time.Time {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	res := make(map[string]time.Time)
+	for key, at := range p.predictions {
+		if key.Course == course {
+			res[key.Section] = at
+		}
+	}
+	return res
+}
+
+func (p *Predictor) Accuracy(since time.Time) (models.PredictionAccuracy, error) {
+	outcomes, err := p.historyRepo.GetPredictionOutcomes(since)
+	if err != nil {
+		return models.PredictionAccuracy{}, err
+	}
+	return models.SummarizePredictions(outcomes, p.clock.Now()), nil
+}
+
+// resolve marks predictions of sections, that got full with the update, as come true
+func (p *Predictor) resolve(update events.CatalogUpdated) {
+	if update.Diff == nil || update.Diff.Initial {
+		return
+	}
+
+	for _, c := range update.Diff.Changes {
+		if c.New == nil || c.New.Size < c.New.Cap || (c.Old != nil && c.Old.Size >= c.Old.Cap) {
+			continue
+		}
+		key := models.SectionKey{Course: c.Course, Section: c.Section}
+		if err := p.historyRepo.ResolvePredictions(key, update.ParsedAt); err != nil {
+			slog.Error("Failed to resolve fill predictions", "error", err, "course", c.Course, "section", c.Section)
+		}
+	}
+}
+
+func (p *Predictor) predict(now time.Time) {
+	history, err := p.historyRepo.GetSince(now.Add(-predictionWindow))
+	if err != nil {
+		slog.Error("Failed to get enrollment history", "error", err)
+		return
+	}
+
+	predictions := make(map[models.SectionKey]time.Time)
+	for key, samples := range history {
+		// history keeps only changes, the current state anchors the trend at the moment
+		sect, exists := p.courseRepo.GetSection(key.Course, key.Section)
+		if !exists {
+			continue
+		}
+		samples = append(samples, models.EnrollmentSample{Size: sect.Size, Cap: sect.Cap, At: now})
+
+		fullAt, ok := models.PredictFullAt(samples)
+		if !ok {
+			continue
+		}
+		predictions[key] = fullAt
+
+		if now.Sub(p.recordedAt[key]) >= predictionRecordEvery {
+			if err := p.historyRepo.RecordPrediction(key, now, fullAt); err != nil {
+				slog.Error("Failed to record fill prediction", "error", err, "course", key.Course, "section", key.Section)
+				continue
+			}
+			p.recordedAt[key] = now
+		}
+	}
+
+	p.mu.Lock()
+	p.predictions = predictions
+	p.mu.Unlock()
+	slog.Debug("Fill predictions updated", "sections", len(predictions))
+}
@@ -0,0 +1,152 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/TheTeemka/telegram_bot_cources/internal/clock"
+	"github.com/TheTeemka/telegram_bot_cources/internal/events"
+	"github.com/TheTeemka/telegram_bot_cources/internal/repositories"
+	"github.com/TheTeemka/telegram_bot_cources/internal/ticker"
+)
+
+// Refresher is the only owner of catalog refreshes. It parses the catalog on schedule or
+// on demand and publishes the outcome to the bus
+type Refresher struct {
+	courseRepo *repositories.CourseRepository
+	bus        *events.Bus
+	ticker     *ticker.DynamicTicker
+	requests   chan chan error
+	clock      clock.Clock
+	// adaptive tunes the interval outside registration windows, nil keeps it fixed
+	adaptive *ticker.AdaptiveInterval
+	// unobserved counts seat changes since the adaptive interval was observed last,
+	// including the ones found by manual refreshes in between
+	unobserved int
+	// baseline tells whether the previous scheduled refresh was outside registration windows,
+	// so the changes since then span a whole default interval
+	baseline bool
+}
+
+func NewRefresher(courseRepo *repositories.CourseRepository, bus *events.Bus, schedule *ticker.Schedule, timeInterval time.Duration, adaptive *ticker.AdaptiveInterval, clk clock.Clock) *Refresher {
+	r := &Refresher{
+		courseRepo: courseRepo,
+		bus:        bus,
+		ticker:     ticker.NewDynamicTicker(timeInterval, schedule, clk),
+		requests:   make(chan chan error),
+		clock:      clk,
+		adaptive:   adaptive,
+	}
+	if adaptive != nil {
+		r.ticker.SetDefaultInterval(adaptive.Current())
+	}
+	return r
+}
+
+func (r *Refresher) Start(ctx context.Context) {
+	// the catalog is parsed once on start already, subscribers still have to hear about it
+	err := r.bus.CatalogUpdated.Publish(ctx, events.CatalogUpdated{
+		Diff:     r.courseRepo.GetLastDiff(),
+		ParsedAt: r.clock.Now(),
+	})
+	if err != nil {
+		slog.Error("Failed to publish the initial catalog", "error", err)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			slog.Info("Refresher stopped")
+			r.ticker.Stop()
+			return
+
+		case <-r.ticker.C:
+			r.refresh(ctx, false)
+
+		case result := <-r.requests:
+			result <- r.refresh(ctx, true)
+		}
+	}
+}
+
+// Refresh asks for an immediate refresh and waits till its result is published
+func (r *Refresher) Refresh(ctx context.Context) error {
+	result := make(chan error, 1)
+	select {
+	case r.requests <- result:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	select {
+	case err := <-result:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// NextRefresh is when the next scheduled refresh happens
+func (r *Refresher) NextRefresh() time.Time {
+	return r.ticker.TimePoint()
+}
+
+// adapt observes the seat changes of whole default intervals only. Manual refreshes and ramp ticks
+// come sooner, so their changes would look like a busier catalog than it is
+func (r *Refresher) adapt(seatChanges int, manual bool) {
+	r.unobserved += seatChanges
+	if manual {
+		return
+	}
+	if r.ticker.InWindow() {
+		r.baseline, r.unobserved = false, 0
+		return
+	}
+	if !r.baseline {
+		r.baseline, r.unobserved = true, 0
+		return
+	}
+
+	d, reason := r.adaptive.Observe(r.unobserved)
+	r.unobserved = 0
+	r.ticker.SetDefaultInterval(d, reason)
+	slog.Info("Polling interval adapted", "interval", d.String(), "reason", reason)
+}
+
+// PollingInterval is how often the catalog is refreshed at the moment and why
+func (r *Refresher) PollingInterval() (time.Duration, string) {
+	return r.ticker.Interval()
+}
+
+func (r *Refresher) refresh(ctx context.Context, manual bool) error {
+	slog.Info("Refreshing catalog", "manual", manual)
+
+	if err := r.courseRepo.Parse(); err != nil {
+		slog.Error("Failed to parse courses", "error", err, "manual", manual)
+		pubErr := r.bus.CatalogRefreshFailed.Publish(ctx, events.CatalogRefreshFailed{
+			Err:    err,
+			At:     r.clock.Now(),
+			Manual: manual,
+		})
+		if pubErr != nil {
+			slog.Error("Failed to publish the refresh failure", "error", pubErr)
+		}
+		return err
+	}
+
+	diff := r.courseRepo.GetLastDiff()
+	if r.adaptive != nil {
+		r.adapt(diff.SeatChanges(), manual)
+	}
+
+	err := r.bus.CatalogUpdated.Publish(ctx, events.CatalogUpdated{
+		Diff:     diff,
+		ParsedAt: r.clock.Now(),
+		Manual:   manual,
+	})
+	if err != nil {
+		slog.Error("Failed to publish the catalog update", "error", err, "manual", manual)
+	}
+	return err
+}
@@ -0,0 +1,229 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/TheTeemka/telegram_bot_cources/internal/clock"
+	"github.com/TheTeemka/telegram_bot_cources/internal/models"
+	"github.com/TheTeemka/telegram_bot_cources/internal/repositories"
+	"github.com/TheTeemka/telegram_bot_cources/internal/telegramfmt"
+	"github.com/TheTeemka/telegram_bot_cources/internal/ticker"
+)
+
+const (
+	reminderPollInterval = time.Minute
+	// reminderHistory keeps sent reminders around, so a window moved a bit doesn't repeat them
+	reminderHistory = 7 * 24 * time.Hour
+)
+
+// Reminders plans reminders about registration windows for users who told their study year,
+// replanning them whenever the schedule changes, and sends them when they are due
+type Reminders struct {
+	schedule         *ticker.Schedule
+	settingsRepo     repositories.UserSettingsRepository
+	reminderRepo     repositories.ReminderRepository
+	subscriptionRepo repositories.CourseSubscriptionRepository
+	courseRepo       *repositories.CourseRepository
+	queueRepo        repositories.NotificationQueueRepository
+	outboxSignal     chan<- struct{}
+	clock            clock.Clock
+}
+
+func NewReminders(schedule *ticker.Schedule,
+	settingsRepo repositories.UserSettingsRepository,
+	reminderRepo repositories.ReminderRepository,
+	subscriptionRepo repositories.CourseSubscriptionRepository,
+	courseRepo *repositories.CourseRepository,
+	queueRepo repositories.NotificationQueueRepository,
+	outboxSignal chan<- struct{},
+	clk clock.Clock) *Reminders {
+	return &Reminders{
+		schedule:         schedule,
+		settingsRepo:     settingsRepo,
+		reminderRepo:     reminderRepo,
+		subscriptionRepo: subscriptionRepo,
+		courseRepo:       courseRepo,
+		queueRepo:        queueRepo,
+		outboxSignal:     outboxSignal,
+		clock:            clk,
+	}
+}
+
+func (r *Reminders) Start(ctx context.Context) {
+	t := r.clock.NewTicker(reminderPollInterval)
+	defer t.Stop()
+
+	if err := r.PlanAll(); err != nil {
+		slog.Error("Failed to plan reminders", "error", err)
+	}
+	for {
+		changed := r.schedule.Changed()
+		if err := r.Send(); err != nil {
+			slog.Error("Failed to send reminders", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			slog.Info("Reminders stopped")
+			return
+		case <-changed:
+			if err := r.PlanAll(); err != nil {
+				slog.Error("Failed to plan reminders", "error", err)
+			}
+		case <-t.C():
+		}
+	}
+}
+
+// PlanAll replans reminders of every user for the current schedule
+func (r *Reminders) PlanAll() error {
+	now := r.clock.Now()
+	years, err := r.settingsRepo.GetStudyYears()
+	if err != nil {
+		return err
+	}
+
+	var reminders []*models.Reminder
+	for userID, year := range years {
+		reminders = append(reminders, r.plan(userID, year, now)...)
+	}
+	if err := r.reminderRepo.ReplaceAllPending(reminders); err != nil {
+		return err
+	}
+
+	if n, err := r.reminderRepo.PurgeBefore(now.Add(-reminderHistory)); err != nil {
+		return err
+	} else if n != 0 {
+		slog.Info("Purged old reminders", "count", n)
+	}
+	slog.Info("Reminders planned", "users", len(years), "reminders", len(reminders))
+	return nil
+}
+
+// Plan replans reminders of a single user, after they changed their study year
+func (r *Reminders) Plan(userID int64) error {
+	settings, err := r.settingsRepo.Get(userID)
+	if err != nil {
+		return err
+	}
+
+	var reminders []*models.Reminder
+	if settings.StudyYear != 0 {
+		reminders = r.plan(userID, settings.StudyYear, r.clock.Now())
+	}
+	return r.reminderRepo.ReplacePending(userID, reminders)
+}
+
+func (r *Reminders) plan(userID int64, year int, now time.Time) []*models.Reminder {
+	var reminders []*models.Reminder
+	for _, w := range r.schedule.Windows() {
+		if !models.WindowForYear(w.Label, year) {
+			continue
+		}
+		opens := w.Till // registration of the cohort opens at Till, not closes
+		for _, lead := range models.ReminderLeads {
+			if remindAt := opens.Add(-lead); remindAt.After(now) {
+				reminders = append(reminders, &models.Reminder{
+					TelegramID:  userID,
+					WindowLabel: w.Label,
+					WindowAt:    opens,
+					RemindAt:    remindAt,
+				})
+			}
+		}
+	}
+	return reminders
+}
+
+// Send delivers due reminders. If several reminders about a window are due at once,
+// e.g. after a downtime, only the latest one is sent, none if the window has already opened
+func (r *Reminders) Send() error {
+	now := r.clock.Now()
+	due, err := r.reminderRepo.Due(now)
+	if err != nil {
+		return err
+	}
+	if len(due) == 0 {
+		return nil
+	}
+
+	type key struct {
+		telegramID int64
+		windowAt   int64
+	}
+	var keys []key
+	latest := make(map[key]*models.Reminder)
+	ids := make([]int64, 0, len(due))
+	for _, rem := range due {
+		ids = append(ids, rem.ID)
+		k := key{rem.TelegramID, rem.WindowAt.Unix()}
+		if prev, ok := latest[k]; !ok {
+			keys = append(keys, k)
+		} else if !rem.RemindAt.After(prev.RemindAt) {
+			continue
+		}
+		latest[k] = rem
+	}
+
+	var notifications []*models.Notification
+	for _, k := range keys {
+		rem := latest[k]
+		if !rem.WindowAt.After(now) {
+			continue
+		}
+		notifications = append(notifications, &models.Notification{
+			TelegramID: rem.TelegramID,
+			Kind:       models.NotificationReminder,
+			Text:       r.reminderText(rem, now),
+			DeliverAt:  now,
+		})
+	}
+
+	if err := r.queueRepo.Enqueue(notifications); err != nil {
+		return err
+	}
+	if err := r.reminderRepo.MarkSent(ids, now); err != nil {
+		return err
+	}
+	slog.Info("Reminders sent", "due", len(due), "sent", len(notifications))
+
+	select {
+	case r.outboxSignal <- struct{}{}:
+	default: // sender is already woken up
+	}
+	return nil
+}
+
+func (r *Reminders) reminderText(rem *models.Reminder, now time.Time) string {
+	location := time.FixedZone("UTC+5", 5*60*60)
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("⏰ <b>%s</b> starts in %s, at %s\n",
+		telegramfmt.Escape(rem.WindowLabel), telegramfmt.FormatETA(rem.WindowAt.Sub(now)), rem.WindowAt.In(location).Format("15:04 02.01")))
+
+	subs, err := r.subscriptionRepo.GetSubscriptions(rem.TelegramID)
+	if err != nil {
+		slog.Error("Failed to get subscriptions for a reminder", "error", err, "user_id", rem.TelegramID)
+		return sb.String()
+	}
+	if len(subs) == 0 {
+		sb.WriteString("\nYou aren't watching any sections, send a course code to check it.")
+		return sb.String()
+	}
+
+	sb.WriteString("\nYour sections right now:\n")
+	for _, sub := range subs {
+		section, exists := r.courseRepo.GetSection(sub.Course, sub.Section)
+		if !exists {
+			sb.WriteString(fmt.Sprintf("• <code>%s %s</code> is not in the catalog\n",
+				telegramfmt.Escape(sub.Course), telegramfmt.Escape(sub.Section)))
+			continue
+		}
+		sb.WriteString(telegramfmt.FormatCourseSection(sub.Course, sub.Section, section.Size, section.Cap, time.Time{}, r.clock.Now()))
+	}
+	return sb.String()
+}
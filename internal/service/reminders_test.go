@@ -0,0 +1,69 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/TheTeemka/telegram_bot_cources/internal/clock"
+	"github.com/TheTeemka/telegram_bot_cources/internal/models"
+	"github.com/TheTeemka/telegram_bot_cources/internal/repositories"
+	"github.com/TheTeemka/telegram_bot_cources/internal/ticker"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReminders(t *testing.T) {
+	location := time.FixedZone("UTC+5", 5*60*60)
+	opens := time.Date(2025, 12, 19, 15, 0, 0, 0, location)
+	clk := clock.NewFake(opens.Add(-48 * time.Hour))
+
+	db := newTestDB(t, clk)
+	settingsRepo := repositories.NewSQLiteUserSettingsRepo(db, clk)
+	queueRepo := repositories.NewSQLiteNotificationQueueRepo(db, clk)
+	schedule := ticker.NewSchedule()
+	assert.NoError(t, schedule.Set(ticker.SourceFile, []ticker.TickerIntervalConfig{
+		{Till: opens.Add(-24 * time.Hour), Label: "First Priority for 4,5,6 UG"},
+		{Till: opens, Label: "Third Priority for 3 UG"},
+	}))
+
+	r := NewReminders(schedule, settingsRepo, repositories.NewSQLiteReminderRepo(db),
+		repositories.NewSQLiteSubscriptionRepo(db, clk), &repositories.CourseRepository{Courses: map[string]*models.Course{}},
+		queueRepo, make(chan struct{}, 1), clk)
+	sent := func() map[int64][]string {
+		assert.NoError(t, r.Send())
+		due, err := queueRepo.Due(clk.Now(), 100)
+		assert.NoError(t, err)
+
+		res := make(map[int64][]string)
+		var ids []int64
+		for _, n := range due {
+			res[n.TelegramID] = append(res[n.TelegramID], n.Text)
+			ids = append(ids, n.ID)
+		}
+		assert.NoError(t, queueRepo.MarkDelivered(ids))
+		return res
+	}
+
+	assert.NoError(t, settingsRepo.SetStudyYear(1, 3))
+	assert.NoError(t, settingsRepo.SetStudyYear(2, 4))
+	assert.NoError(t, r.PlanAll())
+
+	clk.Advance(24 * time.Hour)
+	res := sent()
+	assert.Len(t, res[1], 1)
+	assert.Contains(t, res[1][0], "<b>Third Priority for 3 UG</b> starts in ~24h, at 15:00 19.12")
+	assert.Empty(t, res[2], "the window of the 4th year opens right now")
+
+	// after changing the year only the reminders of the new one are planned
+	assert.NoError(t, settingsRepo.SetStudyYear(2, 3))
+	assert.NoError(t, r.Plan(2))
+
+	// after a downtime only the latest of the due reminders about a window is sent
+	clk.Advance(24*time.Hour - 5*time.Minute)
+	res = sent()
+	assert.Len(t, res[1], 1)
+	assert.Contains(t, res[1][0], "starts in ~5m, at 15:00 19.12")
+	assert.Len(t, res[2], 1)
+
+	clk.Advance(time.Hour)
+	assert.Empty(t, sent())
+}
@@ -4,100 +4,412 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"strings"
 	"time"
 
+	"github.com/TheTeemka/telegram_bot_cources/internal/clock"
+	"github.com/TheTeemka/telegram_bot_cources/internal/events"
+	"github.com/TheTeemka/telegram_bot_cources/internal/models"
 	"github.com/TheTeemka/telegram_bot_cources/internal/repositories"
 	"github.com/TheTeemka/telegram_bot_cources/internal/telegramfmt"
-	"github.com/TheTeemka/telegram_bot_cources/internal/ticker"
-	tapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
 
 type Tracker struct {
 	courseRepo       *repositories.CourseRepository
 	subscriptionRepo repositories.CourseSubscriptionRepository
-	ticker           *ticker.DynamicTicker
+	bundleRepo       repositories.BundleSubscriptionRepository
+	interestRepo     repositories.CourseInterestRepository
+	store            *repositories.TrackingStore
+	notifier         *Notifier
+	fairness         *Fairness
+	outboxSignal     chan<- struct{}
+	updates          <-chan events.CatalogUpdated
+	clock            clock.Clock
+
+	// lastTrackedAt is when the latest successfully saved tick started, zero forces a full check
+	lastTrackedAt time.Time
 }
 
-func NewTracker(courseRepo *repositories.CourseRepository, subscriptionRepo repositories.CourseSubscriptionRepository, timeInterval time.Duration) *Tracker {
+func NewTracker(courseRepo *repositories.CourseRepository,
+	subscriptionRepo repositories.CourseSubscriptionRepository,
+	bundleRepo repositories.BundleSubscriptionRepository,
+	interestRepo repositories.CourseInterestRepository,
+	store *repositories.TrackingStore,
+	notifier *Notifier,
+	fairness *Fairness,
+	bus *events.Bus,
+	outboxSignal chan<- struct{},
+	clk clock.Clock) *Tracker {
 	return &Tracker{
 		courseRepo:       courseRepo,
 		subscriptionRepo: subscriptionRepo,
-		ticker:           ticker.NewDynamicTicker(timeInterval),
+		bundleRepo:       bundleRepo,
+		interestRepo:     interestRepo,
+		store:            store,
+		notifier:         notifier,
+		fairness:         fairness,
+		outboxSignal:     outboxSignal,
+		updates:          bus.CatalogUpdated.Subscribe(),
+		clock:            clk,
 	}
 }
 
-func (t *Tracker) Start(ctx context.Context, writeChan chan<- tapi.Chattable) {
-	t.Track(writeChan)
-
+func (t *Tracker) Start(ctx context.Context) {
 	for {
 		select {
 		case <-ctx.Done():
 			slog.Info("Tracker stopped")
-			t.ticker.Stop()
 			return
 
-		case <-t.ticker.C:
-			t.Track(writeChan)
+		case update := <-t.updates:
+			t.Track(update.Diff)
 		}
 	}
 }
 
-func (t *Tracker) Track(writeChan chan<- tapi.Chattable) {
-	slog.Info("Tracker ticked, checking subscriptions")
-	subs, err := t.subscriptionRepo.GetAll()
+// Track matches subscriptions against the catalog changed by a refresh
+func (t *Tracker) Track(diff *models.CatalogDiff) {
+	slog.Info("Catalog updated, checking subscriptions")
+	startedAt := t.clock.Now()
+
+	subs, err := t.affectedSubscriptions(diff, startedAt)
 	if err != nil {
 		slog.Error("Failed to get subscriptions", "error", err)
 		return
 	}
-	t.courseRepo.Parse()
+
+	changes := &models.TrackingChanges{}
+	t.trackSubscriptions(subs, startedAt, changes)
+	t.trackBundles(diff, changes)
+	t.trackCatalogChanges(diff, changes)
+	t.trackInterests(diff, changes)
+	t.notifier.Schedule(changes.Notifications)
+
+	err = t.store.Commit(changes)
+	if err != nil {
+		slog.Error("Failed to save tracking changes", "error", err,
+			"subscriptions", len(changes.Subscriptions), "notifications", len(changes.Notifications))
+		t.lastTrackedAt = time.Time{}
+		return
+	}
+	slog.Info("Tracker saved changes", "checked", len(subs),
+		"subscriptions", len(changes.Subscriptions), "notifications", len(changes.Notifications),
+		"took", t.clock.Now().Sub(startedAt))
+	t.lastTrackedAt = startedAt
+
+	select {
+	case t.outboxSignal <- struct{}{}:
+	default: // sender is already woken up
+	}
+}
+
+// affectedSubscriptions loads only subscriptions to sections changed by the refresh and the ones
+// skipped since the previous tick: new, unsnoozed and of users who came back. The first tick
+// after start checks everything, as the catalog could change while the bot was down
+func (t *Tracker) affectedSubscriptions(diff *models.CatalogDiff, now time.Time) ([]*models.CourseSubscription, error) {
+	if t.lastTrackedAt.IsZero() {
+		return t.subscriptionRepo.GetAll()
+	}
+
+	subs, err := t.subscriptionRepo.GetPendingSince(t.lastTrackedAt, now)
+	if err != nil {
+		return nil, err
+	}
+
+	type key struct {
+		telegramID      int64
+		course, section string
+	}
+	seen := make(map[key]bool, len(subs))
 	for _, sub := range subs {
-		mf := telegramfmt.NewMessageFormatter(sub.TelegramID)
+		seen[key{sub.TelegramID, sub.Course, sub.Section}] = true
+	}
+
+	for course, sections := range diff.ChangedSections() {
+		changed, err := t.subscriptionRepo.GetBySections(course, sections)
+		if err != nil {
+			return nil, err
+		}
+		for _, sub := range changed {
+			k := key{sub.TelegramID, sub.Course, sub.Section}
+			if !seen[k] {
+				seen[k] = true
+				subs = append(subs, sub)
+			}
+		}
+	}
+
+	return subs, nil
+}
+
+func (t *Tracker) trackSubscriptions(subs []*models.CourseSubscription, now time.Time, changes *models.TrackingChanges) {
+	// users competing for the same free seat are notified in the order of the fairness policy.
+	// Openings are enqueued before anything else, as the outbox sends batches in the order of their first row
+	type section struct{ course, section string }
+	var (
+		opened []section
+		rest   []*models.Notification
+	)
+	openings := make(map[section][]*models.Notification)
+
+	for _, sub := range subs {
+		// a snoozed subscription keeps the state the user last heard about, so they learn what
+		// changed once the snooze is over
+		if sub.Snoozed(now) {
+			continue
+		}
+
 		_, exists := t.courseRepo.GetCourse(sub.Course)
 		if !exists {
-			mf.AddString(fmt.Sprintf("%s %s is not existent anymore", sub.Course, sub.Section))
-			mf.UnsubscribeOrIgnoreCourse(sub.Course)
-
-			writeChan <- mf.Messages()[0]
+			changes.Notifications = append(changes.Notifications, &models.Notification{
+				TelegramID: sub.TelegramID,
+				Kind:       models.NotificationCourseGone,
+				Course:     sub.Course,
+				Section:    sub.Section,
+				Text:       fmt.Sprintf("❌ %s is not existent anymore", telegramfmt.Escape(sub.Section)),
+			})
 			continue
 		}
 
 		sect, exists := t.courseRepo.GetSection(sub.Course, sub.Section)
 		if !exists {
-			mf.AddString(fmt.Sprintf("%s %s is not existent anymore", sub.Course, sub.Section))
-			mf.UnsubscribeOrIgnoreSection(sub.Course, sub.Section)
-
-			writeChan <- mf.Messages()[0]
+			changes.Notifications = append(changes.Notifications, &models.Notification{
+				TelegramID: sub.TelegramID,
+				Kind:       models.NotificationSectionGone,
+				Course:     sub.Course,
+				Section:    sub.Section,
+				Text:       fmt.Sprintf("❌ %s is not existent anymore", telegramfmt.Escape(sub.Section)),
+			})
 			continue
 		}
 
+		var text string
+		opening := false
 		if sub.IsFull && sect.Size < sect.Cap {
-			writeChan <- immediateMessage(sub.TelegramID,
-				fmt.Sprintf("🔆 %s %s now has free places \\(%d/%d\\)",
-					sub.Course, sub.Section, sect.Size, sect.Cap))
-
+			text = fmt.Sprintf("🔆 %s now has free places (%d/%d)", telegramfmt.Escape(sub.Section), sect.Size, sect.Cap)
 			sub.IsFull = false
-			err := t.subscriptionRepo.Update(sub)
-			if err != nil {
-				slog.Error("Failed to update subscription", "error", err, "subscription", sub)
+			opening = true
+		} else if !sub.IsFull && sect.Size >= sect.Cap {
+			text = fmt.Sprintf("🚫 %s is full (%d/%d)", telegramfmt.Escape(sub.Section), sect.Size, sect.Cap)
+			sub.IsFull = true
+		} else {
+			continue
+		}
+
+		changes.Subscriptions = append(changes.Subscriptions, sub)
+		n := &models.Notification{
+			TelegramID: sub.TelegramID,
+			Kind:       models.NotificationSeat,
+			Course:     sub.Course,
+			Section:    sub.Section,
+			Text:       text,
+			Urgent:     sub.Urgent,
+		}
+		if !opening {
+			rest = append(rest, n)
+			continue
+		}
+
+		key := section{sub.Course, sub.Section}
+		if _, ok := openings[key]; !ok {
+			opened = append(opened, key)
+		}
+		openings[key] = append(openings[key], n)
+	}
+
+	for _, key := range opened {
+		t.fairness.Order(key.course, key.section, openings[key])
+		changes.Notifications = append(changes.Notifications, openings[key]...)
+	}
+	changes.Notifications = append(changes.Notifications, rest...)
+}
+
+// pendingCourses is the course filter of GetPending of bundles and interests. Besides the ones
+// added or reactivated since lastTrackedAt, only those of courses with sections changed by the
+// refresh have to be checked again, so these are listed under every name they are known by
+func pendingCourses(diff *models.CatalogDiff) []string {
+	var courses []string
+	for course := range diff.ChangedSections() {
+		courses = append(courses, course)
+	}
+	return courses
+}
+
+func (t *Tracker) trackBundles(diff *models.CatalogDiff, changes *models.TrackingChanges) {
+	var (
+		bundles []*models.BundleSubscription
+		err     error
+	)
+	if t.lastTrackedAt.IsZero() {
+		bundles, err = t.bundleRepo.GetAll()
+	} else {
+		bundles, err = t.bundleRepo.GetPending(pendingCourses(diff), t.lastTrackedAt)
+	}
+	if err != nil {
+		slog.Error("Failed to get bundle subscriptions", "error", err)
+		return
+	}
+
+	for _, bundle := range bundles {
+		sections := strings.Join(bundle.Sections, ", ")
+
+		var (
+			free    int
+			details []string
+			missing string
+		)
+		for _, sectionName := range bundle.Sections {
+			sect, exists := t.courseRepo.GetSection(bundle.Course, sectionName)
+			if !exists {
+				missing = sectionName
+				break
+			}
+			if sect.Size < sect.Cap {
+				free++
+			}
+			details = append(details, fmt.Sprintf("%s %d/%d", sectionName, sect.Size, sect.Cap))
+		}
+
+		// a missing section is reported once, the bundle is checked again if it comes back
+		if missing != "" {
+			if bundle.State == models.BundleBroken {
 				continue
 			}
+			bundle.State = models.BundleBroken
+			changes.Bundles = append(changes.Bundles, bundle)
+			changes.Notifications = append(changes.Notifications, &models.Notification{
+				TelegramID: bundle.TelegramID,
+				Kind:       models.NotificationBundle,
+				Course:     bundle.Course,
+				BundleID:   bundle.ID,
+				Text: fmt.Sprintf("❌ Bundle (%s): %s is not existent anymore",
+					telegramfmt.Escape(sections), telegramfmt.Escape(missing)),
+			})
+			continue
+		}
 
-		} else if !sub.IsFull && sect.Size >= sect.Cap {
-			writeChan <- immediateMessage(sub.TelegramID,
-				fmt.Sprintf("🚫 %s %s is full \\(%d/%d\\)",
-					sub.Course, sub.Section, sect.Size, sect.Cap))
+		state := models.BundleUnavailable
+		if free == len(bundle.Sections) {
+			state = models.BundleAvailable
+		} else if free > 0 {
+			state = models.BundlePartial
+		}
+		if state == bundle.State {
+			continue
+		}
 
-			sub.IsFull = true
-			err := t.subscriptionRepo.Update(sub)
+		n := &models.Notification{
+			TelegramID: bundle.TelegramID,
+			Kind:       models.NotificationBundle,
+			Course:     bundle.Course,
+			BundleID:   bundle.ID,
+			Silent:     true,
+		}
+		detailsStr := telegramfmt.Escape(strings.Join(details, ", "))
+		switch {
+		case state == models.BundleAvailable:
+			n.Text = fmt.Sprintf("🔆 Every section of bundle (%s) now has free places", detailsStr)
+			n.Silent = false
+		case bundle.State == models.BundleAvailable:
+			n.Text = fmt.Sprintf("🚫 Bundle (%s) is not fully available anymore", detailsStr)
+		case state == models.BundlePartial && bundle.NotifyPartial:
+			n.Text = fmt.Sprintf("◐ Bundle (%s) is partially available (%d/%d sections)", detailsStr, free, len(bundle.Sections))
+		default:
+			n = nil
+		}
+
+		bundle.State = state
+		changes.Bundles = append(changes.Bundles, bundle)
+		if n != nil {
+			changes.Notifications = append(changes.Notifications, n)
+		}
+	}
+}
+
+// trackCatalogChanges notifies course subscribers about capacity changes and
+// users who asked for it about brand-new sections
+func (t *Tracker) trackCatalogChanges(diff *models.CatalogDiff, changes *models.TrackingChanges) {
+	if diff == nil || diff.Initial {
+		return
+	}
+
+	for course, sectionChanges := range diff.ByCourse() {
+		var capChanges, newSections []string
+		for _, c := range sectionChanges {
+			switch {
+			case c.CapChanged():
+				capChanges = append(capChanges, fmt.Sprintf("%s: %d → %d (%d/%d)",
+					c.Section, c.Old.Cap, c.New.Cap, c.New.Size, c.New.Cap))
+			case c.IsNew():
+				newSections = append(newSections, fmt.Sprintf("%s (%d/%d)",
+					c.Section, c.New.Size, c.New.Cap))
+			}
+		}
+
+		if len(capChanges) != 0 {
+			subscribers, err := t.subscriptionRepo.GetCourseSubscribers(course)
 			if err != nil {
-				slog.Error("Failed to update subscription", "error", err, "subscription", sub)
-				continue
+				slog.Error("Failed to get course subscribers", "error", err, "course", course)
+			}
+			text := fmt.Sprintf("📐 Capacity changed: %s", telegramfmt.Escape(strings.Join(capChanges, ", ")))
+			for _, id := range subscribers {
+				changes.Notifications = append(changes.Notifications, &models.Notification{
+					TelegramID: id,
+					Kind:       models.NotificationCapacity,
+					Course:     course,
+					Text:       text,
+				})
+			}
+		}
+
+		if len(newSections) != 0 {
+			watchers, err := t.subscriptionRepo.GetNewSectionsWatchers(course)
+			if err != nil {
+				slog.Error("Failed to get new sections watchers", "error", err, "course", course)
+			}
+			text := fmt.Sprintf("🆕 New sections: %s", telegramfmt.Escape(strings.Join(newSections, ", ")))
+			for _, id := range watchers {
+				changes.Notifications = append(changes.Notifications, &models.Notification{
+					TelegramID: id,
+					Kind:       models.NotificationNewSections,
+					Course:     course,
+					Text:       text,
+				})
 			}
 		}
 	}
 }
-func immediateMessage(chatId int64, text string) tapi.Chattable {
-	msg := tapi.NewMessage(chatId, text)
-	msg.ParseMode = tapi.ModeMarkdownV2
-	return msg
+
+// trackInterests notifies users waiting for a course once it appears in the catalog
+func (t *Tracker) trackInterests(diff *models.CatalogDiff, changes *models.TrackingChanges) {
+	var (
+		interests []*models.CourseInterest
+		err       error
+	)
+	if t.lastTrackedAt.IsZero() {
+		interests, err = t.interestRepo.GetAll()
+	} else {
+		interests, err = t.interestRepo.GetPending(pendingCourses(diff), t.lastTrackedAt)
+	}
+	if err != nil {
+		slog.Error("Failed to get course interests", "error", err)
+		return
+	}
+
+	for _, interest := range interests {
+		course, exists := t.courseRepo.GetCourse(interest.Course)
+		if !exists {
+			continue
+		}
+
+		changes.Interests = append(changes.Interests, interest)
+		changes.Notifications = append(changes.Notifications, &models.Notification{
+			TelegramID: interest.TelegramID,
+			Kind:       models.NotificationCourseOffered,
+			Course:     course.AbbrName,
+			Text: fmt.Sprintf("🆕 Course <b>%s</b> you were waiting for is now offered!\n\n%s",
+				telegramfmt.Escape(interest.Course),
+				telegramfmt.FormatCourseInDetails(course, t.courseRepo.SemesterName, t.courseRepo.LastTimeParsed, nil, t.clock.Now())),
+		})
+	}
 }
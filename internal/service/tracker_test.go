@@ -0,0 +1,138 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/TheTeemka/telegram_bot_cources/internal/clock"
+	"github.com/TheTeemka/telegram_bot_cources/internal/events"
+	"github.com/TheTeemka/telegram_bot_cources/internal/models"
+	"github.com/TheTeemka/telegram_bot_cources/internal/repositories"
+	"github.com/stretchr/testify/assert"
+)
+
+type trackerTest struct {
+	t                *testing.T
+	clock            *clock.Fake
+	courseRepo       *repositories.CourseRepository
+	subscriptionRepo repositories.CourseSubscriptionRepository
+	bundleRepo       repositories.BundleSubscriptionRepository
+	userRepo         repositories.UserRepository
+	queueRepo        repositories.NotificationQueueRepository
+	tracker          *Tracker
+}
+
+func newTrackerTest(t *testing.T) *trackerTest {
+	clk := clock.NewFake(time.Date(2025, 12, 17, 9, 0, 0, 0, time.UTC))
+	db := newTestDB(t, clk)
+
+	tt := &trackerTest{
+		t:                t,
+		clock:            clk,
+		courseRepo:       &repositories.CourseRepository{Courses: map[string]*models.Course{}},
+		subscriptionRepo: repositories.NewSQLiteSubscriptionRepo(db, clk),
+		bundleRepo:       repositories.NewSQLiteBundleRepo(db, clk),
+		userRepo:         repositories.NewSQLiteUserRepo(db, clk),
+		queueRepo:        repositories.NewSQLiteNotificationQueueRepo(db, clk),
+	}
+	interestRepo := repositories.NewSQLiteInterestRepo(db, clk)
+	store := repositories.NewTrackingStore(db, tt.subscriptionRepo, tt.bundleRepo, interestRepo, tt.queueRepo)
+	tt.tracker = NewTracker(tt.courseRepo, tt.subscriptionRepo, tt.bundleRepo, interestRepo,
+		store, NewNotifier(repositories.NewSQLiteUserSettingsRepo(db, clk), clk),
+		NewFairness(FairnessRandom, tt.queueRepo), events.NewBus(), make(chan struct{}, 1), clk)
+	return tt
+}
+
+// refresh replaces the catalog with a single course and tracks the changes a minute later
+func (tt *trackerTest) refresh(sections ...*models.Section) {
+	old := tt.courseRepo.Courses
+	tt.courseRepo.Courses = map[string]*models.Course{
+		"PHYS 161": {AbbrName: "PHYS 161", FullName: "Physics I", Sections: sections},
+	}
+	tt.clock.Advance(time.Minute)
+	tt.tracker.Track(models.DiffCatalogs(old, tt.courseRepo.Courses))
+}
+
+// notified returns texts of notifications queued since the previous call
+func (tt *trackerTest) notified() []string {
+	due, err := tt.queueRepo.Due(tt.clock.Now().Add(48*time.Hour), 100)
+	assert.NoError(tt.t, err)
+
+	var texts []string
+	var ids []int64
+	for _, n := range due {
+		texts = append(texts, n.Text)
+		ids = append(ids, n.ID)
+	}
+	if len(ids) != 0 {
+		assert.NoError(tt.t, tt.queueRepo.MarkDelivered(ids))
+	}
+	return texts
+}
+
+func TestTrackerSnoozeEndsBetweenTicks(t *testing.T) {
+	tt := newTrackerTest(t)
+	assert.NoError(t, tt.subscriptionRepo.Subscribe(1, "PHYS 161", []string{"1L"}))
+
+	tt.refresh(&models.Section{SectionName: "1L", Size: 20, Cap: 20})
+	assert.Equal(t, []string{"🚫 1L is full (20/20)"}, tt.notified())
+
+	_, err := tt.subscriptionRepo.Snooze(1, "PHYS 161", "1L", tt.clock.Now().Add(time.Hour))
+	assert.NoError(t, err)
+	tt.refresh(&models.Section{SectionName: "1L", Size: 19, Cap: 20})
+	assert.Empty(t, tt.notified(), "snoozed subscriptions are muted")
+
+	tt.clock.Advance(time.Hour)
+	tt.refresh(&models.Section{SectionName: "1L", Size: 19, Cap: 20})
+	assert.Equal(t, []string{"🔆 1L now has free places (19/20)"}, tt.notified(),
+		"the seat freed during the snooze is reported once it is over")
+
+	tt.refresh(&models.Section{SectionName: "1L", Size: 19, Cap: 20})
+	assert.Empty(t, tt.notified())
+}
+
+func TestTrackerRechecksReactivatedUsers(t *testing.T) {
+	tt := newTrackerTest(t)
+	_, err := tt.userRepo.Touch(1, "student")
+	assert.NoError(t, err)
+	assert.NoError(t, tt.subscriptionRepo.Subscribe(1, "PHYS 161", []string{"1L"}))
+
+	tt.refresh(&models.Section{SectionName: "1L", Size: 20, Cap: 20})
+	assert.Equal(t, []string{"🚫 1L is full (20/20)"}, tt.notified())
+
+	assert.NoError(t, tt.userRepo.Deactivate(1, "blocked"))
+	tt.refresh(&models.Section{SectionName: "1L", Size: 19, Cap: 20})
+	assert.Empty(t, tt.notified(), "users who blocked the bot are skipped")
+
+	reactivated, err := tt.userRepo.Touch(1, "student")
+	assert.NoError(t, err)
+	assert.True(t, reactivated)
+	tt.refresh(&models.Section{SectionName: "1L", Size: 19, Cap: 20})
+	assert.Equal(t, []string{"🔆 1L now has free places (19/20)"}, tt.notified())
+}
+
+func TestTrackerBundles(t *testing.T) {
+	tt := newTrackerTest(t)
+	assert.NoError(t, tt.bundleRepo.Subscribe(1, "PHYS 161", []string{"1L", "1PLB"}, false))
+
+	tt.refresh(&models.Section{SectionName: "1L", Size: 20, Cap: 20}, &models.Section{SectionName: "1PLB", Size: 10, Cap: 20})
+	assert.Empty(t, tt.notified(), "partial availability is quiet unless asked for")
+
+	tt.refresh(&models.Section{SectionName: "1L", Size: 19, Cap: 20}, &models.Section{SectionName: "1PLB", Size: 10, Cap: 20})
+	assert.Equal(t, []string{"🔆 Every section of bundle (1L 19/20, 1PLB 10/20) now has free places"}, tt.notified())
+
+	tt.refresh(&models.Section{SectionName: "1L", Size: 18, Cap: 20})
+	assert.Equal(t, []string{"❌ Bundle (1L, 1PLB): 1PLB is not existent anymore"}, tt.notified())
+
+	tt.refresh(&models.Section{SectionName: "1L", Size: 17, Cap: 20})
+	assert.Empty(t, tt.notified(), "a missing section is reported once")
+
+	tt.refresh(&models.Section{SectionName: "1L", Size: 17, Cap: 20}, &models.Section{SectionName: "1PLB", Size: 5, Cap: 20})
+	assert.Equal(t, []string{"🔆 Every section of bundle (1L 17/20, 1PLB 5/20) now has free places"}, tt.notified())
+
+	bundles, err := tt.bundleRepo.GetBundles(1)
+	assert.NoError(t, err)
+	if assert.Len(t, bundles, 1) {
+		assert.Equal(t, models.BundleAvailable, bundles[0].State)
+	}
+}
@@ -9,52 +9,84 @@ import (
 	"sync"
 	"time"
 
+	"github.com/TheTeemka/telegram_bot_cources/internal/clock"
 	"github.com/TheTeemka/telegram_bot_cources/internal/config"
 	"github.com/TheTeemka/telegram_bot_cources/internal/handlers"
 	"github.com/TheTeemka/telegram_bot_cources/internal/repositories"
+	"github.com/TheTeemka/telegram_bot_cources/internal/ticker"
 	tapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
 
 type TelegramBot struct {
 	BotAPI *tapi.BotAPI
 	*handlers.MessageHandler
+	Scheduler *SendScheduler
 	workerNum int
 }
 
 func NewTelegramBot(stage string, cfg config.BotConfig,
 	coursesRepo *repositories.CourseRepository,
 	subscriptionRepo repositories.CourseSubscriptionRepository,
+	bundleRepo repositories.BundleSubscriptionRepository,
+	interestRepo repositories.CourseInterestRepository,
 	stateRepo repositories.StateRepository,
-	statisticsRepo *repositories.StatisticsRepository) *TelegramBot {
+	settingsRepo repositories.UserSettingsRepository,
+	queueRepo repositories.NotificationQueueRepository,
+	userRepo repositories.UserRepository,
+	statisticsRepo *repositories.StatisticsRepository,
+	windowRepo repositories.RegistrationWindowRepository,
+	schedule *ticker.Schedule,
+	refresher handlers.CatalogRefresher,
+	predictor handlers.FillPredictor,
+	reminders handlers.ReminderPlanner,
+	clk clock.Clock) *TelegramBot {
 	bot, err := tapi.NewBotAPI(cfg.Token)
 	if err != nil {
 		slog.Error("Failed to create Telegram Bot", "error", err)
 		os.Exit(1)
 	}
 
-	handler := handlers.NewMessageHandler(bot, cfg, coursesRepo, subscriptionRepo, stateRepo, statisticsRepo)
+	handler := handlers.NewMessageHandler(bot, cfg, coursesRepo, subscriptionRepo, bundleRepo, interestRepo, stateRepo, settingsRepo, queueRepo, userRepo, statisticsRepo, windowRepo, schedule, refresher, predictor, reminders, clk)
 
-	res, err := bot.Request(handler.CommandsList())
-	if err != nil {
-		slog.Error("Failed to set bot commands", "error", err)
-		os.Exit(1)
-	} else if !res.Ok {
-		slog.Error("Failed to set bot commands", "desc", res.Description)
-		os.Exit(1)
+	for i, commands := range handler.CommandsList() {
+		res, err := bot.Request(commands)
+		if i > 0 {
+			// menus of admins fail for those who never opened the bot, they still can use the commands
+			if err != nil {
+				slog.Warn("Failed to set admin commands", "error", err)
+			} else if !res.Ok {
+				slog.Warn("Failed to set admin commands", "desc", res.Description)
+			}
+			continue
+		}
+		if err != nil {
+			slog.Error("Failed to set bot commands", "error", err)
+			os.Exit(1)
+		} else if !res.Ok {
+			slog.Error("Failed to set bot commands", "desc", res.Description)
+			os.Exit(1)
+		}
 	}
 
 	return &TelegramBot{
 		BotAPI:         bot,
 		MessageHandler: handler,
+		Scheduler:      NewSendScheduler(bot, clk),
 		workerNum:      cfg.WorkerNumber,
 	}
 }
 
-func (bot *TelegramBot) Start(ctx context.Context, writeChan <-chan tapi.Chattable) {
+func (bot *TelegramBot) Start(ctx context.Context, outboxSignal <-chan struct{}) {
 	updateConfig := tapi.NewUpdate(0)
 	updateChan := bot.BotAPI.GetUpdatesChan(updateConfig)
 
 	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		bot.Scheduler.Run(ctx)
+	}()
+
 	wg.Add(bot.workerNum)
 	for range bot.workerNum {
 		go func() {
@@ -66,7 +98,7 @@ func (bot *TelegramBot) Start(ctx context.Context, writeChan <-chan tapi.Chattab
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		bot.Sender(ctx, writeChan)
+		bot.Sender(ctx, outboxSignal)
 	}()
 
 	wg.Wait()
@@ -96,7 +128,7 @@ func (bot *TelegramBot) Worker(ctx context.Context, updateChan tapi.UpdatesChann
 			} else if update.ChannelPost != nil {
 				createdAt = time.Unix(int64(update.ChannelPost.Date), 0).UTC()
 			}
-			receivedAt := time.Now().UTC()
+			receivedAt := bot.Clock.Now().UTC()
 
 			if !createdAt.IsZero() && receivedAt.Sub(createdAt) > timespan {
 				slog.Info("Dropped old update", "createdAt", createdAt, "receivedAt", receivedAt, "timespan", timespan)
@@ -106,7 +138,7 @@ func (bot *TelegramBot) Worker(ctx context.Context, updateChan tapi.UpdatesChann
 			msgs := bot.HandleUpdate(update)
 
 			for _, msg := range msgs {
-				_, err := bot.BotAPI.Send(msg)
+				_, err := bot.Scheduler.Send(ctx, msg, PriorityInteractive)
 				var unmarshalTypeErr *json.UnmarshalTypeError
 				if err != nil && !errors.As(err, &unmarshalTypeErr) {
 					var (
@@ -124,29 +156,34 @@ func (bot *TelegramBot) Worker(ctx context.Context, updateChan tapi.UpdatesChann
 						continue
 					}
 
-					slog.Error("Failed to send message in worker", "error", err,
+					kind := ClassifySendError(err)
+					slog.Error("Failed to send message in worker", "error", err, "kind", kind,
 						"userID", userID, "username", userName, "msg", msg)
+					if kind.UserIsGone() {
+						if err := bot.deactivateUser(userID, kind); err != nil {
+							slog.Error("Failed to deactivate user", "error", err, "userID", userID)
+						}
+					}
 				}
 			}
 		}
 	}
 }
 
-func (bot *TelegramBot) Sender(ctx context.Context, writeChan <-chan tapi.Chattable) {
+// Sender drains the notifications outbox, either periodically or when signaled about new notifications
+func (bot *TelegramBot) Sender(ctx context.Context, outboxSignal <-chan struct{}) {
+	ticker := bot.Clock.NewTicker(outboxPollInterval)
+	defer ticker.Stop()
+
+	bot.drainOutbox(ctx)
 	for {
 		select {
 		case <-ctx.Done():
 			return
-		case msg, ok := <-writeChan:
-			if !ok {
-				slog.Info("Update channel closed")
-				return
-			}
-			_, err := bot.BotAPI.Send(msg)
-			if err != nil {
-				slog.Error("Failed to send message in sender", "error", err)
-				continue
-			}
+		case <-ticker.C():
+			bot.drainOutbox(ctx)
+		case <-outboxSignal:
+			bot.drainOutbox(ctx)
 		}
 	}
 }
@@ -0,0 +1,64 @@
+package telegram
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	tapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+type SendErrorKind int
+
+const (
+	SendErrorTemporary    SendErrorKind = iota // network problems and Telegram failures, worth retrying
+	SendErrorBlocked                           // user blocked the bot or deleted the account
+	SendErrorChatNotFound                      // chat does not exist or the bot can't write there
+	SendErrorRateLimited                       // too many requests even after waiting
+	SendErrorBadRequest                        // the message itself is wrong, retrying won't help
+)
+
+func (k SendErrorKind) String() string {
+	switch k {
+	case SendErrorBlocked:
+		return "blocked"
+	case SendErrorChatNotFound:
+		return "chat not found"
+	case SendErrorRateLimited:
+		return "rate limited"
+	case SendErrorBadRequest:
+		return "bad request"
+	default:
+		return "temporary"
+	}
+}
+
+// UserIsGone reports whether nothing can be delivered to the chat anymore
+func (k SendErrorKind) UserIsGone() bool {
+	return k == SendErrorBlocked || k == SendErrorChatNotFound
+}
+
+// ClassifySendError tells apart errors of BotAPI.Send
+func ClassifySendError(err error) SendErrorKind {
+	var tgErr *tapi.Error
+	if !errors.As(err, &tgErr) {
+		return SendErrorTemporary
+	}
+
+	desc := strings.ToLower(tgErr.Message)
+	switch {
+	case tgErr.Code == http.StatusTooManyRequests || tgErr.RetryAfter != 0:
+		return SendErrorRateLimited
+	case tgErr.Code == http.StatusForbidden:
+		if strings.Contains(desc, "chat not found") {
+			return SendErrorChatNotFound
+		}
+		return SendErrorBlocked // blocked by the user, user is deactivated, can't initiate conversation
+	case strings.Contains(desc, "chat not found") || strings.Contains(desc, "user not found"):
+		return SendErrorChatNotFound
+	case tgErr.Code == http.StatusBadRequest:
+		return SendErrorBadRequest
+	default:
+		return SendErrorTemporary
+	}
+}
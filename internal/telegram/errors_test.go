@@ -0,0 +1,46 @@
+package telegram
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"syscall"
+	"testing"
+
+	tapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClassifySendError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want SendErrorKind
+	}{
+		{"blocked", &tapi.Error{Code: 403, Message: "Forbidden: bot was blocked by the user"}, SendErrorBlocked},
+		{"deactivated", &tapi.Error{Code: 403, Message: "Forbidden: user is deactivated"}, SendErrorBlocked},
+		{"never started", &tapi.Error{Code: 403, Message: "Forbidden: bot can't initiate conversation with a user"}, SendErrorBlocked},
+		{"forbidden chat not found", &tapi.Error{Code: 403, Message: "Forbidden: chat not found"}, SendErrorChatNotFound},
+		{"chat not found", &tapi.Error{Code: 400, Message: "Bad Request: chat not found"}, SendErrorChatNotFound},
+		{"user not found", &tapi.Error{Code: 400, Message: "Bad Request: user not found"}, SendErrorChatNotFound},
+		{"too long", &tapi.Error{Code: 400, Message: "Bad Request: message is too long"}, SendErrorBadRequest},
+		{"bad markup", &tapi.Error{Code: 400, Message: "Bad Request: BUTTON_DATA_INVALID"}, SendErrorBadRequest},
+		{"rate limited", &tapi.Error{
+			Code:               429,
+			Message:            "Too Many Requests: retry after 7",
+			ResponseParameters: tapi.ResponseParameters{RetryAfter: 7},
+		}, SendErrorRateLimited},
+		{"retry after without code", &tapi.Error{ResponseParameters: tapi.ResponseParameters{RetryAfter: 3}}, SendErrorRateLimited},
+		{"server error", &tapi.Error{Code: 502, Message: "Bad Gateway"}, SendErrorTemporary},
+		{"network", &url.Error{Op: "Post", URL: "https://api.telegram.org/bot/sendMessage", Err: syscall.ECONNREFUSED}, SendErrorTemporary},
+		{"wrapped", fmt.Errorf("send digest: %w", &tapi.Error{Code: 403, Message: "Forbidden: bot was blocked by the user"}), SendErrorBlocked},
+		{"other", errors.New("unexpected EOF"), SendErrorTemporary},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kind := ClassifySendError(tt.err)
+			assert.Equal(t, tt.want, kind, "got %s", kind)
+			assert.Equal(t, tt.want == SendErrorBlocked || tt.want == SendErrorChatNotFound, kind.UserIsGone())
+		})
+	}
+}
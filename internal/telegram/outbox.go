@@ -0,0 +1,138 @@
+package telegram
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/TheTeemka/telegram_bot_cources/internal/models"
+	"github.com/TheTeemka/telegram_bot_cources/internal/telegramfmt"
+	tapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	outboxPollInterval  = 30 * time.Second
+	outboxBatchSize     = 100
+	maxDeliveryAttempts = 5
+)
+
+// drainOutbox delivers every due notification, grouping non-standalone ones into a digest per user
+func (bot *TelegramBot) drainOutbox(ctx context.Context) {
+	for {
+		due, err := bot.QueueRepo.Due(bot.Clock.Now(), outboxBatchSize)
+		if err != nil {
+			slog.Error("Failed to get due notifications", "error", err)
+			return
+		}
+
+		for _, batch := range groupNotifications(due) {
+			if ctx.Err() != nil {
+				return
+			}
+			if err := bot.deliver(ctx, batch); err != nil {
+				slog.Error("Failed to save delivery status", "error", err)
+				return
+			}
+		}
+
+		if len(due) < outboxBatchSize {
+			return
+		}
+	}
+}
+
+func (bot *TelegramBot) deliver(ctx context.Context, batch []*models.Notification) error {
+	ids := make([]int64, 0, len(batch))
+	attempts := 0
+	for _, n := range batch {
+		ids = append(ids, n.ID)
+		attempts = max(attempts, n.Attempts+1)
+	}
+
+	msg := bot.renderNotifications(batch)
+	_, err := bot.Scheduler.Send(ctx, msg, PriorityBulk)
+	if err == nil {
+		return bot.QueueRepo.MarkDelivered(ids)
+	}
+	if ctx.Err() != nil {
+		return nil // shutting down, notifications stay pending
+	}
+
+	kind := ClassifySendError(err)
+	if kind.UserIsGone() {
+		return bot.deactivateUser(batch[0].TelegramID, kind)
+	}
+
+	if attempts >= maxDeliveryAttempts || kind == SendErrorBadRequest {
+		slog.Error("Failed to deliver notification, giving up", "error", err,
+			"user_id", batch[0].TelegramID, "attempts", attempts, "kind", kind)
+		return bot.QueueRepo.MarkFailed(ids, err.Error())
+	}
+
+	retryAt := bot.Clock.Now().Add(time.Minute << (attempts - 1))
+	slog.Warn("Failed to deliver notification, will retry", "error", err,
+		"user_id", batch[0].TelegramID, "attempts", attempts, "retry_at", retryAt)
+	return bot.QueueRepo.Retry(ids, err.Error(), retryAt)
+}
+
+// deactivateUser pauses subscriptions of a user, who blocked the bot, till they write to it again
+func (bot *TelegramBot) deactivateUser(userID int64, kind SendErrorKind) error {
+	slog.Info("User can't be reached, pausing their subscriptions", "user_id", userID, "reason", kind)
+
+	if err := bot.UserRepo.Deactivate(userID, kind.String()); err != nil {
+		return err
+	}
+	return bot.QueueRepo.DropPending(userID, kind.String())
+}
+
+func (bot *TelegramBot) renderNotifications(batch []*models.Notification) tapi.Chattable {
+	mf := telegramfmt.NewMessageFormatter(batch[0].TelegramID)
+
+	if len(batch) == 1 && (batch[0].Kind == models.NotificationAdminAlert || batch[0].Kind == models.NotificationReminder) {
+		mf.AddString(batch[0].Text)
+		return mf.Messages()[0]
+	}
+
+	if len(batch) == 1 && batch[0].Kind == models.NotificationCourseOffered {
+		mf.AddString(batch[0].Text)
+		if course, exists := bot.CoursesRepo.GetCourse(batch[0].Course); exists {
+			mf.QuickSubscribeKeyboard(course)
+		}
+		return mf.Messages()[0]
+	}
+
+	mf.AddDigest(batch)
+	return mf.Messages()[0]
+}
+
+// groupNotifications keeps standalone notifications apart and the rest together per user,
+// in order of the oldest notification of each group. A digest too big for a single message
+// is cut into several ones, so every batch is delivered, or fails, as one message
+func groupNotifications(notifications []*models.Notification) [][]*models.Notification {
+	var batches [][]*models.Notification
+	digests := make(map[int64]int)
+	for _, n := range notifications {
+		if n.Standalone() {
+			batches = append(batches, []*models.Notification{n})
+			continue
+		}
+
+		ind, ok := digests[n.TelegramID]
+		if !ok {
+			ind = len(batches)
+			digests[n.TelegramID] = ind
+			batches = append(batches, nil)
+		}
+		batches[ind] = append(batches[ind], n)
+	}
+
+	var split [][]*models.Notification
+	for _, batch := range batches {
+		if batch[0].Standalone() {
+			split = append(split, batch)
+			continue
+		}
+		split = append(split, telegramfmt.SplitDigest(batch)...)
+	}
+	return split
+}
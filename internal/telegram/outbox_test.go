@@ -0,0 +1,282 @@
+package telegram
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+	"unicode/utf16"
+
+	"github.com/TheTeemka/telegram_bot_cources/internal/clock"
+	"github.com/TheTeemka/telegram_bot_cources/internal/database"
+	"github.com/TheTeemka/telegram_bot_cources/internal/handlers"
+	"github.com/TheTeemka/telegram_bot_cources/internal/models"
+	"github.com/TheTeemka/telegram_bot_cources/internal/repositories"
+	tapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGroupNotificationsSplitsOversizedDigest(t *testing.T) {
+	var notifications []*models.Notification
+	id := int64(0)
+	add := func(n *models.Notification) {
+		id++
+		n.ID = id
+		notifications = append(notifications, n)
+	}
+
+	for i := range 90 {
+		add(&models.Notification{
+			TelegramID: 1,
+			Kind:       models.NotificationSeat,
+			Course:     fmt.Sprintf("CSCI %d", 100+i%10),
+			Section:    fmt.Sprintf("%dL", i),
+			Text:       fmt.Sprintf("🔆 %dL now has free places (10/50)", i),
+		})
+		if i == 10 {
+			add(&models.Notification{TelegramID: 1, Kind: models.NotificationSeat, Course: "PHYS 161", Section: "1L", Urgent: true, Text: "urgent"})
+			add(&models.Notification{TelegramID: 2, Kind: models.NotificationSeat, Course: "PHYS 161", Section: "1L", Text: "🔆 1L now has free places (10/50)"})
+		}
+	}
+	for i := range 20 {
+		add(&models.Notification{
+			TelegramID: 3,
+			Kind:       models.NotificationCapacity,
+			Course:     "MATH 161",
+			Section:    fmt.Sprintf("%dR", i),
+			Text:       strings.Repeat("📈", 150),
+		})
+	}
+
+	batches := groupNotifications(notifications)
+
+	byUser := make(map[int64][]int64)
+	bot := &TelegramBot{}
+	for _, batch := range batches {
+		for _, n := range batch[1:] {
+			assert.Equal(t, batch[0].TelegramID, n.TelegramID, "a batch goes to a single user")
+		}
+		for _, n := range batch {
+			byUser[n.TelegramID] = append(byUser[n.TelegramID], n.ID)
+		}
+
+		msg := bot.renderNotifications(batch).(tapi.MessageConfig)
+		assert.LessOrEqual(t, len(utf16.Encode([]rune(msg.Text))), 4096)
+		if markup, ok := msg.ReplyMarkup.(tapi.InlineKeyboardMarkup); ok {
+			buttons := 0
+			for _, row := range markup.InlineKeyboard {
+				buttons += len(row)
+			}
+			assert.LessOrEqual(t, buttons, 100)
+		}
+	}
+
+	var ids []int64
+	for _, n := range notifications {
+		if n.TelegramID == 1 && !n.Urgent {
+			ids = append(ids, n.ID)
+		}
+	}
+	assert.Equal(t, append(ids, 12), byUser[1], "digests keep the order of notifications, the urgent one goes apart")
+	assert.Equal(t, []int64{13}, byUser[2])
+	assert.Len(t, byUser[3], 20)
+
+	count := make(map[int64]int)
+	for _, batch := range batches {
+		count[batch[0].TelegramID]++
+	}
+	assert.Greater(t, count[1], 2, "90 seat notifications don't fit into 100 buttons")
+	assert.Equal(t, 1, count[2])
+	assert.Greater(t, count[3], 1, "20 long notifications don't fit into 4096 characters")
+}
+
+type outboxTest struct {
+	bot   *TelegramBot
+	api   *fakeAPI
+	clk   *clock.Fake
+	db    *sql.DB
+	queue repositories.NotificationQueueRepository
+}
+
+func newOutboxTest(t *testing.T) *outboxTest {
+	clk := clock.NewFake(time.Date(2025, 12, 19, 15, 0, 0, 0, time.UTC))
+	db := database.NewSQLiteDB(filepath.Join(t.TempDir(), "db.db"))
+	t.Cleanup(func() { db.Close() })
+
+	api := &fakeAPI{}
+	ot := &outboxTest{
+		api:   api,
+		clk:   clk,
+		db:    db,
+		queue: repositories.NewSQLiteNotificationQueueRepo(db, clk),
+	}
+	ot.bot = &TelegramBot{
+		MessageHandler: &handlers.MessageHandler{
+			QueueRepo: ot.queue,
+			UserRepo:  repositories.NewSQLiteUserRepo(db, clk),
+			Clock:     clk,
+		},
+		Scheduler: NewSendScheduler(api, clk),
+	}
+
+	// stands for Run, moving the clock only while a message waits for the limits
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go func() {
+		for ctx.Err() == nil {
+			wait := ot.bot.Scheduler.dispatch(clk.Now())
+			if queued(ot.bot.Scheduler) != 0 {
+				clk.Advance(wait)
+			} else {
+				time.Sleep(time.Millisecond)
+			}
+		}
+	}()
+	return ot
+}
+
+func (ot *outboxTest) enqueue(t *testing.T, notifications ...*models.Notification) {
+	for _, n := range notifications {
+		n.DeliverAt = ot.clk.Now()
+	}
+	assert.NoError(t, ot.queue.Enqueue(notifications))
+}
+
+type queueRow struct {
+	Status    string
+	Attempts  int
+	DeliverAt time.Time
+}
+
+func (ot *outboxTest) rows(t *testing.T) []queueRow {
+	rows, err := ot.db.Query(`SELECT status, attempts, deliver_at FROM notification_queue ORDER BY id`)
+	assert.NoError(t, err)
+	defer rows.Close()
+
+	var res []queueRow
+	for rows.Next() {
+		var r queueRow
+		assert.NoError(t, rows.Scan(&r.Status, &r.Attempts, &r.DeliverAt))
+		r.DeliverAt = r.DeliverAt.UTC()
+		res = append(res, r)
+	}
+	return res
+}
+
+func seat(userID int64, section string) *models.Notification {
+	return &models.Notification{
+		TelegramID: userID,
+		Kind:       models.NotificationSeat,
+		Course:     "PHYS 161",
+		Section:    section,
+		Text:       fmt.Sprintf("🔆 %s now has free places (10/50)", section),
+	}
+}
+
+func TestDrainOutbox(t *testing.T) {
+	t.Run("a digest per user", func(t *testing.T) {
+		ot := newOutboxTest(t)
+		urgent := seat(1, "3L")
+		urgent.Urgent = true
+		ot.enqueue(t, seat(1, "1L"), seat(2, "1L"), urgent, seat(1, "2L"))
+
+		ot.bot.drainOutbox(context.Background())
+
+		sent := ot.api.Sent()
+		assert.Len(t, sent, 3)
+		assert.Equal(t, int64(1), sent[0].(tapi.MessageConfig).ChatID)
+		assert.Contains(t, sent[0].(tapi.MessageConfig).Text, "2 updates")
+		assert.Equal(t, int64(2), sent[1].(tapi.MessageConfig).ChatID)
+		assert.Equal(t, int64(1), sent[2].(tapi.MessageConfig).ChatID)
+		for _, row := range ot.rows(t) {
+			assert.Equal(t, repositories.NotificationDelivered, row.Status)
+			assert.Equal(t, 1, row.Attempts)
+		}
+	})
+
+	t.Run("an oversized digest goes in several messages", func(t *testing.T) {
+		ot := newOutboxTest(t)
+		var notifications []*models.Notification
+		for i := range 60 {
+			notifications = append(notifications, seat(1, fmt.Sprintf("%dL", i)))
+		}
+		ot.enqueue(t, notifications...)
+
+		ot.bot.drainOutbox(context.Background())
+
+		assert.Len(t, ot.api.Sent(), 2)
+		for _, row := range ot.rows(t) {
+			assert.Equal(t, repositories.NotificationDelivered, row.Status)
+		}
+	})
+
+	t.Run("retries with a growing delay, then gives up", func(t *testing.T) {
+		ot := newOutboxTest(t)
+		network := &url.Error{Op: "Post", URL: "https://api.telegram.org", Err: syscall.ECONNRESET}
+		for range maxDeliveryAttempts {
+			ot.api.errs = append(ot.api.errs, network)
+		}
+		ot.enqueue(t, seat(1, "1L"), seat(1, "2L"))
+
+		delay := time.Minute
+		for attempt := 1; attempt < maxDeliveryAttempts; attempt++ {
+			ot.bot.drainOutbox(context.Background())
+			for _, row := range ot.rows(t) {
+				assert.Equal(t, repositories.NotificationPending, row.Status, "attempt %d", attempt)
+				assert.Equal(t, attempt, row.Attempts)
+				assert.Equal(t, ot.clk.Now().Add(delay), row.DeliverAt)
+			}
+
+			ot.bot.drainOutbox(context.Background())
+			assert.Len(t, ot.api.Sent(), attempt, "nothing is sent before the retry time")
+
+			ot.clk.Advance(delay)
+			delay *= 2
+		}
+
+		ot.bot.drainOutbox(context.Background())
+		assert.Len(t, ot.api.Sent(), maxDeliveryAttempts)
+		for _, row := range ot.rows(t) {
+			assert.Equal(t, repositories.NotificationFailed, row.Status)
+			assert.Equal(t, maxDeliveryAttempts, row.Attempts)
+		}
+	})
+
+	t.Run("a bad request is not retried", func(t *testing.T) {
+		ot := newOutboxTest(t)
+		ot.api.errs = []error{&tapi.Error{Code: 400, Message: "Bad Request: BUTTON_DATA_INVALID"}}
+		ot.enqueue(t, seat(1, "1L"), seat(2, "1L"))
+
+		ot.bot.drainOutbox(context.Background())
+
+		rows := ot.rows(t)
+		assert.Equal(t, repositories.NotificationFailed, rows[0].Status)
+		assert.Equal(t, repositories.NotificationDelivered, rows[1].Status, "others are delivered")
+	})
+
+	t.Run("a blocked user is deactivated", func(t *testing.T) {
+		ot := newOutboxTest(t)
+		_, err := ot.bot.UserRepo.Touch(1, "user")
+		assert.NoError(t, err)
+		ot.api.errs = []error{&tapi.Error{Code: 403, Message: "Forbidden: bot was blocked by the user"}}
+		ot.enqueue(t, seat(1, "1L"))
+		later := seat(1, "2L")
+		ot.enqueue(t, later)
+		_, err = ot.db.Exec(`UPDATE notification_queue SET deliver_at = ? WHERE id = 2`, ot.clk.Now().Add(time.Hour).UTC())
+		assert.NoError(t, err)
+
+		ot.bot.drainOutbox(context.Background())
+
+		for _, row := range ot.rows(t) {
+			assert.Equal(t, repositories.NotificationFailed, row.Status, "pending notifications are dropped")
+		}
+		var active bool
+		assert.NoError(t, ot.db.QueryRow(`SELECT is_active FROM users WHERE telegram_id = 1`).Scan(&active))
+		assert.False(t, active)
+	})
+}
@@ -0,0 +1,189 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/TheTeemka/telegram_bot_cources/internal/clock"
+	tapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+type Priority int
+
+const (
+	PriorityInteractive Priority = iota // replies to users, sent first
+	PriorityBulk                        // tracker notifications
+)
+
+const (
+	globalSendInterval = time.Second / 30 // Telegram allows about 30 messages per second in total
+	chatSendInterval   = time.Second      // and about one message per second to the same chat
+	maxRateLimitRetry  = 3
+	idleWait           = time.Hour
+)
+
+// MessageSender is the part of tapi.BotAPI the scheduler sends with
+type MessageSender interface {
+	Send(c tapi.Chattable) (tapi.Message, error)
+}
+
+type sendRequest struct {
+	chatID int64
+	ready  chan struct{}
+}
+
+// SendScheduler is the single gate for every outgoing request. It keeps Telegram limits,
+// pauses sending when Telegram asks to retry later, and lets interactive replies overtake notifications
+type SendScheduler struct {
+	api   MessageSender
+	clock clock.Clock
+
+	mu          sync.Mutex
+	queues      [2][]*sendRequest
+	lastSent    time.Time
+	chatSent    map[int64]time.Time
+	pausedUntil time.Time
+	wake        chan struct{}
+}
+
+func NewSendScheduler(api MessageSender, clk clock.Clock) *SendScheduler {
+	return &SendScheduler{
+		api:      api,
+		clock:    clk,
+		chatSent: make(map[int64]time.Time),
+		wake:     make(chan struct{}, 1),
+	}
+}
+
+// Send waits for its turn and sends the message, retrying it when Telegram responds with 429
+func (s *SendScheduler) Send(ctx context.Context, msg tapi.Chattable, priority Priority) (tapi.Message, error) {
+	for attempt := 0; ; attempt++ {
+		if err := s.wait(ctx, chatIDOf(msg), priority); err != nil {
+			return tapi.Message{}, err
+		}
+
+		res, err := s.api.Send(msg)
+		var tgErr *tapi.Error
+		if !errors.As(err, &tgErr) || tgErr.RetryAfter == 0 || attempt == maxRateLimitRetry {
+			return res, err
+		}
+
+		retryAfter := time.Duration(tgErr.RetryAfter) * time.Second
+		slog.Warn("Hit Telegram rate limit, pausing sending", "retry_after", retryAfter, "attempt", attempt+1)
+		s.pause(retryAfter)
+	}
+}
+
+func (s *SendScheduler) wait(ctx context.Context, chatID int64, priority Priority) error {
+	req := &sendRequest{chatID: chatID, ready: make(chan struct{})}
+
+	s.mu.Lock()
+	s.queues[priority] = append(s.queues[priority], req)
+	s.mu.Unlock()
+	s.notify()
+
+	select {
+	case <-req.ready:
+		return nil
+	case <-ctx.Done():
+		s.mu.Lock()
+		s.queues[priority] = slices.DeleteFunc(s.queues[priority], func(r *sendRequest) bool { return r == req })
+		s.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+func (s *SendScheduler) pause(d time.Duration) {
+	s.mu.Lock()
+	if until := s.clock.Now().Add(d); until.After(s.pausedUntil) {
+		s.pausedUntil = until
+	}
+	s.mu.Unlock()
+}
+
+func (s *SendScheduler) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run lets queued requests go one by one as the limits allow
+func (s *SendScheduler) Run(ctx context.Context) {
+	for {
+		timer := s.clock.NewTimer(s.dispatch(s.clock.Now()))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-s.wake:
+		case <-timer.C():
+		}
+		timer.Stop()
+	}
+}
+
+// dispatch releases the next request if limits allow and returns how long to wait before the next try
+func (s *SendScheduler) dispatch(now time.Time) time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if now.Before(s.pausedUntil) {
+		return s.pausedUntil.Sub(now)
+	}
+	if next := s.lastSent.Add(globalSendInterval); now.Before(next) {
+		return next.Sub(now)
+	}
+
+	wait := idleWait
+	for priority := range s.queues {
+		for i, req := range s.queues[priority] {
+			readyAt := s.chatSent[req.chatID].Add(chatSendInterval)
+			if req.chatID != 0 && now.Before(readyAt) {
+				wait = min(wait, readyAt.Sub(now))
+				continue
+			}
+
+			s.queues[priority] = slices.Delete(s.queues[priority], i, i+1)
+			s.lastSent = now
+			s.chatSent[req.chatID] = now
+			close(req.ready)
+			s.forgetIdleChats(now)
+			return globalSendInterval
+		}
+	}
+	return wait
+}
+
+func (s *SendScheduler) forgetIdleChats(now time.Time) {
+	const maxTrackedChats = 1000
+	if len(s.chatSent) < maxTrackedChats {
+		return
+	}
+	for chatID, sent := range s.chatSent {
+		if now.Sub(sent) > chatSendInterval {
+			delete(s.chatSent, chatID)
+		}
+	}
+}
+
+func chatIDOf(msg tapi.Chattable) int64 {
+	switch m := msg.(type) {
+	case tapi.MessageConfig:
+		return m.ChatID
+	case tapi.EditMessageTextConfig:
+		return m.ChatID
+	case tapi.EditMessageReplyMarkupConfig:
+		return m.ChatID
+	case tapi.DeleteMessageConfig:
+		return m.ChatID
+	case tapi.DocumentConfig:
+		return m.ChatID
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,156 @@
+package telegram
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/TheTeemka/telegram_bot_cources/internal/clock"
+	tapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeAPI records sent messages and fails them with the queued errors one by one
+type fakeAPI struct {
+	mu   sync.Mutex
+	sent []tapi.Chattable
+	errs []error
+}
+
+func (a *fakeAPI) Send(c tapi.Chattable) (tapi.Message, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.sent = append(a.sent, c)
+	if len(a.errs) == 0 {
+		return tapi.Message{}, nil
+	}
+	err := a.errs[0]
+	a.errs = a.errs[1:]
+	return tapi.Message{}, err
+}
+
+func (a *fakeAPI) Sent() []tapi.Chattable {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.sent
+}
+
+// enqueue adds a request the way wait does, without blocking on it
+func enqueue(s *SendScheduler, chatID int64, priority Priority) *sendRequest {
+	req := &sendRequest{chatID: chatID, ready: make(chan struct{})}
+	s.queues[priority] = append(s.queues[priority], req)
+	return req
+}
+
+func released(req *sendRequest) bool {
+	select {
+	case <-req.ready:
+		return true
+	default:
+		return false
+	}
+}
+
+func queued(s *SendScheduler) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.queues[PriorityInteractive]) + len(s.queues[PriorityBulk])
+}
+
+func TestSendSchedulerDispatch(t *testing.T) {
+	now := time.Date(2025, 8, 1, 12, 0, 0, 0, time.UTC)
+
+	t.Run("interactive replies overtake notifications", func(t *testing.T) {
+		s := NewSendScheduler(&fakeAPI{}, clock.NewFake(now))
+		bulk := enqueue(s, 1, PriorityBulk)
+		reply := enqueue(s, 2, PriorityInteractive)
+
+		assert.Equal(t, globalSendInterval, s.dispatch(now))
+		assert.True(t, released(reply))
+		assert.False(t, released(bulk))
+
+		half := globalSendInterval / 2
+		assert.Equal(t, globalSendInterval-half, s.dispatch(now.Add(half)), "waits for the global limit")
+		assert.False(t, released(bulk))
+
+		s.dispatch(now.Add(globalSendInterval))
+		assert.True(t, released(bulk))
+		assert.Equal(t, idleWait, s.dispatch(now.Add(2*globalSendInterval)))
+	})
+
+	t.Run("a busy chat doesn't hold others", func(t *testing.T) {
+		s := NewSendScheduler(&fakeAPI{}, clock.NewFake(now))
+		first := enqueue(s, 1, PriorityInteractive)
+		second := enqueue(s, 1, PriorityInteractive)
+		other := enqueue(s, 2, PriorityBulk)
+
+		s.dispatch(now)
+		assert.True(t, released(first))
+
+		s.dispatch(now.Add(globalSendInterval))
+		assert.False(t, released(second))
+		assert.True(t, released(other), "a notification to another chat goes before the second reply")
+
+		at := now.Add(2 * globalSendInterval)
+		assert.Equal(t, chatSendInterval-2*globalSendInterval, s.dispatch(at), "waits for the chat limit")
+		assert.False(t, released(second))
+
+		s.dispatch(now.Add(chatSendInterval))
+		assert.True(t, released(second))
+	})
+}
+
+func TestSendSchedulerPausesOnRateLimit(t *testing.T) {
+	clk := clock.NewFake(time.Date(2025, 8, 1, 12, 0, 0, 0, time.UTC))
+	api := &fakeAPI{errs: []error{&tapi.Error{
+		Code:               429,
+		Message:            "Too Many Requests: retry after 5",
+		ResponseParameters: tapi.ResponseParameters{RetryAfter: 5},
+	}}}
+	s := NewSendScheduler(api, clk)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := s.Send(context.Background(), tapi.NewMessage(1, "hi"), PriorityBulk)
+		done <- err
+	}()
+
+	assert.Eventually(t, func() bool { return queued(s) == 1 }, time.Second, time.Millisecond)
+	s.dispatch(clk.Now())
+	assert.Eventually(t, func() bool { return len(api.Sent()) == 1 && queued(s) == 1 }, time.Second, time.Millisecond,
+		"the message is queued again after 429")
+
+	clk.Advance(time.Second)
+	assert.Equal(t, 4*time.Second, s.dispatch(clk.Now()), "sending is paused for retry_after")
+	assert.Len(t, api.Sent(), 1)
+
+	clk.Advance(4 * time.Second)
+	s.dispatch(clk.Now())
+	assert.NoError(t, <-done)
+	assert.Len(t, api.Sent(), 2)
+}
+
+func TestSendSchedulerRun(t *testing.T) {
+	clk := clock.NewFake(time.Date(2025, 8, 1, 12, 0, 0, 0, time.UTC))
+	api := &fakeAPI{}
+	s := NewSendScheduler(api, clk)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		s.Run(ctx)
+		close(stopped)
+	}()
+
+	_, err := s.Send(ctx, tapi.NewMessage(1, "hi"), PriorityInteractive)
+	assert.NoError(t, err)
+	assert.Len(t, api.Sent(), 1)
+
+	cancel()
+	<-stopped
+	_, err = s.Send(ctx, tapi.NewMessage(1, "bye"), PriorityInteractive)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, 0, queued(s), "a canceled request leaves the queue")
+}
@@ -9,7 +9,9 @@ import (
 	tapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
 
-func FormatCourseInDetails(course *models.Course, semesterName string, lastTimeParse time.Time) string {
+// FormatCourseInDetails renders every section of the course. predictions map section names to the
+// moment they are likely to be full and may be nil, now is the moment they are counted from
+func FormatCourseInDetails(course *models.Course, semesterName string, lastTimeParse time.Time, predictions map[string]time.Time, now time.Time) string {
 	var sb strings.Builder
 	semesterName = Escape(semesterName)
 
@@ -25,7 +27,7 @@ func FormatCourseInDetails(course *models.Course, semesterName string, lastTimeP
 			s = trimNumbersFromPrefix(section.SectionName)
 		}
 
-		sb.WriteString(formatSection(section.SectionName, section.Size, section.Cap))
+		sb.WriteString(formatSection(section.SectionName, section.Size, section.Cap, predictions[section.SectionName], now))
 	}
 	timeStr := Escape(lastTimeParse.Format("Last Update on: 15:04:05 02.01.2006"))
 	sb.WriteString(fmt.Sprintf("\n<i>%s</i>\n @nu_cources_bot", timeStr))
@@ -33,18 +35,20 @@ func FormatCourseInDetails(course *models.Course, semesterName string, lastTimeP
 	return sb.String()
 }
 
-func formatSection(sectionName string, sectionSize, sectionCap int) string {
+func formatSection(sectionName string, sectionSize, sectionCap int, fullAt, now time.Time) string {
 	sectionName = Escape(sectionName)
 	if sectionSize >= sectionCap {
 		return (fmt.Sprintf("• <s>%-8s %-9s [FULL]</s>\n", sectionName,
 			fmt.Sprintf("(%d/%d)", sectionSize, sectionCap)))
 	} else {
-		return (fmt.Sprintf("• <code>%-4s <s>%-9s</s></code>\n", sectionName,
-			fmt.Sprintf("(%d/%d)", sectionSize, sectionCap)))
+		return (fmt.Sprintf("• <code>%-4s <s>%-9s</s></code>%s\n", sectionName,
+			fmt.Sprintf("(%d/%d)", sectionSize, sectionCap), formatFullAt(fullAt, now)))
 	}
 }
 
-func FormatCourseSection(courseName, sectionName string, sectionSize, sectionCap int) string {
+// FormatCourseSection renders a single section, fullAt is the moment it is likely to be full or zero,
+// counted from now
+func FormatCourseSection(courseName, sectionName string, sectionSize, sectionCap int, fullAt, now time.Time) string {
 	courseName = Escape(courseName)
 	sectionName = Escape(sectionName)
 	if sectionSize >= sectionCap {
@@ -52,9 +56,44 @@ func FormatCourseSection(courseName, sectionName string, sectionSize, sectionCap
 			courseName, sectionName,
 			fmt.Sprintf("(%d/%d)", sectionSize, sectionCap)))
 	} else {
-		return (fmt.Sprintf("• <code>%-8s %-3s %-9s</code>\n",
+		return (fmt.Sprintf("• <code>%-8s %-3s %-9s</code>%s\n",
 			courseName, sectionName,
-			fmt.Sprintf("(%d/%d)", sectionSize, sectionCap)))
+			fmt.Sprintf("(%d/%d)", sectionSize, sectionCap), formatFullAt(fullAt, now)))
+	}
+}
+
+func formatFullAt(fullAt, now time.Time) string {
+	if fullAt.IsZero() {
+		return ""
+	}
+	return fmt.Sprintf(" <i>likely full in %s</i>", FormatETA(fullAt.Sub(now)))
+}
+
+// FormatETA rounds a duration to a short human-readable form like "~2h"
+func FormatETA(d time.Duration) string {
+	switch {
+	case d < 5*time.Minute:
+		return "a few minutes"
+	case d < time.Hour:
+		return fmt.Sprintf("~%dm", int(d.Round(5*time.Minute).Minutes()))
+	case d < 48*time.Hour:
+		return fmt.Sprintf("~%dh", int(d.Round(time.Hour).Hours()))
+	default:
+		return fmt.Sprintf("~%dd", int((d+12*time.Hour).Hours())/24)
+	}
+}
+
+func FormatBundle(bundle *models.BundleSubscription) string {
+	name := Escape(fmt.Sprintf("%s (%s)", bundle.Course, strings.Join(bundle.Sections, ", ")))
+	switch bundle.State {
+	case models.BundleAvailable:
+		return fmt.Sprintf("• <code>%s</code> [FREE]\n", name)
+	case models.BundlePartial:
+		return fmt.Sprintf("• <code>%s</code> [PARTIAL]\n", name)
+	case models.BundleBroken:
+		return fmt.Sprintf("• <s>%s</s> [GONE]\n", name)
+	default:
+		return fmt.Sprintf("• <s>%s</s> [FULL]\n", name)
 	}
 }
 
@@ -0,0 +1,17 @@
+package telegramfmt
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatCourseSectionFullAt(t *testing.T) {
+	now := time.Date(2025, 12, 17, 9, 0, 0, 0, time.UTC)
+
+	assert.Contains(t, FormatCourseSection("PHYS 161", "1L", 10, 50, now.Add(2*time.Hour), now), "likely full in ~2h",
+		"the prediction is counted from the given moment, not the wall clock")
+	assert.NotContains(t, FormatCourseSection("PHYS 161", "1L", 10, 50, time.Time{}, now), "likely full")
+	assert.Contains(t, FormatCourseSection("PHYS 161", "1L", 50, 50, now.Add(time.Hour), now), "[FULL]")
+}
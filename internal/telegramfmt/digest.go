@@ -0,0 +1,165 @@
+package telegramfmt
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/TheTeemka/telegram_bot_cources/internal/models"
+	tapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// FormatDigest renders notifications of a single user grouped by course
+func FormatDigest(notifications []*models.Notification) string {
+	var courses []string
+	byCourse := make(map[string][]*models.Notification)
+	for _, n := range notifications {
+		if _, ok := byCourse[n.Course]; !ok {
+			courses = append(courses, n.Course)
+		}
+		byCourse[n.Course] = append(byCourse[n.Course], n)
+	}
+
+	var sb strings.Builder
+	if len(notifications) > 1 {
+		sb.WriteString(fmt.Sprintf("🔔 <b>%d updates on your subscriptions</b>\n\n", len(notifications)))
+	}
+	for i, course := range courses {
+		if i != 0 {
+			sb.WriteRune('\n')
+		}
+		sb.WriteString(fmt.Sprintf("<b>%s</b>\n", Escape(course)))
+		for _, n := range byCourse[course] {
+			sb.WriteString(n.Text)
+			sb.WriteRune('\n')
+		}
+	}
+
+	return sb.String()
+}
+
+const (
+	// maxMessageLength is the limit of Telegram on the text of a message in UTF-16 code units.
+	// Digests count their markup too, so they stay under it
+	maxMessageLength = 4096
+	// maxKeyboardButtons is the limit of Telegram on the buttons under a message
+	maxKeyboardButtons = 100
+)
+
+// SplitDigest cuts notifications of a single user into consecutive digests, each of them
+// fitting into a single message with its buttons
+func SplitDigest(notifications []*models.Notification) [][]*models.Notification {
+	var (
+		digests [][]*models.Notification
+		start   int
+	)
+	for end := start + 1; end <= len(notifications); end++ {
+		if end-start > 1 && !fitsMessage(notifications[start:end]) {
+			digests = append(digests, slices.Clip(notifications[start:end-1]))
+			start = end - 1
+		}
+	}
+	if start < len(notifications) {
+		digests = append(digests, slices.Clip(notifications[start:]))
+	}
+	return digests
+}
+
+func fitsMessage(notifications []*models.Notification) bool {
+	if len(utf16.Encode([]rune(FormatDigest(notifications)))) > maxMessageLength {
+		return false
+	}
+
+	buttons := 0
+	for _, row := range digestKeyboard(notifications) {
+		buttons += len(row)
+	}
+	return buttons <= maxKeyboardButtons
+}
+
+// AddDigest adds a single message with all notifications and buttons to unsubscribe from them.
+// Notifications have to fit into a single message, see SplitDigest
+func (mf *MessageFormatter) AddDigest(notifications []*models.Notification) {
+	mf.AddString(FormatDigest(notifications))
+
+	silent := true
+	for _, n := range notifications {
+		silent = silent && n.Silent
+	}
+	msgCfg := mf.messages[len(mf.messages)-1].(tapi.MessageConfig)
+	msgCfg.DisableNotification = silent
+	mf.messages[len(mf.messages)-1] = msgCfg
+
+	keyboard := digestKeyboard(notifications)
+	if len(keyboard) != 0 {
+		mf.AddKeyboardToLastMessage(keyboard)
+	}
+}
+
+func digestKeyboard(notifications []*models.Notification) [][]tapi.InlineKeyboardButton {
+	var (
+		keyboard [][]tapi.InlineKeyboardButton
+		seen     []string
+	)
+	for _, n := range notifications {
+		var row []tapi.InlineKeyboardButton
+		switch n.Kind {
+		case models.NotificationSeat:
+			show := fmt.Sprintf("course_%s", n.Course)
+			if !slices.Contains(seen, show) && len(show) <= maxCallbackData {
+				seen = append(seen, show)
+				keyboard = append(keyboard, []tapi.InlineKeyboardButton{
+					{Text: fmt.Sprintf("ℹ️ Show %s", n.Course), CallbackData: &show},
+				})
+			}
+			row = sectionButtons(n.Course, n.Section)
+		case models.NotificationSectionGone:
+			row = []tapi.InlineKeyboardButton{
+				button(fmt.Sprintf("✖ Unsubscribe %s %s", n.Course, n.Section), fmt.Sprintf("unsubscribe_%s_%s", n.Course, n.Section)),
+			}
+		case models.NotificationCourseGone:
+			row = []tapi.InlineKeyboardButton{
+				button(fmt.Sprintf("✖ Unsubscribe %s", n.Course), fmt.Sprintf("unsubscribe_%s", n.Course)),
+			}
+		case models.NotificationBundle:
+			row = []tapi.InlineKeyboardButton{
+				button(fmt.Sprintf("✖ Remove bundle %s", n.Course), fmt.Sprintf("unbundle_%d", n.BundleID)),
+			}
+		case models.NotificationExpiring:
+			row = []tapi.InlineKeyboardButton{
+				button(fmt.Sprintf("👀 Keep watching %s", n.Course), fmt.Sprintf("keep_%s", n.Course)),
+			}
+		default:
+			continue
+		}
+
+		// a digest with a button over the limit of Telegram is rejected as a whole
+		row = slices.DeleteFunc(row, func(b tapi.InlineKeyboardButton) bool { return len(*b.CallbackData) > maxCallbackData })
+		if len(row) == 0 {
+			continue
+		}
+		key := *row[len(row)-1].CallbackData
+		if slices.Contains(seen, key) {
+			continue
+		}
+		seen = append(seen, key)
+
+		keyboard = append(keyboard, row)
+	}
+	return keyboard
+}
+
+// sectionButtons lets to snooze or drop a single section right from its notification.
+// Buttons may be over the limit of Telegram, digestKeyboard leaves them out
+func sectionButtons(course, section string) []tapi.InlineKeyboardButton {
+	return []tapi.InlineKeyboardButton{
+		button(fmt.Sprintf("💤 %s 1h", section), fmt.Sprintf("snooze_%s_%s_1h", course, section)),
+		button(fmt.Sprintf("💤 %s 6h", section), fmt.Sprintf("snooze_%s_%s_6h", course, section)),
+		button(fmt.Sprintf("✖ %s", section), fmt.Sprintf("unsubscribe_%s_%s", course, section)),
+	}
+}
+
+func button(text, data string) tapi.InlineKeyboardButton {
+	return tapi.InlineKeyboardButton{Text: text, CallbackData: &data}
+}
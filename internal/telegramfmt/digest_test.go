@@ -0,0 +1,35 @@
+package telegramfmt
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/TheTeemka/telegram_bot_cources/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDigestKeyboardFitsCallbackData(t *testing.T) {
+	long := "CHEM 211/BIOL 211/ENVS 211/GEOL 211/ROBT 211"
+	keyboard := digestKeyboard([]*models.Notification{
+		{Kind: models.NotificationSeat, Course: "PHYS 161", Section: "1L"},
+		{Kind: models.NotificationSeat, Course: long, Section: "10ORIENT"},
+		{Kind: models.NotificationSeat, Course: long, Section: strings.Repeat("1", 60) + "L"},
+		{Kind: models.NotificationCourseGone, Course: strings.Repeat("X", 60)},
+	})
+
+	var data [][]string
+	for _, row := range keyboard {
+		var line []string
+		for _, b := range row {
+			assert.LessOrEqual(t, len(*b.CallbackData), maxCallbackData)
+			line = append(line, *b.CallbackData)
+		}
+		data = append(data, line)
+	}
+	assert.Equal(t, [][]string{
+		{"course_PHYS 161"},
+		{"snooze_PHYS 161_1L_1h", "snooze_PHYS 161_1L_6h", "unsubscribe_PHYS 161_1L"},
+		{"course_" + long},
+		{"snooze_" + long + "_10ORIENT_1h", "snooze_" + long + "_10ORIENT_6h"},
+	}, data, "buttons over the limit are left out")
+}
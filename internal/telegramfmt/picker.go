@@ -0,0 +1,87 @@
+package telegramfmt
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/TheTeemka/telegram_bot_cources/internal/models"
+	tapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// FormatSectionPicker is the text above the section picker of a course
+func FormatSectionPicker(course *models.Course, selected []string, lastTimeParsed time.Time) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("📝 <b>%s</b> %s\n", Escape(course.AbbrName), Escape(course.FullName)))
+	sb.WriteString("Tap the sections to watch, then Confirm.\n\n")
+	if len(selected) == 0 {
+		sb.WriteString("Selected: <i>nothing yet</i>\n")
+	} else {
+		sb.WriteString(fmt.Sprintf("Selected: <b>%s</b>\n", Escape(strings.Join(selected, ", "))))
+	}
+	sb.WriteString(fmt.Sprintf("\n<i>%s</i>", lastTimeParsed.Format("Last Update on: 15:04:05 02.01.2006")))
+	return sb.String()
+}
+
+// maxCallbackData is the limit of Telegram on the data of a button, in bytes
+const maxCallbackData = 64
+
+// pickerButton returns a button of the pickers, false if its data doesn't fit into the limit of Telegram.
+// Cross-listed courses are found under every code, so the first one stands for a long name
+func pickerButton(text, action string, course *models.Course, args ...string) (tapi.InlineKeyboardButton, bool) {
+	for _, name := range []string{course.AbbrName, strings.Split(course.AbbrName, "/")[0]} {
+		data := strings.Join(append([]string{action, name}, args...), "_")
+		if len(data) <= maxCallbackData {
+			return button(text, data), true
+		}
+	}
+	return tapi.InlineKeyboardButton{}, false
+}
+
+// SectionPickerKeyboard lists sections with their seats, every component starts its own row.
+// Sections, whose buttons don't fit into the limit of Telegram, are left out
+func SectionPickerKeyboard(course *models.Course, selected []string) [][]tapi.InlineKeyboardButton {
+	const perRow = 3
+
+	var (
+		keyboard  [][]tapi.InlineKeyboardButton
+		component string
+	)
+	for _, section := range course.Sections {
+		mark := "▫️"
+		if slices.Contains(selected, section.SectionName) {
+			mark = "✅"
+		}
+		b, ok := pickerButton(fmt.Sprintf("%s %s %d/%d", mark, section.SectionName, section.Size, section.Cap),
+			"toggle", course, section.SectionName)
+		if !ok {
+			continue
+		}
+
+		last := len(keyboard) - 1
+		if last < 0 || section.Component() != component || len(keyboard[last]) == perRow {
+			keyboard = append(keyboard, []tapi.InlineKeyboardButton{})
+			last++
+		}
+		component = section.Component()
+		keyboard[last] = append(keyboard[last], b)
+	}
+
+	controls := []tapi.InlineKeyboardButton{button("✖ Cancel", "abort")}
+	if confirm, ok := pickerButton("✔ Confirm", "confirm", course); ok {
+		controls = append([]tapi.InlineKeyboardButton{confirm}, controls...)
+	}
+	return append(keyboard, controls)
+}
+
+// CoursePickerKeyboard lets to pick one of the courses found by a search
+func CoursePickerKeyboard(courses []*models.Course) [][]tapi.InlineKeyboardButton {
+	keyboard := make([][]tapi.InlineKeyboardButton, 0, len(courses)+1)
+	for _, course := range courses {
+		if b, ok := pickerButton(fmt.Sprintf("%s %s", course.AbbrName, course.FullName), "pick", course); ok {
+			keyboard = append(keyboard, []tapi.InlineKeyboardButton{b})
+		}
+	}
+	return append(keyboard, []tapi.InlineKeyboardButton{button("✖ Cancel", "abort")})
+}
@@ -0,0 +1,76 @@
+package telegramfmt
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/TheTeemka/telegram_bot_cources/internal/models"
+	tapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSectionPickerKeyboard(t *testing.T) {
+	course := &models.Course{AbbrName: "PHYS 161", Sections: []*models.Section{
+		{SectionName: "1L", Size: 50, Cap: 50},
+		{SectionName: "2L", Size: 12, Cap: 50},
+		{SectionName: "1PLB", Size: 3, Cap: 24},
+		{SectionName: "2PLB", Size: 24, Cap: 24},
+		{SectionName: "3PLB", Size: 0, Cap: 24},
+		{SectionName: "4PLB", Size: 0, Cap: 24},
+	}}
+
+	keyboard := SectionPickerKeyboard(course, []string{"2L"})
+
+	var texts [][]string
+	for _, row := range keyboard {
+		var line []string
+		for _, b := range row {
+			line = append(line, b.Text)
+		}
+		texts = append(texts, line)
+	}
+	assert.Equal(t, [][]string{
+		{"▫️ 1L 50/50", "✅ 2L 12/50"},
+		{"▫️ 1PLB 3/24", "▫️ 2PLB 24/24", "▫️ 3PLB 0/24"},
+		{"▫️ 4PLB 0/24"},
+		{"✔ Confirm", "✖ Cancel"},
+	}, texts)
+	assert.Equal(t, "toggle_PHYS 161_1PLB", *keyboard[1][0].CallbackData)
+	assert.Equal(t, "confirm_PHYS 161", *keyboard[3][0].CallbackData)
+}
+
+func TestPickerKeyboardsFitCallbackData(t *testing.T) {
+	crossListed := &models.Course{AbbrName: "CHEM 211/BIOL 211/ENVS 211/GEOL 211/PHYS 211/MATH 211/ROBT 211", Sections: []*models.Section{
+		{SectionName: "1L", Size: 3, Cap: 20},
+	}}
+	long := &models.Course{AbbrName: "CHEM 211 " + strings.Repeat("X", 60), Sections: []*models.Section{
+		{SectionName: "1L", Size: 3, Cap: 20},
+	}}
+
+	keyboard := SectionPickerKeyboard(crossListed, nil)
+	assert.Equal(t, "toggle_CHEM 211_1L", *keyboard[0][0].CallbackData, "a long cross-listed course goes by its first code")
+	assert.Equal(t, "confirm_CHEM 211", *keyboard[1][0].CallbackData)
+
+	keyboard = CoursePickerKeyboard([]*models.Course{long, crossListed})
+	assert.Len(t, keyboard, 2, "a course with too long code is left out")
+	assert.Equal(t, "pick_CHEM 211", *keyboard[0][0].CallbackData)
+
+	mf := NewMessageFormatter(1)
+	mf.AddString("PHYS 161")
+	mf.QuickSubscribeKeyboard(&models.Course{AbbrName: "PHYS 161", Sections: []*models.Section{
+		{SectionName: "1L"}, {SectionName: strings.Repeat("1", 60) + "L"}, {SectionName: "2L"},
+	}})
+	markup := mf.Messages()[0].(tapi.MessageConfig).ReplyMarkup.(tapi.InlineKeyboardMarkup)
+	assert.Len(t, markup.InlineKeyboard, 2)
+	assert.Len(t, markup.InlineKeyboard[0], 2, "a section with too long name is left out")
+	assert.Equal(t, "subscribe_PHYS 161_2L", *markup.InlineKeyboard[0][1].CallbackData)
+
+	keyboard = SectionPickerKeyboard(long, nil)
+	assert.Len(t, keyboard, 1)
+	assert.Equal(t, "abort", *keyboard[0][0].CallbackData)
+	for _, row := range keyboard {
+		for _, b := range row {
+			assert.LessOrEqual(t, len(*b.CallbackData), maxCallbackData)
+		}
+	}
+}
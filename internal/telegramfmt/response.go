@@ -2,7 +2,9 @@ package telegramfmt
 
 import (
 	"fmt"
+	"strings"
 
+	"github.com/TheTeemka/telegram_bot_cources/internal/models"
 	tapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
 
@@ -103,3 +105,64 @@ func (mf *MessageFormatter) UnsubscribeOrIgnoreCourse(courseAbbr string) {
 		},
 	})
 }
+
+// NotifyWhenOfferedOrIgnore lets to register an interest in a course that is not in the catalog.
+// Nothing is added if the course code doesn't fit into a button
+func (mf *MessageFormatter) NotifyWhenOfferedOrIgnore(courseAbbr string) {
+	ignore := "delete"
+	interest := fmt.Sprintf("interest_%s;delete", courseAbbr)
+	if len(interest) > maxCallbackData {
+		return
+	}
+	mf.AddKeyboardToLastMessage([][]tapi.InlineKeyboardButton{
+		{
+			{Text: "Ignore", CallbackData: &ignore},
+			{Text: "🕓 Notify me when it appears", CallbackData: &interest},
+		},
+	})
+}
+
+func (mf *MessageFormatter) RemoveOrIgnoreBundle(bundleID int64) {
+	ignore := "delete"
+	remove := fmt.Sprintf("unbundle_%d;delete", bundleID)
+	mf.AddKeyboardToLastMessage([][]tapi.InlineKeyboardButton{
+		{
+			{Text: "Ignore", CallbackData: &ignore},
+			{Text: "Remove bundle", CallbackData: &remove},
+		},
+	})
+}
+
+func (mf *MessageFormatter) RemoveBundlesKeyboard(bundles []*models.BundleSubscription) {
+	keyboard := make([][]tapi.InlineKeyboardButton, 0, len(bundles))
+	for _, bundle := range bundles {
+		remove := fmt.Sprintf("unbundle_%d;delete", bundle.ID)
+		keyboard = append(keyboard, []tapi.InlineKeyboardButton{{
+			Text:         fmt.Sprintf("Remove bundle %s (%s)", bundle.Course, strings.Join(bundle.Sections, ", ")),
+			CallbackData: &remove,
+		}})
+	}
+	mf.AddKeyboardToLastMessage(keyboard)
+}
+
+// QuickSubscribeKeyboard lets to subscribe to any section of the course with a single tap.
+// Sections, whose buttons don't fit into the limit of Telegram, are left out
+func (mf *MessageFormatter) QuickSubscribeKeyboard(course *models.Course) {
+	const perRow = 4
+
+	var keyboard [][]tapi.InlineKeyboardButton
+	for _, section := range course.Sections {
+		b, ok := pickerButton("🔔 "+section.SectionName, "subscribe", course, section.SectionName)
+		if !ok {
+			continue
+		}
+		if len(keyboard) == 0 || len(keyboard[len(keyboard)-1]) == perRow {
+			keyboard = append(keyboard, []tapi.InlineKeyboardButton{})
+		}
+		keyboard[len(keyboard)-1] = append(keyboard[len(keyboard)-1], b)
+	}
+
+	ignore := "delete"
+	keyboard = append(keyboard, []tapi.InlineKeyboardButton{{Text: "Ignore", CallbackData: &ignore}})
+	mf.AddKeyboardToLastMessage(keyboard)
+}
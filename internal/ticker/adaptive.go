@@ -0,0 +1,65 @@
+package ticker
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	// busySeatChanges is how many seat changes in a single parse make polling faster
+	busySeatChanges  = 10
+	adaptiveSpeedUp  = 2
+	adaptiveSlowDown = 1.5
+)
+
+// AdaptiveInterval tunes the default polling interval to how busy the catalog is:
+// it halves the interval after a busy parse and grows it after a parse with no seat changes
+type AdaptiveInterval struct {
+	lowest  time.Duration
+	highest time.Duration
+
+	mu     sync.Mutex
+	cur    time.Duration
+	reason string
+}
+
+func NewAdaptiveInterval(start, lowest, highest time.Duration) (*AdaptiveInterval, error) {
+	if lowest <= 0 || lowest > highest {
+		return nil, fmt.Errorf("invalid adaptive interval bounds %s-%s", lowest, highest)
+	}
+	return &AdaptiveInterval{
+		lowest:  lowest,
+		highest: highest,
+		cur:     clamp(start, lowest, highest),
+		reason:  "no parses observed yet",
+	}, nil
+}
+
+// Observe adapts the interval to the number of seat changes found over the latest default interval
+func (a *AdaptiveInterval) Observe(seatChanges int) (time.Duration, string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	switch {
+	case seatChanges >= busySeatChanges:
+		a.cur = clamp(a.cur/adaptiveSpeedUp, a.lowest, a.highest)
+		a.reason = fmt.Sprintf("%d seat changes in the latest update", seatChanges)
+	case seatChanges == 0:
+		a.cur = clamp(time.Duration(float64(a.cur)*adaptiveSlowDown), a.lowest, a.highest)
+		a.reason = "no seat changes in the latest update"
+	default:
+		a.reason = fmt.Sprintf("only %d seat changes in the latest update", seatChanges)
+	}
+	return a.cur, a.reason
+}
+
+func (a *AdaptiveInterval) Current() (time.Duration, string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.cur, a.reason
+}
+
+func clamp(d, lowest, highest time.Duration) time.Duration {
+	return min(max(d.Round(time.Minute), lowest), highest)
+}
@@ -0,0 +1,27 @@
+package ticker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdaptiveInterval(t *testing.T) {
+	a, err := NewAdaptiveInterval(3*time.Hour, 20*time.Minute, 4*time.Hour)
+	assert.NoError(t, err)
+
+	d, _ := a.Observe(0)
+	assert.Equal(t, 4*time.Hour, d, "quiet catalog is polled less often, up to the upper bound")
+
+	d, _ = a.Observe(5)
+	assert.Equal(t, 4*time.Hour, d, "a few changes keep the interval")
+
+	for range 5 {
+		d, _ = a.Observe(busySeatChanges)
+	}
+	assert.Equal(t, 20*time.Minute, d, "busy catalog is polled more often, down to the lower bound")
+
+	_, err = NewAdaptiveInterval(time.Hour, 2*time.Hour, time.Hour)
+	assert.Error(t, err)
+}
@@ -2,55 +2,20 @@ package ticker
 
 import (
 	"log/slog"
+	"sync"
 	"time"
-)
-
-var registrationTickerIntervalConfig = []TickerIntervalConfig{
-	{
-		Till:  parseTime("2025-12-17T09:00:00+05:00"),
-		Label: "First Priority for 4,5,6 UG",
-	},
-	{
-		Till:  parseTime("2025-12-17T11:00:00+05:00"),
-		Label: "First Priority for 3 UG",
-	},
-	{
-		Till:  parseTime("2025-12-17T13:00:00+05:00"),
-		Label: "First Priority for 2 UG",
-	},
-	{
-		Till:  parseTime("2025-12-17T15:00:00+05:00"),
-		Label: "First Priority for 1 UG",
-	},
-
-	{
-		Till:  parseTime("2025-12-18T09:00:00+05:00"),
-		Label: "Second Priority for 4,5,6 UG",
-	},
-	{
-		Till:  parseTime("2025-12-18T11:00:00+05:00"),
-		Label: "Second Priority for 3 UG",
-	},
-	{
-		Till:  parseTime("2025-12-18T13:00:00+05:00"),
-		Label: "Second Priority for 2 UG",
-	},
-	{
-		Till:  parseTime("2025-12-18T15:00:00+05:00"),
-		Label: "Second Priority for 1 UG",
-	},
-
-	{
-		Till:  parseTime("2025-12-19T15:00:00+05:00"),
-		Label: "Third Priority for All UG",
-	},
-}
 
-var registrationTickerInterval = ParseTimeConfig(registrationTickerIntervalConfig)
+	"github.com/TheTeemka/telegram_bot_cources/internal/clock"
+)
 
+// TickerIntervalConfig is a registration window
 type TickerIntervalConfig struct {
-	Till  time.Time
-	Label string
+	ID int64 // set for windows added by admins, zero for the ones from the file
+	// Till is when registration of the cohort of the window opens, polling ramps around it
+	// and users of the cohort are reminded before it
+	Till    time.Time
+	Label   string
+	Profile string // ramp profile, the default one when empty
 }
 
 type TickerInterval struct {
@@ -61,38 +26,101 @@ type TickerInterval struct {
 }
 
 type DynamicTicker struct {
-	C                   chan time.Time
-	TimePoint           time.Time
-	stop                chan struct{}
-	TickerIntervals     []TickerInterval
+	C        chan time.Time
+	stop     chan struct{}
+	wake     chan struct{}
+	schedule *Schedule
+	clock    clock.Clock
+
+	mu                  sync.Mutex
+	timePoint           time.Time
 	defaultTimeInterval time.Duration
+	defaultReason       string
 }
 
-func NewDynamicTicker(timeInterval time.Duration) *DynamicTicker {
+func NewDynamicTicker(timeInterval time.Duration, schedule *Schedule, clk clock.Clock) *DynamicTicker {
 	t := &DynamicTicker{
 		C:                   make(chan time.Time, 1),
 		stop:                make(chan struct{}),
-		TickerIntervals:     registrationTickerInterval,
+		wake:                make(chan struct{}, 1),
+		schedule:            schedule,
+		clock:               clk,
 		defaultTimeInterval: timeInterval,
+		defaultReason:       "default interval",
 	}
 	go t.run()
 	return t
 }
 
+// TimePoint is when the next tick is due
+func (t *DynamicTicker) TimePoint() time.Time {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.timePoint
+}
+
+// SetDefaultInterval changes the interval used outside registration windows, the next tick is recomputed right away
+func (t *DynamicTicker) SetDefaultInterval(d time.Duration, reason string) {
+	t.mu.Lock()
+	t.defaultTimeInterval = d
+	t.defaultReason = reason
+	t.mu.Unlock()
+
+	select {
+	case t.wake <- struct{}{}:
+	default: // already going to recompute
+	}
+}
+
+// Interval is how often the catalog is polled right now and why
+func (t *DynamicTicker) Interval() (time.Duration, string) {
+	now := t.clock.Now()
+	t.mu.Lock()
+	cur, reason := t.defaultTimeInterval, t.defaultReason
+	t.mu.Unlock()
+
+	for _, tt := range t.schedule.Intervals() {
+		if isWithin(now, tt) {
+			cur, reason = tt.Interval, "registration window: "+tt.Label
+		}
+	}
+	return cur, reason
+}
+
+// InWindow tells whether the ramp of a registration window sets the interval right now
+func (t *DynamicTicker) InWindow() bool {
+	now := t.clock.Now()
+	for _, tt := range t.schedule.Intervals() {
+		if isWithin(now, tt) {
+			return true
+		}
+	}
+	return false
+}
+
 func (t *DynamicTicker) run() {
 	location := time.FixedZone("UTC+5", 5*60*60)
 
 	for {
-		d := t.getDuration()
-		t.TimePoint = time.Now().In(location).Add(d)
+		changed := t.schedule.Changed()
+		now := t.clock.Now().In(location)
+		d := t.getDuration(now)
+		t.mu.Lock()
+		t.timePoint = now.Add(d)
+		t.mu.Unlock()
 		slog.Debug("Dynamic ticker started", "duration", d.String())
-		timer := time.NewTimer(d)
+		timer := t.clock.NewTimer(d)
 		select {
-		case now := <-timer.C:
+		case now := <-timer.C():
 			select {
 			case t.C <- now:
 			default: // drop tick if nobody is listening
 			}
+		case <-changed:
+			// the schedule was reloaded, the next tick may be due sooner or later
+			timer.Stop()
+		case <-t.wake:
+			timer.Stop()
 		case <-t.stop:
 			timer.Stop()
 			close(t.C)
@@ -105,18 +133,17 @@ func (t *DynamicTicker) Stop() {
 	close(t.stop)
 }
 
-func (t *DynamicTicker) getDuration() time.Duration {
-	location := time.FixedZone("UTC+5", 5*60*60)
-
+func (t *DynamicTicker) getDuration(now time.Time) time.Duration {
+	t.mu.Lock()
 	cur := t.defaultTimeInterval
-	for _, tt := range t.TickerIntervals {
-		now := time.Now().In(location)
+	t.mu.Unlock()
+
+	for _, tt := range t.schedule.Intervals() {
 		if isWithin(now, tt) {
 			cur = tt.Interval
 		}
-		// slog.Debug("Checking ticker interval", "after", tt.From.After(now), "before", tt.From.Before(now.Add(cur)), "current", cur.String(), "from", tt.From.String(), "now+add", now.Add(cur).String())
 		if tt.From.After(now) && tt.From.Before(now.Add(cur)) {
-			cur = time.Until(tt.From)
+			cur = tt.From.Sub(now)
 		}
 	}
 
@@ -127,43 +154,23 @@ func isWithin(t time.Time, cfg TickerInterval) bool {
 	return (t.After(cfg.From) || t.Equal(cfg.From)) && (t.Before(cfg.Till) || t.Equal(cfg.Till))
 }
 
-func ParseTimeConfig(timeConfigs []TickerIntervalConfig) []TickerInterval {
-	tt := make([]TickerInterval, 0, len(timeConfigs)*4)
+// ParseTimeConfig expands every window into the steps of its ramp profile, unknown profiles fall back to the default one
+func ParseTimeConfig(timeConfigs []TickerIntervalConfig, profiles map[string]RampProfile) []TickerInterval {
+	tt := make([]TickerInterval, 0, len(timeConfigs)*5)
 	for _, t := range timeConfigs {
-		tt = append(tt, TickerInterval{
-			From:     t.Till.Add(-1 * time.Hour),
-			Till:     t.Till.Add(-30 * time.Minute),
-			Interval: 30 * time.Minute,
-			Label:    t.Label,
-		})
-
-		tt = append(tt, TickerInterval{
-			From:     t.Till.Add(-30 * time.Minute),
-			Till:     t.Till.Add(-15 * time.Minute),
-			Interval: 15 * time.Minute,
-			Label:    t.Label,
-		})
-
-		tt = append(tt, TickerInterval{
-			From:     t.Till.Add(-15 * time.Minute),
-			Till:     t.Till.Add(-5 * time.Minute),
-			Interval: 5 * time.Minute,
-			Label:    t.Label,
-		})
-
-		tt = append(tt, TickerInterval{
-			From:     t.Till.Add(-5 * time.Minute),
-			Till:     t.Till.Add(5 * time.Minute),
-			Interval: 1 * time.Minute,
-			Label:    t.Label,
-		})
-
-		tt = append(tt, TickerInterval{
-			From:     t.Till.Add(5 * time.Minute),
-			Till:     t.Till.Add(30 * time.Minute),
-			Interval: 3 * time.Minute,
-			Label:    t.Label,
-		})
+		profile, ok := profiles[t.Profile]
+		if !ok {
+			profile = profiles[DefaultRampProfile]
+		}
+
+		for _, step := range profile.Steps {
+			tt = append(tt, TickerInterval{
+				From:     t.Till.Add(step.From),
+				Till:     t.Till.Add(step.Till),
+				Interval: step.Interval,
+				Label:    t.Label,
+			})
+		}
 	}
 
 	return tt
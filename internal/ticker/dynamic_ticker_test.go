@@ -4,6 +4,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/TheTeemka/telegram_bot_cources/internal/clock"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -58,7 +59,107 @@ func TestParseTimeConfig(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			assert.Equal(t, ParseTimeConfig(tt.args), tt.want)
+			assert.Equal(t, ParseTimeConfig(tt.args, DefaultRampProfiles()), tt.want)
 		})
 	}
 }
+
+// nextTick moves the fake clock to the next tick the ticker waits for
+func nextTick(t *testing.T, fake *clock.Fake, ticker *DynamicTicker) time.Time {
+	t.Helper()
+	fake.BlockUntil(1)
+	fake.Advance(ticker.TimePoint().Sub(fake.Now()))
+	return <-ticker.C
+}
+
+func TestDynamicTickerRampsAroundWindow(t *testing.T) {
+	till := parseTime("2025-12-17T09:00:00+05:00")
+	schedule := NewSchedule()
+	assert.NoError(t, schedule.Set(SourceFile, []TickerIntervalConfig{{Till: till, Label: "First Priority"}}))
+
+	fake := clock.NewFake(till.Add(-2 * time.Hour))
+	ticker := NewDynamicTicker(3*time.Hour, schedule, fake)
+	defer ticker.Stop()
+
+	var want []time.Time
+	for _, offset := range []time.Duration{-time.Hour, -30 * time.Minute, -15 * time.Minute, -10 * time.Minute} {
+		want = append(want, till.Add(offset))
+	}
+	for m := -5; m <= 5; m++ {
+		want = append(want, till.Add(time.Duration(m)*time.Minute))
+	}
+	for m := 8; m <= 32; m += 3 {
+		want = append(want, till.Add(time.Duration(m)*time.Minute))
+	}
+	want = append(want, till.Add(32*time.Minute+3*time.Hour))
+
+	assert.False(t, ticker.InWindow())
+	for i, w := range want {
+		got := nextTick(t, fake, ticker)
+		if !assert.True(t, w.Equal(got), "tick %d: want %s, got %s", i, w, got) {
+			return
+		}
+		assert.Equal(t, i < len(want)-2, ticker.InWindow(), "tick %d", i)
+	}
+}
+
+func TestDynamicTickerReloadsSchedule(t *testing.T) {
+	start := parseTime("2025-12-17T06:00:00+05:00")
+	schedule := NewSchedule()
+	fake := clock.NewFake(start)
+	ticker := NewDynamicTicker(3*time.Hour, schedule, fake)
+	defer ticker.Stop()
+
+	fake.BlockUntil(1)
+	assert.True(t, start.Add(3*time.Hour).Equal(ticker.TimePoint()))
+	interval, reason := ticker.Interval()
+	assert.Equal(t, 3*time.Hour, interval)
+	assert.Equal(t, "default interval", reason)
+
+	assert.NoError(t, schedule.Set(SourceAdmin, []TickerIntervalConfig{{ID: 1, Till: start.Add(90 * time.Minute), Label: "First Priority"}}))
+	assert.Eventually(t, func() bool {
+		return start.Add(30 * time.Minute).Equal(ticker.TimePoint())
+	}, time.Second, time.Millisecond, "the ticker must wake up for the window ramp")
+	assert.True(t, start.Add(30*time.Minute).Equal(nextTick(t, fake, ticker)))
+	interval, reason = ticker.Interval()
+	assert.Equal(t, 30*time.Minute, interval)
+	assert.Equal(t, "registration window: First Priority", reason)
+}
+
+func TestDynamicTickerSetDefaultInterval(t *testing.T) {
+	start := parseTime("2025-12-17T06:00:00+05:00")
+	fake := clock.NewFake(start)
+	ticker := NewDynamicTicker(3*time.Hour, NewSchedule(), fake)
+	defer ticker.Stop()
+
+	fake.BlockUntil(1)
+	ticker.SetDefaultInterval(time.Hour, "busy")
+	assert.Eventually(t, func() bool {
+		return start.Add(time.Hour).Equal(ticker.TimePoint())
+	}, time.Second, time.Millisecond, "the pending tick must be rescheduled")
+	assert.True(t, start.Add(time.Hour).Equal(nextTick(t, fake, ticker)))
+
+	interval, reason := ticker.Interval()
+	assert.Equal(t, time.Hour, interval)
+	assert.Equal(t, "busy", reason)
+}
+
+func TestDynamicTickerDropsUnreadTicks(t *testing.T) {
+	start := parseTime("2025-12-17T06:00:00+05:00")
+	fake := clock.NewFake(start)
+	ticker := NewDynamicTicker(time.Hour, NewSchedule(), fake)
+	defer ticker.Stop()
+
+	for range 3 {
+		fake.BlockUntil(1)
+		fake.Advance(time.Hour)
+	}
+	fake.BlockUntil(1)
+
+	assert.True(t, start.Add(time.Hour).Equal(<-ticker.C), "the first tick is kept till somebody reads it")
+	select {
+	case tick := <-ticker.C:
+		t.Errorf("ticks nobody listened to must be dropped, got %s", tick)
+	default:
+	}
+}
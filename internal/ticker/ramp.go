@@ -0,0 +1,76 @@
+package ticker
+
+import (
+	"fmt"
+	"time"
+)
+
+// DefaultRampProfile is used by windows that don't pick a profile
+const DefaultRampProfile = "default"
+
+// RampStep polls every Interval between From and Till, both are offsets from the end of a window
+type RampStep struct {
+	From     time.Duration
+	Till     time.Duration
+	Interval time.Duration
+}
+
+// RampProfile is how polling speeds up around the end of a registration window
+type RampProfile struct {
+	Name  string
+	Steps []RampStep
+}
+
+// DefaultRampProfiles are always available, a schedule file may override them
+func DefaultRampProfiles() map[string]RampProfile {
+	return map[string]RampProfile{
+		DefaultRampProfile: {Name: DefaultRampProfile, Steps: []RampStep{
+			{From: -1 * time.Hour, Till: -30 * time.Minute, Interval: 30 * time.Minute},
+			{From: -30 * time.Minute, Till: -15 * time.Minute, Interval: 15 * time.Minute},
+			{From: -15 * time.Minute, Till: -5 * time.Minute, Interval: 5 * time.Minute},
+			{From: -5 * time.Minute, Till: 5 * time.Minute, Interval: 1 * time.Minute},
+			{From: 5 * time.Minute, Till: 30 * time.Minute, Interval: 3 * time.Minute},
+		}},
+		// add/drop keeps shifting for hours after the deadline, while requests are being approved
+		"add-drop": {Name: "add-drop", Steps: []RampStep{
+			{From: -1 * time.Hour, Till: -15 * time.Minute, Interval: 15 * time.Minute},
+			{From: -15 * time.Minute, Till: 5 * time.Minute, Interval: 1 * time.Minute},
+			{From: 5 * time.Minute, Till: 1 * time.Hour, Interval: 3 * time.Minute},
+			{From: 1 * time.Hour, Till: 6 * time.Hour, Interval: 15 * time.Minute},
+		}},
+	}
+}
+
+// Validate checks the steps follow each other without overlaps or gaps
+func (p RampProfile) Validate() error {
+	if len(p.Steps) == 0 {
+		return fmt.Errorf("ramp %q has no steps", p.Name)
+	}
+	for i, s := range p.Steps {
+		if s.From >= s.Till {
+			return fmt.Errorf("ramp %q: step %d starts at %s, not before its end %s", p.Name, i+1, s.From, s.Till)
+		}
+		if s.Interval <= 0 {
+			return fmt.Errorf("ramp %q: step %d has no interval", p.Name, i+1)
+		}
+		if i == 0 {
+			continue
+		}
+		prev := p.Steps[i-1]
+		if s.From < prev.Till {
+			return fmt.Errorf("ramp %q: step %d overlaps the previous one", p.Name, i+1)
+		}
+		if s.From > prev.Till {
+			return fmt.Errorf("ramp %q: gap between %s and %s", p.Name, prev.Till, s.From)
+		}
+	}
+	return nil
+}
+
+// End is the offset from the end of a window where the ramp stops
+func (p RampProfile) End() time.Duration {
+	if len(p.Steps) == 0 {
+		return 0
+	}
+	return p.Steps[len(p.Steps)-1].Till
+}
@@ -0,0 +1,231 @@
+package ticker
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Sources of registration windows, the schedule is the union of all of them
+const (
+	SourceFile  = "file"
+	SourceAdmin = "admin"
+)
+
+// Schedule is the set of registration windows the ticker speeds up around.
+// It can be replaced at runtime, listeners learn about it through Changed
+type Schedule struct {
+	mu        sync.RWMutex
+	sources   map[string][]TickerIntervalConfig
+	profiles  map[string]RampProfile
+	windows   []TickerIntervalConfig
+	intervals []TickerInterval
+	changed   chan struct{}
+}
+
+func NewSchedule() *Schedule {
+	return &Schedule{
+		sources:  make(map[string][]TickerIntervalConfig),
+		profiles: DefaultRampProfiles(),
+		changed:  make(chan struct{}),
+	}
+}
+
+// Set validates and replaces the windows of the source, waking up everyone waiting on Changed
+func (s *Schedule) Set(source string, windows []TickerIntervalConfig) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.apply(source, s.profiles, windows)
+}
+
+// Reload replaces ramp profiles together with the windows of the source, so windows may pick new profiles
+func (s *Schedule) Reload(source string, profiles map[string]RampProfile, windows []TickerIntervalConfig) error {
+	for _, p := range profiles {
+		if err := p.Validate(); err != nil {
+			return err
+		}
+	}
+	if _, ok := profiles[DefaultRampProfile]; !ok {
+		return fmt.Errorf("ramp %q is missing", DefaultRampProfile)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.apply(source, profiles, windows)
+}
+
+func (s *Schedule) apply(source string, profiles map[string]RampProfile, windows []TickerIntervalConfig) error {
+	merged, err := s.merge(source, profiles, windows)
+	if err != nil {
+		return err
+	}
+
+	s.sources[source] = slices.Clone(windows)
+	s.profiles = profiles
+	s.windows = merged
+	s.intervals = ParseTimeConfig(merged, profiles)
+	close(s.changed)
+	s.changed = make(chan struct{})
+	return nil
+}
+
+// Check tells whether Set would accept the windows without applying them
+func (s *Schedule) Check(source string, windows []TickerIntervalConfig) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	_, err := s.merge(source, s.profiles, windows)
+	return err
+}
+
+func (s *Schedule) merge(source string, profiles map[string]RampProfile, windows []TickerIntervalConfig) ([]TickerIntervalConfig, error) {
+	merged := slices.Clone(windows)
+	for name, other := range s.sources {
+		if name != source {
+			merged = append(merged, other...)
+		}
+	}
+	slices.SortStableFunc(merged, func(a, b TickerIntervalConfig) int {
+		return a.Till.Compare(b.Till)
+	})
+
+	if err := ValidateWindows(merged); err != nil {
+		return nil, err
+	}
+	for _, w := range merged {
+		if _, ok := profiles[w.Profile]; w.Profile != "" && !ok {
+			return nil, fmt.Errorf("window %q: unknown ramp %q", w.Label, w.Profile)
+		}
+	}
+	if err := validateRamps(merged, profiles); err != nil {
+		return nil, err
+	}
+	return merged, nil
+}
+
+// validateRamps checks the ramps of different windows don't overlap, the ticker would mix their
+// intervals otherwise. Ramps may touch each other
+func validateRamps(windows []TickerIntervalConfig, profiles map[string]RampProfile) error {
+	type span struct {
+		from, till time.Time
+		label      string
+	}
+	spans := make([]span, 0, len(windows))
+	for _, w := range windows {
+		profile, ok := profiles[w.Profile]
+		if !ok {
+			profile = profiles[DefaultRampProfile]
+		}
+		if len(profile.Steps) == 0 {
+			continue
+		}
+		spans = append(spans, span{from: w.Till.Add(profile.Steps[0].From), till: w.Till.Add(profile.End()), label: w.Label})
+	}
+	slices.SortFunc(spans, func(a, b span) int {
+		return a.from.Compare(b.from)
+	})
+
+	for i := 1; i < len(spans); i++ {
+		prev := spans[i-1]
+		if spans[i].from.Before(prev.till) {
+			return fmt.Errorf("ramps of windows %q and %q overlap between %s and %s",
+				prev.label, spans[i].label, spans[i].from.Format(time.RFC3339), prev.till.Format(time.RFC3339))
+		}
+		if spans[i].till.Before(prev.till) {
+			spans[i] = prev
+		}
+	}
+	return nil
+}
+
+// Windows returns the registration windows of every source ordered by their end
+func (s *Schedule) Windows() []TickerIntervalConfig {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return slices.Clone(s.windows)
+}
+
+// SourceWindows returns the registration windows of a single source as they were set
+func (s *Schedule) SourceWindows(source string) []TickerIntervalConfig {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return slices.Clone(s.sources[source])
+}
+
+func (s *Schedule) Intervals() []TickerInterval {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.intervals
+}
+
+// Profile returns a ramp profile by its name, the default one for an empty name
+func (s *Schedule) Profile(name string) (RampProfile, bool) {
+	if name == "" {
+		name = DefaultRampProfile
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	p, ok := s.profiles[name]
+	return p, ok
+}
+
+func (s *Schedule) ProfileNames() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return slices.Sorted(maps.Keys(s.profiles))
+}
+
+// ActiveProfile is the ramp of the nearest window whose ramp isn't over yet, the default one without such windows
+func (s *Schedule) ActiveProfile(now time.Time) RampProfile {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, w := range s.windows {
+		profile, ok := s.profiles[w.Profile]
+		if !ok {
+			profile = s.profiles[DefaultRampProfile]
+		}
+		if w.Till.Add(profile.End()).After(now) {
+			return profile
+		}
+	}
+	return s.profiles[DefaultRampProfile]
+}
+
+// Changed is closed on the next Set
+func (s *Schedule) Changed() <-chan struct{} {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.changed
+}
+
+// RegistrationEnd is when the last registration window of the schedule opens, zero without windows
+func (s *Schedule) RegistrationEnd() time.Time {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if len(s.windows) == 0 {
+		return time.Time{}
+	}
+	return s.windows[len(s.windows)-1].Till
+}
+
+// ValidateWindows checks windows ordered by their end
+func ValidateWindows(windows []TickerIntervalConfig) error {
+	for i, w := range windows {
+		if w.Label == "" {
+			return fmt.Errorf("window %d: label is empty", i+1)
+		}
+		if w.Till.IsZero() {
+			return fmt.Errorf("window %q: end time is missing", w.Label)
+		}
+		if i > 0 && windows[i-1].Till.Equal(w.Till) {
+			return fmt.Errorf("windows %q and %q end at the same time", windows[i-1].Label, w.Label)
+		}
+	}
+	return nil
+}
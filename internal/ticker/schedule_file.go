@@ -0,0 +1,224 @@
+package ticker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/TheTeemka/telegram_bot_cources/internal/clock"
+)
+
+const (
+	scheduleTimeLayout   = "2006-01-02 15:04"
+	scheduleFilePollTime = 10 * time.Second
+)
+
+// scheduleFile is the on-disk format of the registration schedule. Ramp steps are offsets
+// from the end of a window, profiles override the built-in ones with the same name:
+//
+//	{
+//	  "timezone": "UTC+5",
+//	  "profiles": {
+//	    "late-tail": [
+//	      {"from": "-1h", "till": "5m", "every": "5m"},
+//	      {"from": "5m", "till": "3h", "every": "10m"}
+//	    ]
+//	  },
+//	  "windows": [
+//	    {"till": "2025-12-17 09:00", "label": "First Priority for 4,5,6 UG"},
+//	    {"till": "2025-12-19 15:00", "label": "Add/Drop", "timezone": "Asia/Almaty", "profile": "late-tail"}
+//	  ]
+//	}
+type scheduleFile struct {
+	Timezone string                    `json:"timezone"`
+	Profiles map[string][]rampStepFile `json:"profiles"`
+	Windows  []struct {
+		Till     string `json:"till"`
+		Label    string `json:"label"`
+		Timezone string `json:"timezone"`
+		Profile  string `json:"profile"`
+	} `json:"windows"`
+}
+
+// LoadScheduleFile reads and validates ramp profiles and registration windows from a JSON file
+func LoadScheduleFile(path string) (map[string]RampProfile, []TickerIntervalConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("reading schedule file: %w", err)
+	}
+
+	var file scheduleFile
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&file); err != nil {
+		return nil, nil, fmt.Errorf("decoding schedule file: %w", err)
+	}
+
+	profiles := DefaultRampProfiles()
+	for name, steps := range file.Profiles {
+		profile := RampProfile{Name: name}
+		for i, s := range steps {
+			step, err := s.parse()
+			if err != nil {
+				return nil, nil, fmt.Errorf("ramp %q: step %d: %w", name, i+1, err)
+			}
+			profile.Steps = append(profile.Steps, step)
+		}
+		if err := profile.Validate(); err != nil {
+			return nil, nil, err
+		}
+		profiles[name] = profile
+	}
+
+	windows, err := parseWindows(file, profiles)
+	if err != nil {
+		return nil, nil, err
+	}
+	return profiles, windows, nil
+}
+
+func parseWindows(file scheduleFile, profiles map[string]RampProfile) ([]TickerIntervalConfig, error) {
+	defaultLocation, err := parseLocation(file.Timezone)
+	if err != nil {
+		return nil, err
+	}
+
+	windows := make([]TickerIntervalConfig, 0, len(file.Windows))
+	for i, w := range file.Windows {
+		location := defaultLocation
+		if w.Timezone != "" {
+			if location, err = parseLocation(w.Timezone); err != nil {
+				return nil, fmt.Errorf("window %d: %w", i+1, err)
+			}
+		}
+
+		till, err := time.ParseInLocation(scheduleTimeLayout, w.Till, location)
+		if err != nil {
+			return nil, fmt.Errorf("window %d: end time %q is not in %q format", i+1, w.Till, scheduleTimeLayout)
+		}
+		if _, ok := profiles[w.Profile]; w.Profile != "" && !ok {
+			return nil, fmt.Errorf("window %d: unknown ramp %q", i+1, w.Profile)
+		}
+		windows = append(windows, TickerIntervalConfig{Till: till, Label: w.Label, Profile: w.Profile})
+	}
+
+	// the file lists windows as people read them, so the order is checked as written
+	if err := ValidateWindows(windows); err != nil {
+		return nil, err
+	}
+	for i := 1; i < len(windows); i++ {
+		if windows[i].Till.Before(windows[i-1].Till) {
+			return nil, fmt.Errorf("window %q ends before the previous window %q", windows[i].Label, windows[i-1].Label)
+		}
+	}
+	return windows, nil
+}
+
+type rampStepFile struct {
+	From  string `json:"from"`
+	Till  string `json:"till"`
+	Every string `json:"every"`
+}
+
+func (s rampStepFile) parse() (RampStep, error) {
+	from, err := time.ParseDuration(s.From)
+	if err != nil {
+		return RampStep{}, fmt.Errorf("invalid start: %w", err)
+	}
+	till, err := time.ParseDuration(s.Till)
+	if err != nil {
+		return RampStep{}, fmt.Errorf("invalid end: %w", err)
+	}
+	every, err := time.ParseDuration(s.Every)
+	if err != nil {
+		return RampStep{}, fmt.Errorf("invalid interval: %w", err)
+	}
+	return RampStep{From: from, Till: till, Interval: every}, nil
+}
+
+// parseLocation accepts IANA names and fixed offsets like "UTC+5", the bot's own timezone by default
+func parseLocation(name string) (*time.Location, error) {
+	if name == "" {
+		name = "UTC+5"
+	}
+
+	if offset, ok := strings.CutPrefix(name, "UTC"); ok && offset != "" {
+		hours, err := strconv.Atoi(offset)
+		if err != nil || hours < -12 || hours > 14 {
+			return nil, fmt.Errorf("invalid timezone %q", name)
+		}
+		return time.FixedZone(name, hours*60*60), nil
+	}
+
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timezone %q: %w", name, err)
+	}
+	return location, nil
+}
+
+// WatchScheduleFile reloads the schedule on SIGHUP or when the file is modified.
+// A broken file is reported and the previous schedule is kept
+func WatchScheduleFile(ctx context.Context, path string, schedule *Schedule, clk clock.Clock) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	poll := clk.NewTicker(scheduleFilePollTime)
+	defer poll.Stop()
+
+	modTime := fileModTime(path)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			slog.Info("Received SIGHUP, reloading schedule", "path", path)
+		case <-poll.C():
+			cur := fileModTime(path)
+			if cur.Equal(modTime) {
+				continue
+			}
+			modTime = cur
+			slog.Info("Schedule file changed, reloading", "path", path)
+		}
+
+		if err := reloadSchedule(path, schedule); err != nil {
+			slog.Error("Failed to reload schedule, keeping the previous one", "error", err, "path", path)
+		}
+	}
+}
+
+func reloadSchedule(path string, schedule *Schedule) error {
+	profiles, windows, err := LoadScheduleFile(path)
+	if err != nil {
+		return err
+	}
+	if err := schedule.Reload(SourceFile, profiles, windows); err != nil {
+		return err
+	}
+	slog.Info("Schedule reloaded", "windows", len(windows), "registration_end", schedule.RegistrationEnd())
+	return nil
+}
+
+// fileModTime is zero for missing files, so the file appearing later counts as a change
+func fileModTime(path string) time.Time {
+	info, err := os.Stat(path)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			slog.Warn("Failed to stat schedule file", "error", err, "path", path)
+		}
+		return time.Time{}
+	}
+	return info.ModTime()
+}
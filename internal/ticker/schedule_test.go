@@ -0,0 +1,161 @@
+package ticker
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/TheTeemka/telegram_bot_cources/internal/clock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadScheduleFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []TickerIntervalConfig
+		wantErr bool
+	}{
+		{
+			name: "Default and per window timezones",
+			content: `{"timezone": "UTC+5", "windows": [
+				{"till": "2025-12-17 09:00", "label": "First"},
+				{"till": "2025-12-17 11:00", "label": "Second", "timezone": "UTC+6"}
+			]}`,
+			want: []TickerIntervalConfig{
+				{Till: parseTime("2025-12-17T09:00:00+05:00"), Label: "First"},
+				{Till: parseTime("2025-12-17T11:00:00+06:00"), Label: "Second"},
+			},
+		},
+		{
+			name: "Window with its own ramp",
+			content: `{"profiles": {"tail": [{"from": "-1h", "till": "5m", "every": "5m"}, {"from": "5m", "till": "3h", "every": "10m"}]},
+				"windows": [{"till": "2025-12-17 09:00", "label": "Add/Drop", "profile": "tail"}]}`,
+			want: []TickerIntervalConfig{
+				{Till: parseTime("2025-12-17T09:00:00+05:00"), Label: "Add/Drop", Profile: "tail"},
+			},
+		},
+		{name: "Unknown ramp", content: `{"windows": [{"till": "2025-12-17 09:00", "label": "First", "profile": "tail"}]}`, wantErr: true},
+		{name: "Ramp with a gap", content: `{"profiles": {"tail": [{"from": "-1h", "till": "-5m", "every": "5m"}, {"from": "5m", "till": "3h", "every": "10m"}]}, "windows": []}`, wantErr: true},
+		{name: "Windows out of order", content: `{"windows": [{"till": "2025-12-18 09:00", "label": "First"}, {"till": "2025-12-17 09:00", "label": "Second"}]}`, wantErr: true},
+		{name: "Same end", content: `{"windows": [{"till": "2025-12-17 09:00", "label": "First"}, {"till": "2025-12-17 09:00", "label": "Second"}]}`, wantErr: true},
+		{name: "Missing label", content: `{"windows": [{"till": "2025-12-17 09:00"}]}`, wantErr: true},
+		{name: "Bad time", content: `{"windows": [{"till": "17.12.2025 09:00", "label": "First"}]}`, wantErr: true},
+		{name: "Bad timezone", content: `{"timezone": "UTC+25", "windows": []}`, wantErr: true},
+		{name: "Unknown field", content: `{"windows": [{"till": "2025-12-17 09:00", "label": "First", "from": "2025-12-17 08:00"}]}`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "schedule.json")
+			assert.NoError(t, os.WriteFile(path, []byte(tt.content), 0644))
+
+			_, got, err := LoadScheduleFile(path)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			if !assert.Len(t, got, len(tt.want)) {
+				return
+			}
+			for i := range tt.want {
+				assert.True(t, tt.want[i].Till.Equal(got[i].Till), "window %d ends at %s", i, got[i].Till)
+				assert.Equal(t, tt.want[i].Label, got[i].Label)
+				assert.Equal(t, tt.want[i].Profile, got[i].Profile)
+			}
+		})
+	}
+}
+
+func TestScheduleSet(t *testing.T) {
+	s := NewSchedule()
+	assert.True(t, s.RegistrationEnd().IsZero())
+
+	changed := s.Changed()
+	windows := []TickerIntervalConfig{
+		{Till: parseTime("2025-12-18T09:00:00+05:00"), Label: "Second"},
+		{Till: parseTime("2025-12-17T09:00:00+05:00"), Label: "First"},
+	}
+	assert.NoError(t, s.Set(SourceFile, windows))
+	assert.Equal(t, parseTime("2025-12-18T09:00:00+05:00"), s.RegistrationEnd())
+	assert.Len(t, s.Intervals(), 10)
+	select {
+	case <-changed:
+	default:
+		t.Error("listeners were not told about the new schedule")
+	}
+
+	assert.Error(t, s.Set(SourceFile, []TickerIntervalConfig{{Till: parseTime("2025-12-17T09:00:00+05:00")}}))
+	assert.Equal(t, "First", s.Windows()[0].Label, "a rejected schedule must not replace the current one")
+
+	added := []TickerIntervalConfig{{ID: 1, Till: parseTime("2025-12-19T09:00:00+05:00"), Label: "Third"}}
+	assert.NoError(t, s.Set(SourceAdmin, added))
+	assert.Len(t, s.Windows(), 3)
+	assert.Equal(t, added, s.SourceWindows(SourceAdmin))
+	assert.Error(t, s.Check(SourceAdmin, append(added, TickerIntervalConfig{ID: 2, Till: parseTime("2025-12-18T09:00:00+05:00"), Label: "Clash"})),
+		"windows of different sources must not end at the same time")
+}
+
+func TestScheduleRampsOverlap(t *testing.T) {
+	s := NewSchedule()
+	assert.NoError(t, s.Reload(SourceFile, DefaultRampProfiles(), []TickerIntervalConfig{
+		{Till: parseTime("2025-12-17T09:00:00+05:00"), Label: "First"},
+		// the default ramp starts an hour before, right where the ramp of the first one ends
+		{Till: parseTime("2025-12-17T10:30:00+05:00"), Label: "Second"},
+	}))
+
+	err := s.Check(SourceAdmin, []TickerIntervalConfig{{Till: parseTime("2025-12-17T11:00:00+05:00"), Label: "Third"}})
+	assert.ErrorContains(t, err, `ramps of windows "Second" and "Third" overlap`, "ramps of different sources are checked together")
+
+	// the long tail of add/drop covers the next window
+	err = s.Check(SourceAdmin, []TickerIntervalConfig{
+		{Till: parseTime("2025-12-17T06:00:00+05:00"), Label: "Add/Drop", Profile: "add-drop"},
+	})
+	assert.ErrorContains(t, err, `ramps of windows "Add/Drop" and "First" overlap`)
+
+	assert.NoError(t, s.Check(SourceAdmin, []TickerIntervalConfig{{Till: parseTime("2025-12-17T12:30:00+05:00"), Label: "Third"}}))
+}
+
+func TestRampProfileValidate(t *testing.T) {
+	for name, p := range DefaultRampProfiles() {
+		assert.NoError(t, p.Validate(), name)
+	}
+
+	overlap := RampProfile{Name: "overlap", Steps: []RampStep{
+		{From: -time.Hour, Till: 0, Interval: 5 * time.Minute},
+		{From: -5 * time.Minute, Till: time.Hour, Interval: time.Minute},
+	}}
+	assert.Error(t, overlap.Validate())
+
+	gap := RampProfile{Name: "gap", Steps: []RampStep{
+		{From: -time.Hour, Till: -10 * time.Minute, Interval: 5 * time.Minute},
+		{From: -5 * time.Minute, Till: time.Hour, Interval: time.Minute},
+	}}
+	assert.Error(t, gap.Validate())
+
+	assert.Error(t, RampProfile{Name: "no interval", Steps: []RampStep{{From: -time.Hour, Till: 0}}}.Validate())
+	assert.Error(t, RampProfile{Name: "empty"}.Validate())
+}
+
+func TestWatchScheduleFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "schedule.json")
+	assert.NoError(t, os.WriteFile(path, []byte(`{"windows": [{"till": "2025-12-17 09:00", "label": "First"}]}`), 0644))
+	schedule := NewSchedule()
+	assert.NoError(t, reloadSchedule(path, schedule))
+
+	fake := clock.NewFake(parseTime("2025-12-16T09:00:00+05:00"))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go WatchScheduleFile(ctx, path, schedule, fake)
+	fake.BlockUntil(1)
+
+	assert.NoError(t, os.WriteFile(path, []byte(`{"windows": [{"till": "2025-12-17 09:00", "label": "First"}, {"till": "2025-12-18 09:00", "label": "Second"}]}`), 0644))
+	later := time.Now().Add(time.Minute)
+	assert.NoError(t, os.Chtimes(path, later, later))
+	assert.Len(t, schedule.Windows(), 1, "the file is polled on the clock")
+
+	fake.Advance(scheduleFilePollTime)
+	assert.Eventually(t, func() bool { return len(schedule.Windows()) == 2 }, time.Second, time.Millisecond)
+}
@@ -5,16 +5,19 @@ import (
 	"log/slog"
 	"os"
 	"os/signal"
+	"slices"
 	"sync"
 	"syscall"
 
+	"github.com/TheTeemka/telegram_bot_cources/internal/clock"
 	"github.com/TheTeemka/telegram_bot_cources/internal/config"
 	"github.com/TheTeemka/telegram_bot_cources/internal/database"
+	"github.com/TheTeemka/telegram_bot_cources/internal/events"
 	"github.com/TheTeemka/telegram_bot_cources/internal/repositories"
 	"github.com/TheTeemka/telegram_bot_cources/internal/service"
 	"github.com/TheTeemka/telegram_bot_cources/internal/telegram"
+	"github.com/TheTeemka/telegram_bot_cources/internal/ticker"
 	"github.com/TheTeemka/telegram_bot_cources/pkg/logging"
-	tapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
 
 func main() {
@@ -26,13 +29,48 @@ func main() {
 	slog.Info("Starting Application", "config", cfg)
 	db := database.NewSQLiteDB("./data/db.db")
 
-	courseRepo := repositories.NewCourseRepo(cfg.APIConfig)
-	subscriptionRepo := repositories.NewSQLiteSubscriptionRepo(db)
-	stateRepo := repositories.NewStateRepository(db)
-	statisticsRepo := repositories.NewStatisticsRepository(db)
+	clk := clock.Real()
+	courseRepo := repositories.NewCourseRepo(cfg.APIConfig, clk)
+	subscriptionRepo := repositories.NewSQLiteSubscriptionRepo(db, clk)
+	bundleRepo := repositories.NewSQLiteBundleRepo(db, clk)
+	interestRepo := repositories.NewSQLiteInterestRepo(db, clk)
+	stateRepo := repositories.NewStateRepository(db, clk)
+	settingsRepo := repositories.NewSQLiteUserSettingsRepo(db, clk)
+	userRepo := repositories.NewSQLiteUserRepo(db, clk)
+	queueRepo := repositories.NewSQLiteNotificationQueueRepo(db, clk)
+	historyRepo := repositories.NewSQLiteHistoryRepo(db)
+	statisticsRepo := repositories.NewStatisticsRepository(db, clk)
+	windowRepo := repositories.NewSQLiteWindowRepo(db)
+	reminderRepo := repositories.NewSQLiteReminderRepo(db)
 
-	bot := telegram.NewTelegramBot(cfg.EnvStage, cfg.BotConfig, courseRepo, subscriptionRepo, stateRepo, statisticsRepo)
-	tracker := service.NewTracker(courseRepo, subscriptionRepo, cfg.TimeIntervalBetweenParses)
+	schedule := loadSchedule(cfg.SchedulePath, windowRepo)
+	bus := events.NewBus()
+	outboxSignal := make(chan struct{}, 1)
+	var adaptive *ticker.AdaptiveInterval
+	if cfg.AdaptivePolling {
+		var err error
+		adaptive, err = ticker.NewAdaptiveInterval(cfg.TimeIntervalBetweenParses, cfg.MinInterval, cfg.MaxInterval)
+		if err != nil {
+			slog.Error("Invalid adaptive polling bounds", "error", err)
+			os.Exit(1)
+		}
+	}
+	refresher := service.NewRefresher(courseRepo, bus, schedule, cfg.TimeIntervalBetweenParses, adaptive, clk)
+	predictor := service.NewPredictor(courseRepo, historyRepo, bus, clk)
+	reminders := service.NewReminders(schedule, settingsRepo, reminderRepo, subscriptionRepo, courseRepo, queueRepo, outboxSignal, clk)
+	bot := telegram.NewTelegramBot(cfg.EnvStage, cfg.BotConfig, courseRepo, subscriptionRepo, bundleRepo, interestRepo, stateRepo, settingsRepo, queueRepo, userRepo, statisticsRepo, windowRepo, schedule, refresher, predictor, reminders, clk)
+	notifier := service.NewNotifier(settingsRepo, clk)
+	fairnessPolicy, err := service.ParseFairnessPolicy(cfg.FairnessPolicy)
+	if err != nil {
+		slog.Error("Invalid fairness policy", "error", err)
+		os.Exit(1)
+	}
+	trackingStore := repositories.NewTrackingStore(db, subscriptionRepo, bundleRepo, interestRepo, queueRepo)
+	fairness := service.NewFairness(fairnessPolicy, queueRepo)
+	tracker := service.NewTracker(courseRepo, subscriptionRepo, bundleRepo, interestRepo, trackingStore, notifier, fairness, bus, outboxSignal, clk)
+	historyRecorder := service.NewHistoryRecorder(historyRepo, bus)
+	janitor := service.NewJanitor(subscriptionRepo, stateRepo, queueRepo, trackingStore, notifier, outboxSignal, schedule, cfg.SubscriptionsExpireAfter, clk)
+	adminAlerts := service.NewAdminAlerts(cfg.BotConfig.AdminID, queueRepo, bus, outboxSignal, clk)
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -44,12 +82,53 @@ func main() {
 		"cources url", cfg.APIConfig.CourseURL,
 		"semester name", bot.CoursesRepo.SemesterName)
 
-	writeChan := make(chan tapi.Chattable, 10)
 	var wg sync.WaitGroup
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		tracker.Start(ctx, writeChan)
+		tracker.Start(ctx)
+	}()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		historyRecorder.Start(ctx)
+	}()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		adminAlerts.Start(ctx)
+	}()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		predictor.Start(ctx)
+	}()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker.WatchScheduleFile(ctx, cfg.SchedulePath, schedule, clk)
+	}()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		refresher.Start(ctx)
+	}()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		janitor.Start(ctx)
+	}()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		reminders.Start(ctx)
 	}()
 
 	wg.Add(1)
@@ -61,13 +140,55 @@ func main() {
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		bot.Start(ctx, writeChan)
+		bot.Start(ctx, outboxSignal)
 	}()
 
 	wg.Wait()
 	slog.Info("Telegram Bot Gracefully shut down")
 }
 
+// loadSchedule merges the schedule file with windows added by admins, which may use ramps from the file,
+// and exits if they are broken.
+// A missing file is waited for while polling at the default interval
+func loadSchedule(path string, windowRepo repositories.RegistrationWindowRepository) *ticker.Schedule {
+	schedule := ticker.NewSchedule()
+
+	if _, err := os.Stat(path); err == nil {
+		profiles, windows, err := ticker.LoadScheduleFile(path)
+		if err == nil {
+			err = schedule.Reload(ticker.SourceFile, profiles, windows)
+		}
+		if err != nil {
+			slog.Error("Invalid registration schedule", "error", err, "path", path)
+			os.Exit(1)
+		}
+	} else {
+		slog.Warn("Registration schedule not found, polling at the default interval", "error", err, "path", path)
+	}
+
+	added, err := windowRepo.GetAll()
+	if err != nil {
+		slog.Error("Failed to get registration windows", "error", err)
+		os.Exit(1)
+	}
+	// a window added by an admin may clash with a later edit of the file, it must not keep the bot down
+	var accepted []ticker.TickerIntervalConfig
+	for _, w := range added {
+		if err := schedule.Check(ticker.SourceAdmin, append(slices.Clone(accepted), w)); err != nil {
+			slog.Error("Skipping registration window added by admins", "error", err, "id", w.ID, "label", w.Label, "till", w.Till)
+			continue
+		}
+		accepted = append(accepted, w)
+	}
+	if err := schedule.Set(ticker.SourceAdmin, accepted); err != nil {
+		slog.Error("Invalid registration windows added by admins", "error", err)
+		os.Exit(1)
+	}
+
+	slog.Info("Registration schedule loaded", "windows", len(schedule.Windows()), "registration_end", schedule.RegistrationEnd())
+	return schedule
+}
+
 func gracefullShutdown(cancel context.CancelFunc) {
 	sigCh := make(chan os.Signal, 1)
 	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM, syscall.SIGPIPE)